@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Расширение архива с прайс-листами
+const archiveExt = ".zip"
+
+// Файл является архивом с прайс-листами
+func isArchiveFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), archiveExt)
+}
+
+// Директории распакованных архивов; удаляются по окончании обработки
+var (
+	archiveDirsMu sync.Mutex
+	archiveDirs   = make(map[string]bool)
+)
+
+// Обработка архива: прайс-листы из него распаковываются в -download-dir и
+// обрабатываются как отдельные файлы. Настройки ищутся по имени файла в архиве
+// (без каталогов), в журналах файл называется "архив/путь в архиве". Прочие
+// файлы архива, вложенные архивы и пути вне архива (абсолютные, с "..")
+// пропускаются.
+func dispatchArchive(db *gorm.DB, source, archivePath string) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		log.Printf("Не удалось открыть архив '%s': %v\n", archivePath, err)
+		counters.FilesFailed.Add(1)
+		return
+	}
+	defer reader.Close()
+
+	sum := sha256.Sum256([]byte(source))
+	dir := filepath.Join(*flagDownloadDir, "zip-"+hex.EncodeToString(sum[:8]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Не удалось распаковать архив '%s': %v\n", archivePath, err)
+		counters.FilesFailed.Add(1)
+		return
+	}
+	archiveDirsMu.Lock()
+	archiveDirs[dir] = true
+	archiveDirsMu.Unlock()
+
+	for _, member := range reader.File {
+		name, ok := archiveMemberPath(member.Name)
+		if member.FileInfo().IsDir() || isArchiveFile(name) || !isInputFile(name) {
+			continue
+		}
+		if !ok {
+			log.Printf("Файл %s из архива '%s' пропущен: путь выходит за пределы архива\n", member.Name, archivePath)
+			continue
+		}
+		// Файлы с одним именем в разных каталогах архива не заменяют друг друга
+		localPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := extractArchiveMember(member, localPath); err != nil {
+			log.Printf("Не удалось распаковать %s из архива '%s': %v\n", member.Name, archivePath, err)
+			counters.FilesFailed.Add(1)
+			continue
+		}
+		dispatchFileFrom(db, source+"/"+name, localPath)
+	}
+}
+
+// Относительный путь файла в архиве с прямыми косыми; false — путь абсолютный
+// или выходит за пределы архива через ".."
+func archiveMemberPath(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	return name, filepath.IsLocal(filepath.FromSlash(name)) && !path.IsAbs(name)
+}
+
+// Удаление распакованных архивов. Вызывается после завершения всех обработок.
+func removeArchiveDirs() {
+	archiveDirsMu.Lock()
+	defer archiveDirsMu.Unlock()
+	for dir := range archiveDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Не удалось удалить распакованный архив %s: %v\n", dir, err)
+		}
+		delete(archiveDirs, dir)
+	}
+}
+
+// Распаковка одного файла архива
+func extractArchiveMember(member *zip.File, localPath string) error {
+	in, err := member.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("запись %s: %w", localPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestArchiveMemberPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"prices.xlsx", "prices.xlsx", true},
+		{"a/prices.xlsx", "a/prices.xlsx", true},
+		{`b\prices.xlsx`, "b/prices.xlsx", true},
+		{"a/../b/prices.xlsx", "b/prices.xlsx", true},
+		{"../prices.xlsx", "../prices.xlsx", false},
+		{"a/../../prices.xlsx", "../prices.xlsx", false},
+		{"/etc/prices.xlsx", "/etc/prices.xlsx", false},
+		{`\prices.xlsx`, "/prices.xlsx", false},
+	}
+	for _, tt := range tests {
+		got, ok := archiveMemberPath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("archiveMemberPath(%q) = %q, %v; ожидалось %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Права файлов выгрузки по умолчанию
+const defaultOutputFileMode os.FileMode = 0644
+
+// Файл, который появляется под итоговым именем только целиком: запись идёт во
+// временный файл в той же директории, commit переименовывает его на место.
+// Читатель итогового имени видит либо прежнюю версию, либо полную новую.
+type atomicFile struct {
+	*os.File
+	target string
+	mode   os.FileMode
+}
+
+// Создание временного файла для target. При keepExisting в него сначала
+// копируется текущее содержимое target (дописывание без частичного состояния).
+func createAtomic(target string, mode os.FileMode, keepExisting bool) (*atomicFile, error) {
+	// CreateTemp создаёт файл с правами 0600: до commit содержимое не видно другим
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	file := &atomicFile{File: tmp, target: target, mode: mode}
+
+	if keepExisting {
+		existing, err := os.Open(target)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			file.abort()
+			return nil, err
+		}
+		if err == nil {
+			_, err = io.Copy(tmp, existing)
+			existing.Close()
+			if err != nil {
+				file.abort()
+				return nil, err
+			}
+		}
+	}
+	return file, nil
+}
+
+// Завершение записи: сброс на диск, права и переименование на итоговое имя
+func (a *atomicFile) commit() error {
+	if err := a.Sync(); err != nil {
+		a.abort()
+		return err
+	}
+	if err := a.Close(); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+	if err := os.Chmod(a.Name(), a.mode); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+	if err := os.Rename(a.Name(), a.target); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+	return nil
+}
+
+// Отмена записи: временный файл удаляется, итоговый не меняется
+func (a *atomicFile) abort() {
+	a.Close()
+	os.Remove(a.Name())
+}
+
+// Разбор прав файла в восьмеричной записи ("0640", "600")
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("ожидаются права в восьмеричной записи, например 0640, получено %q", value)
+	}
+	return os.FileMode(mode), nil
+}
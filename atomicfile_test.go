@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// До commit под итоговым именем видна прежняя версия файла (или его нет);
+// abort оставляет её нетронутой и не оставляет временных файлов
+func TestAtomicFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     string // "" — файла нет
+		keepExisting bool
+		commit       bool
+		want         string // Содержимое после завершения ("" — файла нет)
+	}{
+		{"новый файл", "", false, true, "новое"},
+		{"замена", "прежнее", false, true, "новое"},
+		{"дописывание", "прежнее;", true, true, "прежнее;новое"},
+		{"отмена нового", "", false, false, ""},
+		{"отмена замены", "прежнее", true, false, "прежнее"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "output.sql")
+			if tt.existing != "" {
+				if err := os.WriteFile(target, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file, err := createAtomic(target, 0640, tt.keepExisting)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := file.WriteString("новое"); err != nil {
+				t.Fatal(err)
+			}
+			if got := readIfExists(t, target); got != tt.existing {
+				t.Errorf("до завершения под итоговым именем %q, ожидалось %q", got, tt.existing)
+			}
+
+			if tt.commit {
+				if err := file.commit(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				file.abort()
+			}
+			if got := readIfExists(t, target); got != tt.want {
+				t.Errorf("после завершения %q, ожидалось %q", got, tt.want)
+			}
+			wantFiles := 0
+			if tt.want != "" {
+				wantFiles = 1
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != wantFiles {
+				t.Errorf("в директории файлы %v, ожидалось файлов: %d", entries, wantFiles)
+			}
+			if info, err := os.Stat(target); err == nil && tt.commit && info.Mode().Perm() != 0640 {
+				t.Errorf("права %v, ожидалось 0640", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+// Содержимое файла ("" — файла нет)
+func readIfExists(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// Пока выгрузка выбирает записи порциями, файла под итоговым именем нет;
+// по окончании он появляется целиком с заданными правами
+func TestExportAtomic(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	products := []Product{
+		{Article: "ab100", Brand: "bosch", Name: "Фильтр", Hash: "h1"},
+		{Article: "ab200", Brand: "bosch", Name: "Свеча", Hash: "h2"},
+		{Article: "ab300", Brand: "bosch", Name: "Ремень", Hash: "h3"},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "output.sql")
+	queries := 0
+	err := db.Callback().Query().After("gorm:query").Register("test:check_output", func(*gorm.DB) {
+		queries++
+		if _, err := os.Stat(output); !os.IsNotExist(err) {
+			t.Errorf("файл выгрузки виден до её окончания (выборка %d)", queries)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exportToSQLFile(db, output, ExportOptions{BatchSize: 1, FileMode: 0600}); err != nil {
+		t.Fatal(err)
+	}
+	if queries < len(products) {
+		t.Fatalf("выгрузка выполнила %d выборок, ожидалось не меньше %d", queries, len(products))
+	}
+
+	dump := readIfExists(t, output)
+	for _, p := range products {
+		if !strings.Contains(dump, "'"+p.Article+"'") {
+			t.Errorf("в выгрузке нет записи %s", p.Article)
+		}
+	}
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("права файла выгрузки %v, ожидалось 0600", info.Mode().Perm())
+	}
+}
+
+// Ошибка посреди выгрузки (здесь — нет таблицы товаров) возвращается, а не
+// завершает процесс; временный файл удаляется, прежний файл выгрузки не меняется
+func TestExportFailureLeavesNoTempFile(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newEmptyTestDB(t)
+	tests := []struct {
+		name     string
+		opts     ExportOptions
+		existing string
+	}{
+		{"новый файл", ExportOptions{BatchSize: 10}, ""},
+		{"дописывание", ExportOptions{BatchSize: 10}, "-- прежняя выгрузка\n"},
+		{"архив", ExportOptions{BatchSize: 10, Archive: ArchiveZip}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := filepath.Join(dir, "output.sql")
+			if tt.existing != "" {
+				if err := os.WriteFile(output, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := exportToSQLFile(db, output, tt.opts); err == nil {
+				t.Fatal("выгрузка без таблицы товаров завершилась без ошибки")
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			wantFiles := 0
+			if tt.existing != "" {
+				wantFiles = 1
+			}
+			if len(names) != wantFiles {
+				t.Errorf("в директории %v, ожидалось файлов: %d", names, wantFiles)
+			}
+			if got := readIfExists(t, output); got != tt.existing {
+				t.Errorf("файл выгрузки %q, ожидалось %q", got, tt.existing)
+			}
+		})
+	}
+}
+
+// Архив выгрузки закрывается до установки на место: zip читается целиком
+func TestExportArchive(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	if err := db.Create(&Product{Article: "ab100", Brand: "bosch", Name: "Фильтр", Hash: "h1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := exportToSQLFile(db, filepath.Join(dir, "output.sql"), ExportOptions{BatchSize: 10, Archive: ArchiveZip}); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.OpenReader(filepath.Join(dir, "output.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if len(archive.File) != 1 || archive.File[0].Name != "output.sql" {
+		t.Fatalf("файлы архива %v", archive.File)
+	}
+	r, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	dump, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dump), "'ab100'") {
+		t.Errorf("в архиве нет записи:\n%s", dump)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Параметры автоопределения колонок
+const (
+	autodetectSampleRows    = 50  // Строк листа, по которым определяются колонки
+	autodetectMinConfidence = 0.3 // Ниже этой уверенности лист не обрабатывается
+)
+
+// Оценки колонки листа для каждой роли (от 0 до 1)
+type columnScores struct {
+	article float64
+	brand   float64
+	name    float64
+}
+
+// Автоопределение колонок по первым строкам листа: артикул — короткие
+// уникальные коды с цифрами, бренд — короткие повторяющиеся строки, название —
+// длинный текст из нескольких слов. Возвращает колонки и уверенность от 0 до 1
+// (наименьшая из оценок выбранных колонок).
+func detectColumns(rows [][]string, sampleRows int) (ColumnSettings, float64) {
+	sample := rows[:min(len(rows), sampleRows)]
+	width := 0
+	for _, row := range sample {
+		width = max(width, len(row))
+	}
+	if len(sample) == 0 || width < 2 {
+		return ColumnSettings{}, 0
+	}
+
+	scores := make([]columnScores, width)
+	for col := range scores {
+		var values []string
+		for _, row := range sample {
+			if col < len(row) {
+				if value := strings.TrimSpace(row[col]); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+		scores[col] = scoreColumn(values, len(sample))
+	}
+
+	// Колонки выбираются по очереди: сначала самая надёжная роль — артикул
+	pick := func(score func(columnScores) float64, taken ...int) (int, float64) {
+		best, bestScore := 0, 0.0
+		for col, s := range scores {
+			index := col + 1
+			if score(s) > bestScore && !slices.Contains(taken, index) {
+				best, bestScore = index, score(s)
+			}
+		}
+		return best, bestScore
+	}
+	article, articleScore := pick(func(s columnScores) float64 { return s.article })
+	name, nameScore := pick(func(s columnScores) float64 { return s.name }, article)
+	brand, brandScore := pick(func(s columnScores) float64 { return s.brand }, article, name)
+
+	settings := ColumnSettings{Article: article, Name: name, Brand: brand}
+	return settings, min(articleScore, nameScore, brandScore)
+}
+
+// Оценка значений одной колонки для каждой роли
+func scoreColumn(values []string, sampleSize int) columnScores {
+	if len(values) == 0 {
+		return columnScores{}
+	}
+	fill := float64(len(values)) / float64(sampleSize)
+
+	distinct := make(map[string]bool)
+	var codes, short, text int
+	totalLen := 0
+	for _, value := range values {
+		distinct[strings.ToLower(value)] = true
+		length := utf8.RuneCountInString(value)
+		totalLen += length
+		words := len(strings.Fields(value))
+		hasDigit := strings.ContainsFunc(value, unicode.IsDigit)
+
+		if words <= 2 && length >= 2 && length <= 30 && hasDigit {
+			codes++
+		}
+		if words <= 3 && length <= 25 && !hasDigit {
+			short++
+		}
+		if words >= 3 {
+			text++
+		}
+	}
+	n := float64(len(values))
+	uniqueness := float64(len(distinct)) / n
+	repetition := 1 - uniqueness
+	if len(distinct) == 1 && len(values) > 1 {
+		repetition = 1 // Один бренд на весь лист
+	}
+	avgLen := float64(totalLen) / n
+
+	return columnScores{
+		article: fill * float64(codes) / n * uniqueness,
+		brand:   fill * float64(short) / n * repetition,
+		name:    fill * float64(text) / n * min(avgLen/30, 1),
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Каноническое строковое значение ячейки по её типу в файле.
+// Логическое значение становится "TRUE"/"FALSE", целое число — цифрами без дробной
+// части и разделителей разрядов, дробное — кратчайшей десятичной записью. Для
+// текстовых ячеек (и при ошибке чтения) возвращается значение formatted из GetRows.
+// Типы ячеек хранит только xlsx; в остальных форматах значение не меняется.
+func canonicalCell(book workbook, sheet string, col, row int, formatted string) string {
+	xlsx, ok := book.(xlsxWorkbook)
+	if !ok {
+		return formatted
+	}
+	f := xlsx.File
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return formatted
+	}
+	cellType, err := f.GetCellType(sheet, cell)
+	if err != nil {
+		return formatted
+	}
+
+	switch cellType {
+	case excelize.CellTypeBool, excelize.CellTypeNumber, excelize.CellTypeUnset:
+	default:
+		return formatted
+	}
+
+	raw, err := f.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+	if err != nil || raw == "" {
+		return formatted
+	}
+
+	if cellType == excelize.CellTypeBool {
+		if raw == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+
+	number, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return formatted
+	}
+	if number == math.Trunc(number) && math.Abs(number) < 1e15 {
+		return strconv.FormatInt(int64(number), 10)
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// Число значащих цифр, которые Excel хранит в числовой ячейке
+const excelSignificantDigits = 15
+
+var scientificNotationRe = regexp.MustCompile(`^[+-]?\d+(?:[.,]\d+)?[eE][+-]?\d+$`)
+
+// Признаки того, что числовой артикул прошёл через число с плавающей точкой и
+// потерял цифры: экспоненциальная запись ("1.23457E+16") или больше 15 цифр,
+// где всё после 15-й значащей — нули (Excel обнуляет их при вводе числом).
+// Длинные артикулы из цифр, хранящиеся как текст, такого хвоста обычно не имеют.
+// Возвращает описание проблемы или пустую строку.
+func articlePrecisionLoss(raw string) string {
+	value := strings.TrimSpace(raw)
+	if scientificNotationRe.MatchString(value) {
+		return "экспоненциальная запись числа"
+	}
+
+	digits := strings.TrimLeft(value, "0")
+	if len(digits) <= excelSignificantDigits || strings.TrimFunc(digits, func(r rune) bool { return r >= '0' && r <= '9' }) != "" {
+		return ""
+	}
+	if strings.Trim(digits[excelSignificantDigits:], "0") == "" {
+		return "больше 15 цифр с нулями после 15-й — вероятно, число округлено Excel"
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Файл xlsx с артикулами разных типов ячеек в колонке A, брендом и названием в B и C
+func writeCellTypesXLSX(t *testing.T) string {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	style, err := f.NewStyle(&excelize.Style{CustomNumFmt: ptr("#,##0.00")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := []struct {
+		cell  string
+		value any
+	}{
+		{"A1", true},
+		{"A2", false},
+		{"A3", 12345},
+		{"A4", 12.5},
+		{"A5", "00123"},
+		{"A6", 123456789012},
+	}
+	for i, c := range cells {
+		if err := f.SetCellValue("Sheet1", c.cell, c.value); err != nil {
+			t.Fatal(err)
+		}
+		f.SetSheetRow("Sheet1", "B"+strconv.Itoa(i+1), &[]string{"Bosch", "Фильтр"})
+	}
+	// Числа с форматом разделителей разрядов и двух знаков после запятой
+	if err := f.SetCellStyle("Sheet1", "A3", "A3", style); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetCellStyle("Sheet1", "A6", "A6", style); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "types.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestCanonicalCell(t *testing.T) {
+	path := writeCellTypesXLSX(t)
+	book, err := openWorkbook(path, FileConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+	rows, err := book.Rows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		row           int
+		wantFormatted string
+		want          string
+	}{
+		{1, "TRUE", "TRUE"},
+		{2, "FALSE", "FALSE"},
+		{3, "12,345.00", "12345"},
+		{4, "12.5", "12.5"},
+		{5, "00123", "00123"}, // Текст не меняется
+		{6, "123,456,789,012.00", "123456789012"},
+	}
+	for _, tt := range tests {
+		formatted := rows[tt.row-1][0]
+		if formatted != tt.wantFormatted {
+			t.Errorf("строка %d: GetRows вернул %q, ожидалось %q", tt.row, formatted, tt.wantFormatted)
+		}
+		if got := canonicalCell(book, "Sheet1", 1, tt.row, formatted); got != tt.want {
+			t.Errorf("строка %d: canonicalCell = %q, ожидалось %q", tt.row, got, tt.want)
+		}
+	}
+
+	// Форматы без типов ячеек возвращают значение как есть
+	memory := memoryWorkbook{{Name: "Sheet1", Rows: [][]string{{"12,345.00"}}}}
+	if got := canonicalCell(memory, "Sheet1", 1, 1, "12,345.00"); got != "12,345.00" {
+		t.Errorf("canonicalCell для книги в памяти = %q", got)
+	}
+}
+
+// Числовой артикул с форматом "#,##0.00" сохраняется целым числом только
+// с canonical_cell_types; логический — как "true"/"false"
+func TestCanonicalCellTypesImport(t *testing.T) {
+	path := writeCellTypesXLSX(t)
+	tests := []struct {
+		canonical bool
+		want      []string
+	}{
+		{true, []string{"true", "false", "12345", "125", "00123", "123456789012"}},
+		{false, []string{"true", "false", "1234500", "125", "00123", "12345678901200"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("canonical=%v", tt.canonical), func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "types.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, CanonicalCellTypes: tt.canonical}
+			err := processXLSXFileWithConfig(db, path, fc, func() (workbook, error) { return openWorkbook(path, fc) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			var articles []string
+			for _, p := range storedProducts(t, db) {
+				articles = append(articles, p.Article)
+			}
+			if !slices.Equal(articles, tt.want) {
+				t.Errorf("артикулы %v, ожидалось %v", articles, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticlePrecisionLoss(t *testing.T) {
+	tests := []struct {
+		article string
+		want    bool
+	}{
+		{"12345678901234567", false}, // 17 цифр текстом
+		{"12345678901234500", true},  // 17 цифр, после 15-й — нули
+		{"1.23457E+16", true},
+		{"1,23457e+16", true},
+		{"123456789012345", false},   // 15 цифр — Excel хранит точно
+		{"00123456789012345", false}, // Ведущие нули не считаются
+		{"100000000000000000", true},
+		{"AB-12345678901234500", false}, // Не число
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := articlePrecisionLoss(tt.article); (got != "") != tt.want {
+			t.Errorf("articlePrecisionLoss(%q) = %q, ожидалась потеря точности: %v", tt.article, got, tt.want)
+		}
+	}
+}
+
+// 17-значный артикул, округлённый Excel, отклоняется при article_precision_check=reject;
+// при warn и без проверки он импортируется
+func TestArticlePrecisionCheck(t *testing.T) {
+	rows := [][]string{
+		{"12345678901234567", "Bosch", "Фильтр"},
+		{"12345678901234500", "Bosch", "Свеча"},
+		{"1.23457E+16", "Bosch", "Колодки"},
+	}
+	tests := []struct {
+		check       string
+		want        []string
+		wantRejects int
+	}{
+		{"", []string{"12345678901234567", "12345678901234500", "123457e16"}, 0},
+		{PrecisionCheckWarn, []string{"12345678901234567", "12345678901234500", "123457e16"}, 0},
+		{PrecisionCheckReject, []string{"12345678901234567"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run("check="+tt.check, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, ArticlePrecisionCheck: tt.check}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			var articles []string
+			for _, p := range storedProducts(t, db) {
+				articles = append(articles, p.Article)
+			}
+			if !slices.Equal(articles, tt.want) {
+				t.Errorf("артикулы %v, ожидалось %v", articles, tt.want)
+			}
+			if reasons := rejectedReasons(); len(reasons) != tt.wantRejects {
+				t.Errorf("отклонено %v, ожидалось строк: %d", reasons, tt.wantRejects)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Директория фрагментов конфигурации рядом с основным файлом
+const configFragmentsDir = "config.d"
+
+// Фрагмент конфигурации: только записи файлов (настройки одного или
+// нескольких поставщиков). Общие настройки задаются в основном файле.
+type configFragment struct {
+	Files []FileConfig `json:"files"`
+}
+
+// Подключение фрагментов конфигурации: файлы из списка include основного файла
+// (пути и glob шаблоны относительно его директории) и все .json, .yaml, .yml
+// и .toml из config.d рядом с ним, по алфавиту. Записи files добавляются к основным;
+// одно имя файла в двух записях — ошибка с указанием обоих источников.
+func loadConfigIncludes(configPath string) error {
+	baseDir := filepath.Dir(configPath)
+
+	var paths []string
+	for _, pattern := range config.Include {
+		pattern, err := expandEnv(pattern)
+		if err != nil {
+			return fmt.Errorf("include: %w", err)
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !isFilenamePattern(pattern) {
+			return fmt.Errorf("include: файл %s не найден", pattern)
+		}
+		slices.Sort(matches)
+		paths = append(paths, matches...)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(baseDir, configFragmentsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("не удалось прочитать %s: %w", configFragmentsDir, err)
+	}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".json", ".yaml", ".yml", ".toml":
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(baseDir, configFragmentsDir, entry.Name()))
+			}
+		}
+	}
+
+	// Источник каждой записи — для сообщения о повторе
+	sources := make(map[string]string, len(config.Files))
+	for _, fc := range config.Files {
+		if _, ok := sources[fc.Filename]; !ok {
+			sources[fc.Filename] = configPath
+		}
+	}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			continue // Файл попал и в include, и в config.d
+		}
+		seen[path] = true
+
+		files, err := readConfigFragment(path)
+		if err != nil {
+			return fmt.Errorf("фрагмент конфигурации %s: %w", path, err)
+		}
+		for _, fc := range files {
+			if source, ok := sources[fc.Filename]; ok {
+				return fmt.Errorf("файл %s описан дважды: в %s и в %s", fc.Filename, source, path)
+			}
+			sources[fc.Filename] = path
+			config.Files = append(config.Files, fc)
+		}
+	}
+	return nil
+}
+
+// Записи файлов из фрагмента. Неизвестные ключи (в том числе общие
+// настройки, которым место в основном файле) — ошибка.
+func readConfigFragment(path string) ([]FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := configDocuments(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileConfig
+	for _, doc := range docs {
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.DisallowUnknownFields()
+		var fragment configFragment
+		if err := decoder.Decode(&fragment); err != nil {
+			return nil, err
+		}
+		files = append(files, fragment.Files...)
+	}
+	return files, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Переменные окружения, заменяющие конфигурационный файл
+const (
+	envConfigPath = "XLSXTOSQL_CONFIG"  // Путь к конфигурационному файлу
+	envColumns    = "XLSXTOSQL_COLUMNS" // Колонки по умолчанию, как у -columns
+)
+
+// Путь к прочитанному конфигурационному файлу ("" — файла нет)
+var loadedConfigPath string
+
+// Пути к конфигурационному файлу по умолчанию в порядке поиска
+var defaultConfigPaths = []string{"./config.json", "./config.yaml", "./config.yml", "./config.toml"}
+
+// Загрузка конфигурации. Источники по возрастанию приоритета:
+//
+//  1. конфигурационный файл: -config, иначе XLSXTOSQL_CONFIG, иначе первый
+//     существующий из ./config.json, ./config.yaml, ./config.yml, ./config.toml.
+//     Формат определяется расширением: .yaml и .yml — YAML, .toml — TOML,
+//     остальные — JSON. В строковых
+//     значениях подставляются переменные окружения ${VAR} и ${VAR:-по умолчанию};
+//     К нему добавляются фрагменты из include и config.d (см. loadConfigIncludes).
+//     Затем применяется профиль -profile (или XLSXTOSQL_PROFILE) из секции profiles;
+//  2. переменная окружения XLSXTOSQL_COLUMNS;
+//  3. флаг -columns.
+//
+// Колонки из окружения или флага заменяют default_columns файла. Файл необязателен,
+// если колонки по умолчанию заданы окружением или флагом: тогда обрабатываются все
+// файлы директории с этими колонками. Ошибка возвращается, только если не удалось
+// прочитать явно указанный файл или ни один источник не дал настроек колонок.
+func loadConfig() error {
+	path := *flagConfig
+	if path == "" {
+		path = os.Getenv(envConfigPath)
+	}
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPaths[0]
+		for _, candidate := range defaultConfigPaths {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		loadedConfigPath = path
+		if err := parseConfigFile(path, data, &config); err != nil {
+			return fmt.Errorf("ошибка парсинга конфигурационного файла %s: %w", path, err)
+		}
+		if err := loadConfigIncludes(path); err != nil {
+			return err
+		}
+		if err := expandConfigEnv(&config); err != nil {
+			return fmt.Errorf("конфигурационный файл %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// Файла нет — настройки должны прийти из config.d, окружения или флагов
+		if err := loadConfigIncludes(path); err != nil {
+			return err
+		}
+		if err := expandConfigEnv(&config); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("не удалось прочитать конфигурационный файл: %w", err)
+	}
+
+	if config.OutputPath != "" && !flagPassed("output") {
+		*flagOutput = config.OutputPath
+	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
+
+	columns := *flagColumns
+	if columns == "" {
+		columns = os.Getenv(envColumns)
+	}
+	if columns != "" {
+		settings, err := parseColumnsSpec(columns)
+		if err != nil {
+			return fmt.Errorf("некорректное описание колонок %q: %w", columns, err)
+		}
+		config.DefaultColumns = &settings
+	}
+
+	if err := compileFilenamePatterns(config.Files); err != nil {
+		return err
+	}
+
+	if len(config.Files) == 0 && config.DefaultColumns == nil && !defaultsEnabled() && !config.Autodetect && !*flagAutodetect {
+		return fmt.Errorf("нет настроек файлов: нет %s, не заданы -columns и %s", path, envColumns)
+	}
+	return nil
+}
+
+// Документы конфигурационного файла в виде JSON, по формату файла
+func configDocuments(path string, data []byte) ([][]byte, error) {
+	switch {
+	case isYAMLConfig(path):
+		return yamlDocumentsJSON(data)
+	case isTOMLConfig(path):
+		doc, err := tomlDocumentJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{doc}, nil
+	}
+	return [][]byte{data}, nil
+}
+
+// Разбор конфигурационного файла. Документы YAML файла (через "---")
+// применяются по порядку: общие настройки последующих документов заменяют
+// предыдущие, списки files объединяются — удобно держать поставщиков отдельно.
+func parseConfigFile(path string, data []byte, target *Config) error {
+	docs, err := configDocuments(path, data)
+	if err != nil {
+		return err
+	}
+	for i, encoded := range docs {
+		files := target.Files
+		target.Files = nil
+		if err := json.Unmarshal(encoded, target); err != nil {
+			if len(docs) > 1 {
+				return fmt.Errorf("документ %d: %w", i+1, err)
+			}
+			return err
+		}
+		target.Files = append(files, target.Files...)
+	}
+	return nil
+}
+
+// Разбор описания колонок вида "brand=1,article=2,name=3[,date=..,ean=..,price=..]"
+// или с буквами колонок: "brand=A,article=B,name=C"
+func parseColumnsSpec(spec string) (ColumnSettings, error) {
+	var settings ColumnSettings
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return settings, fmt.Errorf("ожидается ключ=номер, получено %q", part)
+		}
+		column, err := parseColumnRef(value) // Номер или буква колонки
+		if err != nil {
+			return settings, err
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "brand":
+			settings.Brand = column
+		case "article":
+			settings.Article = column
+		case "name":
+			settings.Name = column
+		case "date":
+			settings.Date = column
+		case "ean":
+			settings.EAN = column
+		case "price":
+			settings.Price = column
+		default:
+			return settings, fmt.Errorf("неизвестная колонка %q", key)
+		}
+	}
+	if settings.Article == 0 {
+		return settings, errors.New("не задана колонка article")
+	}
+	return settings, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// Защита конфигурации от перезагрузки во время обработки: поиск настроек
+// и обработка файла держат чтение, перезагрузка в режиме -watch — запись.
+// Поэтому файл обрабатывается целиком с одной версией конфигурации.
+var configMu sync.RWMutex
+
+// Изменённый файл относится к конфигурации: основной файл или фрагмент из config.d
+func isConfigFile(path string) bool {
+	if loadedConfigPath == "" {
+		return false
+	}
+	path = filepath.Clean(path)
+	if path == filepath.Clean(loadedConfigPath) {
+		return true
+	}
+	return filepath.Dir(path) == filepath.Join(filepath.Dir(loadedConfigPath), configFragmentsDir)
+}
+
+// Перезагрузка конфигурации в режиме наблюдения. Новая версия подменяет
+// прежнюю целиком после успешного чтения; при ошибке остаётся прежняя.
+// Настройки, от которых зависят подключение и структура таблицы (dsn,
+// table_name, custom_columns), требуют перезапуска и сохраняются прежними.
+func reloadConfig() error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	previous := config
+	config = Config{}
+	if err := loadConfig(); err != nil {
+		config = previous
+		return err
+	}
+
+	if config.DSN != previous.DSN || config.TableName != previous.TableName ||
+		!reflect.DeepEqual(config.CustomColumns, previous.CustomColumns) {
+		log.Println("Внимание: dsn, table_name и custom_columns применяются только после перезапуска")
+		config.DSN = previous.DSN
+		config.TableName = previous.TableName
+		config.CustomColumns = previous.CustomColumns
+	}
+	fmt.Printf("Конфигурация %s перезагружена: %d записей файлов\n", loadedConfigPath, len(config.Files))
+	return nil
+}
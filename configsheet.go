@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Служебный лист, которым поставщик описывает колонки своего файла.
+// Каждая строка — пара «ключ, колонка»: ключ brand, article, name, date, ean
+// или price, колонка — номер (с единицы) или буквенное обозначение ("C").
+// Указанные на листе колонки заменяют колонки из config.json для этого файла,
+// остальные берутся из config.json. Сам лист данных не содержит и не импортируется.
+const configSheetName = "__config"
+
+// Есть ли в файле служебный лист с описанием колонок
+func hasConfigSheet(filePath string) bool {
+	f, err := openWorkbook(filePath, FileConfig{})
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return slices.Contains(f.Sheets(), configSheetName)
+}
+
+// Применение служебного листа к настройкам файла. Возвращает false, если листа нет.
+// Колонки, заданные на листе, точны, поэтому поиск по заголовкам при этом отключается.
+func applyConfigSheet(f workbook, fc *FileConfig) (bool, error) {
+	if !slices.Contains(f.Sheets(), configSheetName) {
+		return false, nil
+	}
+	rows, err := f.Rows(configSheetName)
+	if err != nil {
+		return true, fmt.Errorf("не удалось прочитать лист %s: %w", configSheetName, err)
+	}
+
+	columns := fc.Columns
+	for i, row := range rows {
+		if len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(row[0]))
+		column, err := parseColumnRef(row[1])
+		if err != nil {
+			return true, fmt.Errorf("лист %s, строка %d: %w", configSheetName, i+1, err)
+		}
+		switch key {
+		case "brand":
+			columns.Brand = column
+		case "article":
+			columns.Article = column
+		case "name":
+			columns.Name = column
+		case "date":
+			columns.Date = column
+		case "ean":
+			columns.EAN = column
+		case "price":
+			columns.Price = column
+		default:
+			return true, fmt.Errorf("лист %s, строка %d: неизвестный ключ %q", configSheetName, i+1, row[0])
+		}
+	}
+
+	fc.Columns = columns
+	fc.Headers = nil
+	return true, nil
+}
+
+// Номер колонки из ячейки служебного листа: число или буквенное обозначение
+func parseColumnRef(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("номер колонки должен быть положительным, получено %d", n)
+		}
+		return n, nil
+	}
+	n, err := excelize.ColumnNameToNumber(value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная колонка %q", value)
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// Лист __config задаёт колонки файла вместо config.json; сам лист не импортируется
+func TestConfigSheet(t *testing.T) {
+	data := memorySheet{Name: "Прайс", Rows: [][]string{
+		{"Фильтр", "Bosch", "120", "AB-100"},
+	}}
+	tests := []struct {
+		name    string
+		config  [][]string // Строки листа __config (nil — листа нет)
+		columns ColumnSettings
+		want    [][3]string
+		wantErr bool
+	}{
+		{
+			name:    "номера колонок",
+			config:  [][]string{{"article", "4"}, {"brand", "2"}, {"name", "1"}},
+			columns: ColumnSettings{Article: 1, Brand: 2, Name: 3},
+			want:    [][3]string{{"ab100", "bosch", "Фильтр"}},
+		},
+		{
+			name:    "буквы колонок и ключи в другом регистре",
+			config:  [][]string{{"Article", "D"}, {" NAME ", "a"}},
+			columns: ColumnSettings{Article: 1, Brand: 2, Name: 3},
+			want:    [][3]string{{"ab100", "bosch", "Фильтр"}},
+		},
+		{
+			name:    "листа нет — колонки из config.json",
+			columns: ColumnSettings{Article: 4, Brand: 2, Name: 1},
+			want:    [][3]string{{"ab100", "bosch", "Фильтр"}},
+		},
+		{
+			name:    "неизвестный ключ",
+			config:  [][]string{{"sku", "4"}},
+			columns: ColumnSettings{Article: 4, Brand: 2, Name: 1},
+			wantErr: true,
+		},
+		{
+			name:    "некорректная колонка",
+			config:  [][]string{{"article", "0"}},
+			columns: ColumnSettings{Article: 4, Brand: 2, Name: 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			book := memoryWorkbook{data}
+			if tt.config != nil {
+				book = memoryWorkbook{{Name: configSheetName, Rows: tt.config}, data}
+			}
+			fc := FileConfig{Filename: "prices.xlsx", Columns: tt.columns}
+			err := processXLSXFileWithConfig(db, fc.Filename, fc, func() (workbook, error) { return book, nil })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %v", err, tt.wantErr)
+			}
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
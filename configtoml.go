@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Конфигурационный файл в формате TOML (по расширению)
+func isTOMLConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// Конфигурация TOML в виде JSON: схема конфигурации описана json тегами,
+// поэтому документ разбирается через encoding/json. Даты и время сохраняются строками.
+func tomlDocumentJSON(data []byte) ([]byte, error) {
+	var root map[string]any
+	if _, err := toml.Decode(string(data), &root); err != nil {
+		return nil, err // Ошибка разбора содержит номер строки ("toml: line 3: ...")
+	}
+	return json.Marshal(tomlToJSONValue(root))
+}
+
+// Приведение значения TOML к виду, который принимает encoding/json:
+// массивы таблиц — списком, дата и время — строкой RFC 3339
+func tomlToJSONValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = tomlToJSONValue(item)
+		}
+		return v
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = tomlToJSONValue(item)
+		}
+		return items
+	case []any:
+		for i, item := range v {
+			v[i] = tomlToJSONValue(item)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Конфигурация TOML разбирается в ту же схему, что и JSON
+func TestParseTOMLConfig(t *testing.T) {
+	const data = `
+dsn = "root@tcp(localhost:3306)/testdb"
+table_name = 'products' # литеральная строка
+include = ["suppliers/*.toml", "extra.toml"]
+
+[default_columns]
+brand = 1
+article = 2
+name = 3
+
+[[files]]
+filename = "bosch.xlsx"
+columns = { brand = 2, article = 1, name = 3, price = 5 }
+article_strip_chars = ["-", " ", "\\"]
+summary_markers = ["Итого", "TOTAL\t"]
+
+[[files]]
+filename = """
+mann.xlsx"""
+hash_salt = "mann"
+`
+	var cfg Config
+	if err := parseConfigFile("config.toml", []byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DSN != "root@tcp(localhost:3306)/testdb" || cfg.TableName != "products" {
+		t.Errorf("dsn %q, таблица %q", cfg.DSN, cfg.TableName)
+	}
+	if !slices.Equal(cfg.Include, []string{"suppliers/*.toml", "extra.toml"}) {
+		t.Errorf("include %v", cfg.Include)
+	}
+	if cfg.DefaultColumns == nil || *cfg.DefaultColumns != (ColumnSettings{Brand: 1, Article: 2, Name: 3}) {
+		t.Errorf("default_columns %+v", cfg.DefaultColumns)
+	}
+	if len(cfg.Files) != 2 {
+		t.Fatalf("записей files %d, ожидалось 2", len(cfg.Files))
+	}
+	bosch, mann := cfg.Files[0], cfg.Files[1]
+	if bosch.Filename != "bosch.xlsx" || bosch.Columns != (ColumnSettings{Brand: 2, Article: 1, Name: 3, Price: 5}) {
+		t.Errorf("первая запись %q, колонки %+v", bosch.Filename, bosch.Columns)
+	}
+	if !slices.Equal(bosch.ArticleStripChars, []string{"-", " ", `\`}) || !slices.Equal(bosch.SummaryMarkers, []string{"Итого", "TOTAL\t"}) {
+		t.Errorf("экранирование: %q, %q", bosch.ArticleStripChars, bosch.SummaryMarkers)
+	}
+	if mann.Filename != "mann.xlsx" || mann.HashSalt != "mann" {
+		t.Errorf("вторая запись %q, соль %q", mann.Filename, mann.HashSalt)
+	}
+}
+
+// Ошибки разбора указывают строку файла
+func TestParseTOMLConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"незакрытая строка", "dsn = \"root\ntable_name = \"x\"", "line 1"},
+		{"повтор ключа", "dsn = \"a\"\n\ndsn = \"b\"", "line 3"},
+		{"неизвестное экранирование", "dsn = \"a\"\ntable_name = \"\\q\"", "line 2"},
+		{"нет значения", "[[files]]\nfilename = = \"a\"\n", "line 2"},
+		{"тип не по схеме", "table_name = 5", "cannot unmarshal number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			err := parseConfigFile("config.toml", []byte(tt.data), &cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидалось упоминание %q", err, tt.want)
+			}
+		})
+	}
+}
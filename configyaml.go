@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Конфигурационный файл в формате YAML (по расширению)
+func isYAMLConfig(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Непустые документы YAML файла в виде JSON: схема конфигурации описана json
+// тегами, поэтому документы разбираются через encoding/json
+func yamlDocumentsJSON(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for doc := 1; ; doc++ {
+		var value any
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("документ %d: %w", doc, err)
+		}
+		if value == nil {
+			continue // Пустой документ
+		}
+
+		normalized, err := yamlToJSONValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("документ %d: %w", doc, err)
+		}
+		encoded, err := json.Marshal(normalized)
+		if err != nil {
+			return nil, fmt.Errorf("документ %d: %w", doc, err)
+		}
+		docs = append(docs, encoded)
+	}
+}
+
+// Приведение значения YAML к виду, который принимает encoding/json
+// (ключи отображений — строки)
+func yamlToJSONValue(value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = converted
+		}
+		return v, nil
+	case map[any]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			name, ok := key.(string)
+			if !ok {
+				name = fmt.Sprint(key)
+			}
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = converted
+		}
+		return result, nil
+	case []any:
+		for i, item := range v {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	}
+	return value, nil
+}
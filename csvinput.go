@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Кодировки CSV файлов
+const (
+	CSVEncodingUTF8        = "utf-8"
+	CSVEncodingWindows1251 = "windows-1251"
+)
+
+// Разделители, среди которых выбирается разделитель CSV файла без настройки
+var csvDelimiters = []rune{';', ',', '\t'}
+
+// Строк в начале файла, по которым определяется разделитель
+const csvSniffLines = 20
+
+// Чтение CSV файла. Колонки задаются так же, как для xlsx: номера считаются с единицы.
+type CSVSettings struct {
+	Delimiter string `json:"delimiter,omitempty"` // ",", ";" или "\t" ("tab"); пусто — определить по файлу
+	Encoding  string `json:"encoding,omitempty"`  // utf-8 или windows-1251; пусто — определить по файлу
+}
+
+// Разделитель из настройки (0 — определить по содержимому)
+func (s *CSVSettings) delimiter() (rune, error) {
+	if s == nil || s.Delimiter == "" {
+		return 0, nil
+	}
+	switch s.Delimiter {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s.Delimiter)
+	if size != len(s.Delimiter) || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("некорректный разделитель CSV %q", s.Delimiter)
+	}
+	return r, nil
+}
+
+// Кодировка из настройки ("" — определить по содержимому)
+func (s *CSVSettings) encoding() (string, error) {
+	if s == nil || s.Encoding == "" {
+		return "", nil
+	}
+	switch strings.ToLower(strings.ReplaceAll(s.Encoding, "_", "-")) {
+	case "utf-8", "utf8":
+		return CSVEncodingUTF8, nil
+	case "windows-1251", "cp1251", "win1251":
+		return CSVEncodingWindows1251, nil
+	}
+	return "", fmt.Errorf("неизвестная кодировка CSV %q (ожидается %s или %s)", s.Encoding, CSVEncodingUTF8, CSVEncodingWindows1251)
+}
+
+// CSV файл как прайс-лист с одним листом, названным по имени файла
+// без расширения (оно же — бренд при brand_from_sheet)
+func openCSVWorkbook(filePath string, settings *CSVSettings) (workbook, error) {
+	delimiter, err := settings.delimiter()
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := settings.encoding()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	text, err := decodeCSV(data, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось перекодировать %s: %w", filePath, err)
+	}
+	if delimiter == 0 {
+		delimiter = sniffCSVDelimiter(text)
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1 // Число ячеек в строках прайс-листа различается
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return memoryWorkbook{{Name: name, Rows: trimRowTails(rows)}}, nil
+}
+
+// Текст файла в UTF-8. Без явной кодировки файл, не являющийся корректным
+// UTF-8, считается Windows-1251 — в ней выгружают прайсы русские программы.
+func decodeCSV(data []byte, encoding string) (string, error) {
+	if encoding == "" {
+		encoding = CSVEncodingWindows1251
+		if utf8.Valid(data) {
+			encoding = CSVEncodingUTF8
+		}
+	}
+	if encoding == CSVEncodingWindows1251 {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", err
+		}
+		data = decoded
+	}
+	return string(bytes.TrimPrefix(data, []byte("\ufeff"))), nil
+}
+
+// Разделитель, встречающийся в первых строках файла чаще остальных
+// (без учёта текста в кавычках); по умолчанию — запятая
+func sniffCSVDelimiter(text string) rune {
+	counts := make(map[rune]int, len(csvDelimiters))
+	inQuotes := false
+	lines := 0
+	for _, r := range text {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == '\n' && !inQuotes:
+			lines++
+		case !inQuotes:
+			counts[r]++
+		}
+		if lines >= csvSniffLines {
+			break
+		}
+	}
+
+	best := ','
+	for _, candidate := range csvDelimiters {
+		if counts[candidate] > counts[best] {
+			best = candidate
+		}
+	}
+	return best
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Дополнительная колонка таблицы, объявленная в конфигурации
+type CustomColumn struct {
+	Name      string `json:"name"`      // Имя колонки в таблице, например "category"
+	Type      string `json:"type"`      // SQL тип (по умолчанию VARCHAR(255))
+	Source    int    `json:"source"`    // Индекс колонки в файле (с единицы); переопределяется в FileConfig.Custom
+	Normalize string `json:"normalize"` // trim (по умолчанию), none, lower, upper, article, brand
+}
+
+// Способы нормализации значений дополнительных колонок
+const (
+	CustomNormalizeTrim    = "trim"
+	CustomNormalizeNone    = "none"
+	CustomNormalizeLower   = "lower"
+	CustomNormalizeUpper   = "upper"
+	CustomNormalizeArticle = "article"
+	CustomNormalizeBrand   = "brand"
+)
+
+// Тип дополнительной колонки по умолчанию
+const defaultCustomColumnType = "VARCHAR(255)"
+
+var (
+	customColumnNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,63}$`)
+	customColumnTypeRe = regexp.MustCompile(`(?i)^[a-z]+( ?\(\d+(, ?\d+)?\))?( unsigned)?$`)
+)
+
+// Колонки модели Product, которые нельзя переобъявить
+var reservedColumns = map[string]bool{
+	"id": true, "article": true, "brand": true, "name": true, "hash": true, "raw_key": true,
+	"ean": true, "price": true, "priority": true, "source": true, "updated_at": true,
+}
+
+// Проверка объявлений дополнительных колонок
+func validateCustomColumns(columns []CustomColumn) error {
+	seen := make(map[string]bool)
+	for _, col := range columns {
+		if !customColumnNameRe.MatchString(col.Name) {
+			return fmt.Errorf("недопустимое имя колонки %q: ожидаются строчные латинские буквы, цифры и _", col.Name)
+		}
+		if reservedColumns[col.Name] {
+			return fmt.Errorf("колонка %q уже есть в модели товара", col.Name)
+		}
+		if seen[col.Name] {
+			return fmt.Errorf("колонка %q объявлена дважды", col.Name)
+		}
+		seen[col.Name] = true
+		if col.Type != "" && !customColumnTypeRe.MatchString(col.Type) {
+			return fmt.Errorf("недопустимый тип %q для колонки %q", col.Type, col.Name)
+		}
+		switch col.Normalize {
+		case "", CustomNormalizeTrim, CustomNormalizeNone, CustomNormalizeLower, CustomNormalizeUpper,
+			CustomNormalizeArticle, CustomNormalizeBrand:
+		default:
+			return fmt.Errorf("неизвестная нормализация %q для колонки %q", col.Normalize, col.Name)
+		}
+	}
+	return nil
+}
+
+// SQL тип дополнительной колонки
+func (c CustomColumn) sqlType() string {
+	if c.Type == "" {
+		return defaultCustomColumnType
+	}
+	return c.Type
+}
+
+// Добавление в таблицу отсутствующих дополнительных колонок (дополняет AutoMigrate)
+func migrateCustomColumns(db *gorm.DB, columns []CustomColumn) error {
+	for _, col := range columns {
+		if db.Migrator().HasColumn(&Product{}, col.Name) {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s NULL",
+			quoteIdent(Product{}.TableName(), IdentQuoteBacktick), quoteIdent(col.Name, IdentQuoteBacktick), col.sqlType())
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("не удалось добавить колонку %s: %w", col.Name, err)
+		}
+	}
+	return nil
+}
+
+// Значения дополнительных колонок из строки файла
+func customValues(row []string, fc FileConfig, rules NormalizeRules) map[string]string {
+	if len(config.CustomColumns) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(config.CustomColumns))
+	for _, col := range config.CustomColumns {
+		source := col.Source
+		if override, ok := fc.Custom[col.Name]; ok {
+			source = override
+		}
+		if source <= 0 || len(row) < source {
+			continue
+		}
+		if value := normalizeCustom(row[source-1], col.Normalize, rules); value != "" {
+			values[col.Name] = value
+		}
+	}
+	return values
+}
+
+// Нормализация значения дополнительной колонки
+func normalizeCustom(value, mode string, rules NormalizeRules) string {
+	switch mode {
+	case CustomNormalizeNone:
+		return value
+	case CustomNormalizeLower:
+		return strings.ToLower(strings.TrimSpace(value))
+	case CustomNormalizeUpper:
+		return strings.ToUpper(strings.TrimSpace(value))
+	case CustomNormalizeArticle:
+		return normalizeArticle(value, rules)
+	case CustomNormalizeBrand:
+		return normalizeBrand(value)
+	default:
+		return strings.TrimSpace(value)
+	}
+}
+
+// Запись значений дополнительных колонок. Если onlyEmpty, заполняются
+// только колонки, в которых у записи ещё нет значения.
+func storeCustomValues(db *gorm.DB, id uint, values map[string]string, onlyEmpty bool) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	updates := make(map[string]any, len(values))
+	for name, value := range values {
+		updates[name] = value
+	}
+
+	if onlyEmpty {
+		current, err := loadCustomValues(db, []uint{id})
+		if err != nil {
+			return err
+		}
+		for name, stored := range current[id] {
+			if stored != nil && fmt.Sprint(stored) != "" {
+				delete(updates, name)
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+	}
+
+	return db.Model(&Product{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
+// Значения дополнительных колонок для набора записей: id → колонка → значение
+func loadCustomValues(db *gorm.DB, ids []uint) (map[uint]map[string]any, error) {
+	result := make(map[uint]map[string]any, len(ids))
+	if len(config.CustomColumns) == 0 || len(ids) == 0 {
+		return result, nil
+	}
+
+	columns := []string{"id"}
+	for _, col := range config.CustomColumns {
+		columns = append(columns, col.Name)
+	}
+
+	var rows []map[string]any
+	if err := db.Model(&Product{}).Select(columns).Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		id, ok := toUint(row["id"])
+		if !ok {
+			continue
+		}
+		delete(row, "id")
+		result[id] = row
+	}
+	return result, nil
+}
+
+// Приведение значения id, прочитанного в map, к uint
+func toUint(value any) (uint, bool) {
+	switch v := value.(type) {
+	case uint:
+		return v, true
+	case uint64:
+		return uint(v), true
+	case int64:
+		return uint(v), true
+	case int:
+		return uint(v), true
+	}
+	return 0, false
+}
+
+// SQL литерал значения дополнительной колонки
+func sqlCustomValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return "'" + escapeSQL(string(v)) + "'"
+	case string:
+		return "'" + escapeSQL(v) + "'"
+	case time.Time:
+		return sqlTime(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Дополнительная колонка category: объявление, импорт из файла и выгрузка
+func TestCustomColumnImportExport(t *testing.T) {
+	columns := []CustomColumn{{Name: "category", Source: 4, Normalize: CustomNormalizeLower}}
+	setTestConfig(t, Config{CustomColumns: columns})
+	if err := validateCustomColumns(columns); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	if err := migrateCustomColumns(db, columns); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]string{
+		{"AB-100", "Bosch", "Фильтр", " Фильтры "},
+		{"GDB-1550", "TRW", "Колодки"},
+		{"AB-100", "Bosch", "Фильтр масляный", "Масло"}, // Категория уже есть — не меняется
+	}
+	fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+	importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+
+	products := storedProducts(t, db)
+	values, err := loadCustomValues(db, []uint{products[0].ID, products[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := customText(values[products[0].ID]["category"]); got != "фильтры" {
+		t.Errorf("категория %q, ожидалось %q", got, "фильтры")
+	}
+	if got := customText(values[products[1].ID]["category"]); got != "" {
+		t.Errorf("категория товара без неё: %q", got)
+	}
+
+	dump := exportSQL(t, db, ExportOptions{})
+	for _, want := range []string{
+		"`category` VARCHAR(255) NULL,",
+		"(`article`, `brand`, `name`, `price`, `category`, `updated_at`)",
+		"('ab100', 'bosch', 'Фильтр масляный', NULL, 'фильтры', ",
+		"('gdb1550', 'trw', 'Колодки', NULL, NULL, ",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("в выгрузке нет %q:\n%s", want, dump)
+		}
+	}
+}
+
+func TestValidateCustomColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []CustomColumn
+		wantErr bool
+	}{
+		{"категория", []CustomColumn{{Name: "category"}}, false},
+		{"тип и нормализация", []CustomColumn{{Name: "weight", Type: "DECIMAL(10, 3)", Normalize: CustomNormalizeNone}}, false},
+		{"занятое имя", []CustomColumn{{Name: "name"}}, true},
+		{"имя с пробелом", []CustomColumn{{Name: "my column"}}, true},
+		{"повтор", []CustomColumn{{Name: "category"}, {Name: "category"}}, true},
+		{"тип с выражением", []CustomColumn{{Name: "category", Type: "TEXT; DROP TABLE products"}}, true},
+		{"неизвестная нормализация", []CustomColumn{{Name: "category", Normalize: "title"}}, true},
+	}
+	for _, tt := range tests {
+		if err := validateCustomColumns(tt.columns); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, ожидалась: %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// Значение дополнительной колонки в виде текста ("" — NULL)
+func customText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
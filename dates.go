@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Форматы текстовых дат, распознаваемые по умолчанию
+var defaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"01-02-06",
+}
+
+// Разбор значения флага -since
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ожидается дата в формате 2006-01-02 или RFC3339, получено %q", value)
+}
+
+// Правила разбора дат файла
+type dateRules struct {
+	Layouts  []string       // Форматы текстовых дат в порядке проверки
+	Location *time.Location // Часовой пояс дат без явного смещения
+}
+
+// Правила разбора дат для файла: date_format проверяется первым, затем форматы
+// по умолчанию; timezone — имя из базы IANA (например "Europe/Moscow").
+func dateRulesFor(fc FileConfig) (dateRules, error) {
+	rules := dateRules{Layouts: defaultDateLayouts, Location: time.Local}
+	if fc.DateFormat != "" {
+		rules.Layouts = append([]string{fc.DateFormat}, defaultDateLayouts...)
+	}
+	if fc.Timezone != "" {
+		location, err := time.LoadLocation(fc.Timezone)
+		if err != nil {
+			return dateRules{}, fmt.Errorf("неизвестный часовой пояс %q: %w", fc.Timezone, err)
+		}
+		rules.Location = location
+	}
+	return rules, nil
+}
+
+// Дата изменения строки из колонки col (с единицы). Значение читается без
+// форматирования, поэтому даты, хранящиеся как число, разбираются как серийные даты Excel.
+func rowDate(f workbook, sheet string, col, row int, rules dateRules) (time.Time, error) {
+	raw, err := f.RawCell(sheet, col, row)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseCellDate(raw, rules)
+}
+
+// Разбор даты из ячейки: серийная дата Excel или текст в одном из известных форматов.
+// Даты без смещения относятся к часовому поясу rules.Location; текст со смещением
+// (RFC3339, "+03:00") сохраняет своё.
+func parseCellDate(raw string, rules dateRules) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, errors.New("пустая ячейка даты")
+	}
+	if serial, err := strconv.ParseFloat(raw, 64); err == nil {
+		t, err := excelize.ExcelDateToTime(serial, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// Серийная дата не содержит часового пояса: считаем её датой пояса файла
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), rules.Location), nil
+	}
+	for _, layout := range rules.Layouts {
+		if t, err := time.ParseInLocation(layout, raw, rules.Location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неизвестный формат даты %q", raw)
+}
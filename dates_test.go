@@ -0,0 +1,166 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Отсечка -since на время теста
+func setSinceCutoff(t *testing.T, cutoff time.Time) {
+	t.Helper()
+	prev := sinceCutoff
+	sinceCutoff = cutoff
+	t.Cleanup(func() { sinceCutoff = prev })
+}
+
+// Серийные даты Excel
+func TestParseCellDateSerial(t *testing.T) {
+	rules := dateRules{Layouts: defaultDateLayouts, Location: time.UTC}
+	tests := []struct {
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"45658", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"46296", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), false},
+		{"46296.5", time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC), false},
+		{" 45657 ", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"", time.Time{}, true},
+		{"вчера", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCellDate(tt.raw, rules)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseCellDate(%q) = %v, %v; ожидалось %v (ошибка: %v)", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// Импорт только строк, изменённых после -since: дата читается из значения
+// ячейки без форматирования (серийного числа), а не из её текста
+func TestSinceFiltersSerialDates(t *testing.T) {
+	sheet := memorySheet{
+		Name: "Лист1",
+		Rows: [][]string{
+			{"AB-100", "Bosch", "Фильтр", "31.12.2024"},
+			{"AB-200", "Bosch", "Свеча", "01.10.2026"},
+			{"AB-300", "Bosch", "Ремень", "15.09.2026"},
+			{"AB-400", "Bosch", "Насос", "не указана"},
+		},
+		Raw: [][]string{
+			{"AB-100", "Bosch", "Фильтр", "45657"},
+			{"AB-200", "Bosch", "Свеча", "46296"},
+			{"AB-300", "Bosch", "Ремень", "46280"},
+			{"AB-400", "Bosch", "Насос", "не указана"},
+		},
+	}
+	tests := []struct {
+		since       string
+		want        []string
+		wantRejects int
+	}{
+		{"", []string{"ab100", "ab200", "ab300", "ab400"}, 0},
+		{"2026-09-01", []string{"ab200", "ab300"}, 1},
+		{"2026-09-20", []string{"ab200"}, 1},
+		{"2027-01-01", []string{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run("since="+tt.since, func(t *testing.T) {
+			cutoff, err := parseSince(tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setSinceCutoff(t, cutoff)
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, Date: 4}}
+			importSheets(t, db, fc, memoryWorkbook{sheet})
+
+			got := []string{}
+			for _, p := range storedProducts(t, db) {
+				got = append(got, p.Article)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("импортированы %v, ожидалось %v", got, tt.want)
+			}
+			if n := len(rejectedReasons()); n != tt.wantRejects {
+				t.Errorf("отклонено %d строк, ожидалось %d", n, tt.wantRejects)
+			}
+		})
+	}
+}
+
+// Текстовые даты в формате файла (date_format) и его часовом поясе (timezone)
+func TestParseCellDateFileRules(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	tests := []struct {
+		name    string
+		fc      FileConfig
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"dd.mm.yyyy", FileConfig{Timezone: "UTC"}, "31.12.2024", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"свой формат", FileConfig{DateFormat: "02/01/2006", Timezone: "UTC"}, "31/12/2024", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"свой формат не задан", FileConfig{Timezone: "UTC"}, "31/12/2024", time.Time{}, true},
+		{"часовой пояс файла", FileConfig{Timezone: "Europe/Moscow"}, "31.12.2024 02:00:00", time.Date(2024, 12, 30, 23, 0, 0, 0, time.UTC), false},
+		{"серийная дата в поясе файла", FileConfig{Timezone: "Europe/Moscow"}, "45657", time.Date(2024, 12, 31, 0, 0, 0, 0, moscow), false},
+		{"смещение в тексте важнее пояса", FileConfig{Timezone: "Europe/Moscow"}, "2024-12-31T00:00:00+05:00", time.Date(2024, 12, 30, 19, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := dateRulesFor(tt.fc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseCellDate(tt.raw, rules)
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("parseCellDate(%q) = %v, %v; ожидалось %v (ошибка: %v)", tt.raw, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := dateRulesFor(FileConfig{Timezone: "Europe/Nowhere"}); err == nil {
+		t.Error("неизвестный часовой пояс принят")
+	}
+}
+
+// Отсечка -since сравнивается с датой строки в часовом поясе файла: 02:00 по Москве
+// 31 декабря — это ещё 30 декабря по UTC
+func TestSinceTextDatesTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Moscow"); err != nil {
+		t.Skip("нет базы часовых поясов:", err)
+	}
+	rows := [][]string{
+		{"AB-100", "Bosch", "Фильтр", "31.12.2024 02:00:00"},
+		{"AB-200", "Bosch", "Свеча", "31.12.2024 05:00:00"},
+	}
+	tests := []struct {
+		timezone string
+		want     []string
+	}{
+		{"UTC", []string{"ab100", "ab200"}},
+		{"Europe/Moscow", []string{"ab200"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			setSinceCutoff(t, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, Date: 4}, DateFormat: "02.01.2006 15:04:05", Timezone: tt.timezone}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+
+			got := []string{}
+			for _, p := range storedProducts(t, db) {
+				got = append(got, p.Article)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("импортированы %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
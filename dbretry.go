@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// Код ошибки MySQL ER_CON_COUNT_ERROR ("Too many connections")
+const mysqlErrTooManyConnections = 1040
+
+// Параметры повторов при исчерпании соединений сервера
+type ConnRetrySettings struct {
+	MaxAttempts int `json:"max_attempts"`  // Максимум попыток (по умолчанию 8)
+	BaseDelayMs int `json:"base_delay_ms"` // Начальная задержка в мс, удваивается с каждой попыткой (по умолчанию 100)
+	MaxDelayMs  int `json:"max_delay_ms"`  // Предел задержки в мс (по умолчанию 5000)
+}
+
+// Значения параметров повторов по умолчанию
+const (
+	defaultConnRetryAttempts = 8
+	defaultConnRetryBaseMs   = 100
+	defaultConnRetryMaxMs    = 5000
+)
+
+// Ошибка означает, что сервер исчерпал лимит соединений — это временное состояние
+func isTooManyConnections(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrTooManyConnections
+	}
+	return err != nil && strings.Contains(err.Error(), "Too many connections")
+}
+
+// Выполнение операции с БД с повтором при "Too many connections".
+// Остальные ошибки (в том числе gorm.ErrRecordNotFound) возвращаются сразу.
+func withConnRetry(op func() error) error {
+	settings := config.ConnRetry
+	attempts := settings.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultConnRetryAttempts
+	}
+	delay := time.Duration(settings.BaseDelayMs) * time.Millisecond
+	if delay <= 0 {
+		delay = defaultConnRetryBaseMs * time.Millisecond
+	}
+	maxDelay := time.Duration(settings.MaxDelayMs) * time.Millisecond
+	if maxDelay <= 0 {
+		maxDelay = defaultConnRetryMaxMs * time.Millisecond
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if !isTooManyConnections(err) || attempt >= attempts {
+			return err
+		}
+
+		// Случайный разброс, чтобы ожидающие горутины не возвращались одновременно
+		wait := delay/2 + rand.N(delay/2+1)
+		log.Printf("Сервер БД исчерпал соединения, повтор %d/%d через %v\n", attempt, attempts-1, wait)
+		time.Sleep(wait)
+		delay = min(delay*2, maxDelay)
+	}
+}
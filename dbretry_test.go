@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Исчерпание соединений повторяется с задержкой, остальные ошибки — нет
+func TestWithConnRetry(t *testing.T) {
+	tooMany := &mysqldriver.MySQLError{Number: mysqlErrTooManyConnections, Message: "Too many connections"}
+	other := errors.New("Duplicate entry")
+	tests := []struct {
+		name      string
+		failures  []error // Ошибки первых вызовов, дальше — успех
+		wantCalls int
+		wantErr   error
+	}{
+		{"успех сразу", nil, 1, nil},
+		{"две перегрузки, затем успех", []error{tooMany, tooMany}, 3, nil},
+		{"перегрузка в обёртке", []error{fmt.Errorf("insert: %w", tooMany)}, 2, nil},
+		{"перегрузка текстом", []error{errors.New("Error 1040: Too many connections")}, 2, nil},
+		{"другая ошибка не повторяется", []error{other, tooMany}, 1, other},
+		{"запись не найдена не повторяется", []error{gorm.ErrRecordNotFound}, 1, gorm.ErrRecordNotFound},
+		{"попытки исчерпаны", []error{tooMany, tooMany, tooMany, tooMany}, 3, tooMany},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{ConnRetry: ConnRetrySettings{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 2}})
+			calls := 0
+			err := withConnRetry(func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("вызовов %d, ожидалось %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ошибка %v, ожидалась %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Перегруженный сервер: первые вставки получают "Too many connections",
+// но ни одна строка не теряется
+func TestImportRetriesTooManyConnections(t *testing.T) {
+	setTestConfig(t, Config{ConnRetry: ConnRetrySettings{MaxAttempts: 5, BaseDelayMs: 1, MaxDelayMs: 2}})
+	db := newTestDB(t)
+
+	failures := 4
+	err := db.Callback().Create().Before("gorm:create").Register("test:too_many_connections", func(tx *gorm.DB) {
+		if failures > 0 {
+			failures--
+			tx.AddError(&mysqldriver.MySQLError{Number: mysqlErrTooManyConnections, Message: "Too many connections"})
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]string{
+		{"AB-100", "Bosch", "Фильтр"},
+		{"AB-200", "Bosch", "Свеча"},
+		{"GDB-1550", "TRW", "Колодки"},
+	}
+	fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+	importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+	if got := len(storedProducts(t, db)); got != len(rows) {
+		t.Errorf("записей %d, ожидалось %d", got, len(rows))
+	}
+	if failures != 0 {
+		t.Errorf("перегрузка не смоделирована: осталось %d отказов", failures)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Код завершения, если из-за -max-runtime не обработан хотя бы один файл.
+// Файлы, не успевшие обработаться, пропущены или прерваны, но всё импортированное
+// до срока выгружено как обычно.
+const exitDeadlineExceeded = 3
+
+var errDeadlineExceeded = errors.New("превышено максимальное время работы (-max-runtime)")
+
+// Контекст запуска: отменяется по истечении -max-runtime
+var runCtx = context.Background()
+
+// Установка общего срока запуска; при нулевой длительности срока нет
+func startDeadline(limit time.Duration) context.CancelFunc {
+	if limit <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	runCtx = ctx
+	return cancel
+}
+
+func deadlineExceeded() bool {
+	return runCtx.Err() != nil
+}
+
+// Завершение с кодом exitDeadlineExceeded, если срок истёк до обработки всех файлов
+func exitIfDeadlineExceeded() {
+	code, message := deadlineOutcome()
+	if message != "" {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// Код завершения и сообщение по итогам срока. Код зависит только от файлов:
+// если все они обработаны, а срок истёк позже (во время выгрузки или отчётов),
+// каталог полный и код успешный, а превышение только сообщается.
+func deadlineOutcome() (int, string) {
+	if skipped := counters.FilesDeadline.Load(); skipped > 0 {
+		return exitDeadlineExceeded, fmt.Sprintf("Превышено максимальное время работы: не обработано файлов — %d, каталог импортирован не полностью", skipped)
+	}
+	if deadlineExceeded() {
+		return 0, "Превышено максимальное время работы после обработки всех файлов: выгрузка и отчёты выполнены полностью"
+	}
+	return 0, ""
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Код 3 — только если по истечении срока остались необработанные файлы;
+// срок, истёкший во время выгрузки, лишь сообщается
+func TestDeadlineOutcome(t *testing.T) {
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		skipped     int64
+		wantCode    int
+		wantMessage string
+	}{
+		{"без срока", context.Background(), 0, 0, ""},
+		{"срок истёк на выгрузке", expired, 0, 0, "после обработки всех файлов"},
+		{"файлы пропущены", expired, 2, exitDeadlineExceeded, "не обработано файлов — 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &runCtx, tt.ctx)
+			prev := counters.FilesDeadline.Swap(tt.skipped)
+			t.Cleanup(func() { counters.FilesDeadline.Store(prev) })
+
+			code, message := deadlineOutcome()
+			if code != tt.wantCode {
+				t.Errorf("код %d, ожидается %d", code, tt.wantCode)
+			}
+			if tt.wantMessage == "" && message != "" || !strings.Contains(message, tt.wantMessage) {
+				t.Errorf("сообщение %q, ожидается содержащее %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
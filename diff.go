@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Запись снимка каталога: товар, идентифицируемый хэшем
+type SnapshotEntry struct {
+	Article string `json:"article"`
+	Brand   string `json:"brand"`
+	Name    string `json:"name"`
+}
+
+// Снимок каталога: хэш → товар
+type Snapshot map[string]SnapshotEntry
+
+// Товар в отчёте об изменениях
+type DiffProduct struct {
+	Hash    string `json:"hash"`
+	Article string `json:"article"`
+	Brand   string `json:"brand"`
+	Name    string `json:"name"`
+}
+
+// Переименованный товар: хэш совпал, название изменилось
+type DiffRename struct {
+	Hash    string `json:"hash"`
+	Article string `json:"article"`
+	Brand   string `json:"brand"`
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
+
+// Отчёт об изменениях каталога относительно предыдущей выгрузки
+type DiffReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Previous    string        `json:"previous"`
+	Summary     DiffSummary   `json:"summary"`
+	Added       []DiffProduct `json:"added"`
+	Removed     []DiffProduct `json:"removed"`
+	Renamed     []DiffRename  `json:"renamed"`
+}
+
+// Сводка отчёта об изменениях
+type DiffSummary struct {
+	Previous int `json:"previous"`
+	Current  int `json:"current"`
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Renamed  int `json:"renamed"`
+}
+
+// Снимок текущего содержимого таблицы, выбираемого постранично
+func snapshotFromDB(db *gorm.DB, batchSize int) (Snapshot, error) {
+	snapshot := make(Snapshot)
+	for offset := 0; ; offset += batchSize {
+		var page []Product
+		if err := db.Order("id").Limit(batchSize).Offset(offset).Find(&page).Error; err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return snapshot, nil
+		}
+		for _, p := range page {
+			snapshot[p.Hash] = SnapshotEntry{Article: p.Article, Brand: p.Brand, Name: p.Name}
+		}
+	}
+}
+
+// Сохранение снимка в JSON файл
+func writeSnapshot(snapshot Snapshot, path string) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Загрузка предыдущей выгрузки: JSON снимок или SQL файл, созданный exportToSQLFile.
+// В SQL выгрузке нет хэшей, поэтому записи сопоставляются с текущим каталогом
+// по хранимым article и brand (см. snapshotFromSQL).
+func loadSnapshot(path string, current Snapshot) (Snapshot, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".sql") {
+		return snapshotFromSQL(path, current)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(Snapshot)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("ошибка разбора снимка %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Разбор INSERT запросов SQL выгрузки в снимок. Хэш записи берётся из колонки
+// hash текущего каталога по тем же article и brand: пересчёт по умолчанию не
+// знает правил файла (hash_salt, article_strip_chars, case_sensitive_article,
+// stored_form). Пара, встреченная в каталоге несколько раз (источники с разной
+// солью), получает хэш записи с тем же названием, иначе — любой из оставшихся. Хэш пересчитывается только для товаров,
+// которых в каталоге больше нет: они попадут в удалённые при любом хэше.
+func snapshotFromSQL(path string, current Snapshot) (Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stored := make(map[[2]string][]string, len(current))
+	for hash, entry := range current {
+		key := [2]string{entry.Article, entry.Brand}
+		stored[key] = append(stored[key], hash)
+	}
+	for _, hashes := range stored {
+		sort.Strings(hashes)
+	}
+
+	snapshot := make(Snapshot)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "INSERT INTO") {
+			continue
+		}
+		idx := strings.Index(line, "VALUES (")
+		if idx < 0 {
+			continue
+		}
+		values := parseSQLValues(line[idx+len("VALUES ("):])
+		if len(values) < 3 {
+			continue
+		}
+		entry := SnapshotEntry{Article: values[0], Brand: values[1], Name: values[2]}
+		key := [2]string{entry.Article, entry.Brand}
+		if hashes := stored[key]; len(hashes) > 0 {
+			// Среди записей с той же парой — сначала запись с тем же названием
+			i := slices.IndexFunc(hashes, func(hash string) bool { return current[hash].Name == entry.Name })
+			if i < 0 {
+				i = 0
+			}
+			snapshot[hashes[i]] = entry
+			stored[key] = slices.Delete(hashes, i, i+1)
+			continue
+		}
+		snapshot[generateHash(entry.Article, entry.Brand, rulesFor(FileConfig{}))] = entry
+	}
+	return snapshot, scanner.Err()
+}
+
+// Разбор списка строковых литералов, экранированных escapeSQL
+func parseSQLValues(s string) []string {
+	var values []string
+	var current strings.Builder
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !inString {
+			if c == '\'' {
+				inString = true
+				current.Reset()
+			} else if c == ')' {
+				break
+			}
+			continue
+		}
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+		case c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+			current.WriteByte('\'')
+		case c == '\'':
+			inString = false
+			values = append(values, current.String())
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// Сравнение предыдущего и текущего снимков
+func diffSnapshots(previous, current Snapshot) DiffReport {
+	report := DiffReport{
+		GeneratedAt: time.Now(),
+		Added:       []DiffProduct{},
+		Removed:     []DiffProduct{},
+		Renamed:     []DiffRename{},
+	}
+
+	for hash, cur := range current {
+		prev, ok := previous[hash]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, DiffProduct{Hash: hash, Article: cur.Article, Brand: cur.Brand, Name: cur.Name})
+		case prev.Name != cur.Name:
+			report.Renamed = append(report.Renamed, DiffRename{Hash: hash, Article: cur.Article, Brand: cur.Brand, OldName: prev.Name, NewName: cur.Name})
+		}
+	}
+	for hash, prev := range previous {
+		if _, ok := current[hash]; !ok {
+			report.Removed = append(report.Removed, DiffProduct{Hash: hash, Article: prev.Article, Brand: prev.Brand, Name: prev.Name})
+		}
+	}
+
+	// Стабильный порядок для удобного просмотра и сравнения отчётов
+	sort.Slice(report.Added, func(i, j int) bool { return report.Added[i].Hash < report.Added[j].Hash })
+	sort.Slice(report.Removed, func(i, j int) bool { return report.Removed[i].Hash < report.Removed[j].Hash })
+	sort.Slice(report.Renamed, func(i, j int) bool { return report.Renamed[i].Hash < report.Renamed[j].Hash })
+
+	report.Summary = DiffSummary{
+		Previous: len(previous),
+		Current:  len(current),
+		Added:    len(report.Added),
+		Removed:  len(report.Removed),
+		Renamed:  len(report.Renamed),
+	}
+	return report
+}
+
+// Построение отчёта об изменениях относительно предыдущей выгрузки и запись его в JSON
+func writeDiffReport(db *gorm.DB, previousPath, reportPath string, batchSize int) error {
+	current, err := snapshotFromDB(db, batchSize)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать текущий каталог: %w", err)
+	}
+	previous, err := loadSnapshot(previousPath, current)
+	if err != nil {
+		return fmt.Errorf("не удалось загрузить предыдущую выгрузку: %w", err)
+	}
+
+	report := diffSnapshots(previous, current)
+	report.Previous = previousPath
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Отчёт об изменениях записан в %s: добавлено %d, удалено %d, переименовано %d\n",
+		reportPath, report.Summary.Added, report.Summary.Removed, report.Summary.Renamed)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Отчёт относительно SQL выгрузки при правилах хэша файлов (соль, регистр
+// артикула, stored_form): неизменные товары не попадают ни в добавленные,
+// ни в удалённые, переименование определяется по хэшу из каталога
+func TestDiffAgainstSQLWithHashRules(t *testing.T) {
+	setTestConfig(t, Config{StoredForm: StoredFormSettings{Article: StoredFormTrim, Brand: StoredFormTrim}})
+	db := newTestDB(t)
+	columns := ColumnSettings{Article: 1, Brand: 2, Name: 3}
+	supplierA := FileConfig{Filename: "a.xlsx", Columns: columns, HashSalt: "a"}
+	supplierB := FileConfig{Filename: "b.xlsx", Columns: columns, HashSalt: "b", CaseSensitiveArticle: true}
+	sheet := func(rows ...[]string) memoryWorkbook { return memoryWorkbook{{Name: "Лист1", Rows: rows}} }
+
+	importSheets(t, db, supplierA, sheet(
+		[]string{"AB-100", "Bosch", "Фильтр"},
+		[]string{"GDB-1550", "TRW", "Колодки"},
+	))
+	importSheets(t, db, supplierB, sheet(
+		[]string{"AB-100", "Bosch", "Фильтр масляный"},
+		[]string{"Xy-1", "NGK", "Свеча"},
+	))
+	previous := filepath.Join(t.TempDir(), "previous.sql")
+	if err := exportToSQLFile(db, previous, ExportOptions{BatchSize: defaultExportBatchSize}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Переименование у поставщика A, удаление и новый товар у поставщика B
+	importSheets(t, db, supplierA, sheet([]string{"GDB-1550", "TRW", "Колодки тормозные"}))
+	if err := db.Where("article = ?", "Xy-1").Delete(&Product{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	importSheets(t, db, supplierB, sheet([]string{"Xy-2", "NGK", "Свеча иридиевая"}))
+
+	reportPath := filepath.Join(t.TempDir(), "diff.json")
+	if err := writeDiffReport(db, previous, reportPath, defaultExportBatchSize); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report DiffReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	want := DiffSummary{Previous: 4, Current: 4, Added: 1, Removed: 1, Renamed: 1}
+	if report.Summary != want {
+		t.Fatalf("сводка %+v, ожидается %+v\nотчёт: %s", report.Summary, want, data)
+	}
+	if report.Added[0].Article != "Xy-2" || report.Removed[0].Article != "Xy-1" {
+		t.Errorf("добавлен %+v, удалён %+v", report.Added[0], report.Removed[0])
+	}
+	if r := report.Renamed[0]; r.Article != "GDB-1550" || r.OldName != "Колодки" || r.NewName != "Колодки тормозные" {
+		t.Errorf("переименование %+v", r)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Подстановка переменной окружения в значении конфигурации: ${VAR} или
+// ${VAR:-значение}, если переменная может быть не задана
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Подстановка переменных окружения в строку. Ссылка на незаданную переменную
+// без значения по умолчанию — ошибка: пустой пароль или путь не должен
+// появиться молча.
+func expandEnv(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var missing []string
+	expanded := envPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		match := envPlaceholder.FindStringSubmatch(placeholder)
+		if env, ok := os.LookupEnv(match[1]); ok {
+			return env
+		}
+		if match[2] != "" {
+			return match[3]
+		}
+		missing = append(missing, match[1])
+		return placeholder
+	})
+	if len(missing) > 0 {
+		return value, fmt.Errorf("не заданы переменные окружения: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// Подстановка переменных окружения во все строковые значения конфигурации
+// (включая вложенные структуры, списки и словари). Профили пропускаются:
+// подстановка в них выполняется при выборе профиля, чтобы переменные чужих
+// окружений (пароль prod на машине разработчика) не требовались.
+func expandConfigEnv(cfg *Config) error {
+	profiles := cfg.Profiles
+	cfg.Profiles = nil
+	defer func() { cfg.Profiles = profiles }()
+	return expandEnvValue(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func expandEnvValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := expandEnv(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.TrimPrefix(path, "."), err)
+		}
+		v.SetString(expanded)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return expandEnvValue(v.Elem(), path)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				name = t.Field(i).Name
+			}
+			if err := expandEnvValue(v.Field(i), path+"."+name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandEnvValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// Значения словаря неадресуемы: подстановка в копию и запись обратно
+		iter := v.MapRange()
+		for iter.Next() {
+			item := reflect.New(v.Type().Elem()).Elem()
+			item.Set(iter.Value())
+			if err := expandEnvValue(item, fmt.Sprintf("%s.%v", path, iter.Key())); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), item)
+		}
+	}
+	return nil
+}
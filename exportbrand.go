@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Выгрузка по брендам: для каждого бренда свой файл <база>_<бренд>.sql
+// (для output.sql — output_bosch.sql). Файлы пишутся параллельно, не более
+// workers одновременно. Очистка таблицы (export_clear) в таком файле удаляет
+// только записи его бренда. Ошибка выгрузки одного бренда не останавливает
+// остальные, ошибки возвращаются вместе.
+func exportByBrand(db *gorm.DB, outputPath string, opts ExportOptions, workers int) error {
+	var brands []string
+	if err := db.Model(&Product{}).Distinct("brand").Order("brand").Pluck("brand", &brands).Error; err != nil {
+		return fmt.Errorf("не удалось получить список брендов: %w", err)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+	var wgBrands sync.WaitGroup
+	var errsMu sync.Mutex
+	var errs []error
+
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	used := make(map[string]bool)
+	for _, brand := range brands {
+		path := fmt.Sprintf("%s_%s%s", base, uniqueBrandFileName(brand, used), ext)
+
+		brandOpts := opts
+		brandOpts.Brand = &brand
+
+		wgBrands.Add(1)
+		slots <- struct{}{}
+		go func(path string, opts ExportOptions) {
+			defer wgBrands.Done()
+			defer func() { <-slots }()
+			if err := exportToSQLFile(db, path, opts); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("бренд %s: %w", *opts.Brand, err))
+				errsMu.Unlock()
+			}
+		}(path, brandOpts)
+	}
+	wgBrands.Wait()
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	log.Printf("Выгружено брендов: %d\n", len(brands))
+	return nil
+}
+
+// Часть имени файла для бренда: строчные латинские буквы, цифры, '-' и '_',
+// остальные символы заменяются на '_'. Бренды, давшие одно имя, различаются суффиксом.
+func uniqueBrandFileName(brand string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, brand)
+	name = strings.Trim(name, "_")
+	if name == "" {
+		name = "nobrand"
+	}
+	name = truncateRunes(name, 100)
+
+	unique := name
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+	used[unique] = true
+	return unique
+}
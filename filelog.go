@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Режимы вывода журнала при параллельной обработке файлов
+const (
+	LogModePrefix = "prefix" // Каждая строка сразу выводится с именем файла (по умолчанию)
+	LogModeBuffer = "buffer" // Строки копятся и выводятся одним блоком по окончании файла
+)
+
+// Общая блокировка вывода, чтобы блоки разных файлов не перемешивались
+var logOutputMu sync.Mutex
+
+// Строка журнала файла: сообщение журнала (stderr) или обычный вывод (stdout)
+type logEntry struct {
+	stdout bool
+	text   string
+}
+
+// Журнал обработки одного файла
+type fileLogger struct {
+	mu      sync.Mutex // Защищает entries при параллельной обработке строк
+	mode    string
+	prefix  string
+	entries []logEntry
+}
+
+// Журнал для файла в режиме, заданном флагом -log-mode
+func newFileLogger(filePath string) *fileLogger {
+	return &fileLogger{mode: *flagLogMode, prefix: "[" + filePath + "] "}
+}
+
+// Сообщение журнала (аналог log.Printf)
+func (l *fileLogger) Printf(format string, args ...any) {
+	l.write(logEntry{text: fmt.Sprintf(format, args...)})
+}
+
+// Обычный вывод (аналог fmt.Println)
+func (l *fileLogger) Println(args ...any) {
+	l.write(logEntry{stdout: true, text: fmt.Sprintln(args...)})
+}
+
+func (l *fileLogger) write(entry logEntry) {
+	if l.mode == LogModeBuffer {
+		l.mu.Lock()
+		l.entries = append(l.entries, entry)
+		l.mu.Unlock()
+		return
+	}
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	l.emit(entry)
+}
+
+func (l *fileLogger) emit(entry logEntry) {
+	text := l.prefix + strings.TrimSuffix(entry.text, "\n")
+	if entry.stdout {
+		fmt.Fprintln(os.Stdout, text)
+		return
+	}
+	log.Println(text)
+}
+
+// Вывод накопленных строк одним блоком
+func (l *fileLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return
+	}
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	for _, entry := range l.entries {
+		l.emit(entry)
+	}
+	l.entries = nil
+}
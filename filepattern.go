@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Префикс регулярного выражения в имени файла или листа конфигурации:
+// "re:^price_\d{4}-\d{2}-\d{2}\.xlsx$"
+const filenameRegexPrefix = "re:"
+
+// Скомпилированные регулярные выражения имён файлов и листов (заполняется
+// при загрузке конфигурации, дальше только читается)
+var filenameRegexps = map[string]*regexp.Regexp{}
+
+// Имя в конфигурации — шаблон (glob или регулярное выражение), а не точное имя
+func isFilenamePattern(name string) bool {
+	return strings.HasPrefix(name, filenameRegexPrefix) || strings.ContainsAny(name, "*?[")
+}
+
+// Проверка шаблонов имён файлов и листов и компиляция регулярных выражений
+func compileFilenamePatterns(files []FileConfig) error {
+	for _, fc := range files {
+		if err := compileNamePattern(fc.Filename); err != nil {
+			return fmt.Errorf("filename: %w", err)
+		}
+		if fc.Sheets == nil {
+			continue
+		}
+		names := append(append([]string(nil), fc.Sheets.Include...), fc.Sheets.Exclude...)
+		for _, override := range fc.Sheets.Columns {
+			names = append(names, override.Sheet)
+		}
+		for _, name := range names {
+			if err := compileNamePattern(name); err != nil {
+				return fmt.Errorf("файл %s, листы: %w", fc.Filename, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Проверка одного шаблона
+func compileNamePattern(name string) error {
+	if expr, ok := strings.CutPrefix(name, filenameRegexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("некорректное регулярное выражение %q: %w", name, err)
+		}
+		filenameRegexps[name] = re
+		return nil
+	}
+	if isFilenamePattern(name) {
+		if _, err := filepath.Match(name, ""); err != nil {
+			return fmt.Errorf("некорректный шаблон %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Соответствие имени шаблону из конфигурации
+func matchFilenamePattern(pattern, fileName string) bool {
+	if re, ok := filenameRegexps[pattern]; ok {
+		return re.MatchString(fileName)
+	}
+	matched, _ := filepath.Match(pattern, fileName)
+	return matched
+}
+
+// Соответствие имени точному имени или шаблону из конфигурации
+func matchName(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	return isFilenamePattern(pattern) && matchFilenamePattern(pattern, name)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Чтение листа с вычислением формул (evaluate_formulas). Excel сохраняет
+// рядом с формулой её значение, и оно читается как обычно; пустое значение
+// бывает у файлов, собранных программами без пересчёта, — такие ячейки
+// вычисляются через excelize. Формулы ищутся в пределах размеров листа,
+// для этого лист загружается в память целиком.
+type formulaRowStream struct {
+	rowStream
+	file  *excelize.File
+	sheet string
+	cols  int // Число колонок листа по его размерам (dimension)
+	row   int // Номер текущей строки
+}
+
+func newFormulaRowStream(file *excelize.File, sheet string, stream rowStream) (rowStream, error) {
+	dimension, err := file.GetSheetDimension(sheet)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	cols := 0
+	if dimension != "" {
+		_, last, _ := strings.Cut(dimension, ":")
+		if last == "" {
+			last = dimension
+		}
+		cols, _, _ = excelize.CellNameToCoordinates(last)
+	}
+	return &formulaRowStream{rowStream: stream, file: file, sheet: sheet, cols: cols}, nil
+}
+
+func (s *formulaRowStream) Next() bool {
+	s.row++
+	return s.rowStream.Next()
+}
+
+func (s *formulaRowStream) Columns() ([]string, error) {
+	row, err := s.rowStream.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for col := 1; col <= max(len(row), s.cols); col++ {
+		if col <= len(row) && row[col-1] != "" {
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(col, s.row)
+		if err != nil {
+			return nil, err
+		}
+		value, err := evaluateFormula(s.file, s.sheet, cell)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		for len(row) < col {
+			row = append(row, "")
+		}
+		row[col-1] = value
+	}
+	return row, nil
+}
+
+// Значение формулы ячейки ("" — в ячейке нет формулы). Ошибка вычисления
+// (неподдерживаемая функция, ссылка на другой файл) записывается в журнал
+// и оставляет ячейку пустой.
+func evaluateFormula(file *excelize.File, sheet, cell string, opts ...excelize.Options) (string, error) {
+	formula, err := file.GetCellFormula(sheet, cell)
+	if err != nil || formula == "" {
+		return "", err
+	}
+	value, err := file.CalcCellValue(sheet, cell, opts...)
+	if err != nil {
+		log.Printf("Не удалось вычислить формулу %s!%s =%s: %v\n", sheet, cell, formula, err)
+		return "", nil
+	}
+	return value, nil
+}
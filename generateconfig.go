@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Подкоманда генерации конфигурации по файлам директории
+const commandGenerateConfig = "generate-config"
+
+// Строк листа в предпросмотре и в поиске строки заголовка
+const generatePreviewRows = 10
+
+// Слова заголовков, по которым угадываются колонки (в нижнем регистре)
+var headerHints = map[string][]string{
+	"brand":   {"бренд", "производитель", "марка", "brand", "manufacturer"},
+	"article": {"артикул", "код", "каталожный номер", "article", "sku", "part number"},
+	"name":    {"наименование", "название", "товар", "описание", "name", "description"},
+}
+
+// Запись конфигурации, созданная generate-config
+type generatedEntry struct {
+	Filename     string         `json:"filename"`
+	Columns      ColumnSettings `json:"columns"`
+	AutoSkipRows bool           `json:"auto_skip_rows,omitempty"`
+}
+
+// Генерация записей конфигурации для xlsx файлов директории, которых ещё нет
+// в outputPath. Для каждого файла показывается начало первого листа и
+// предлагаются колонки, угаданные по заголовку; оператор подтверждает их
+// (Enter) или вводит букву либо номер колонки. С assumeYes угаданные колонки
+// принимаются без вопросов. Файл уже настроен, если ему подходит запись
+// files по тем же правилам, что и при обработке (точное имя или шаблон).
+// Новые записи дописываются в конец files, остальной текст outputPath не меняется.
+func runGenerateConfig(dirPath, outputPath string, assumeYes bool) error {
+	if isYAMLConfig(outputPath) || isTOMLConfig(outputPath) {
+		return fmt.Errorf("generate-config пишет только JSON, а не %s", outputPath)
+	}
+
+	var document struct {
+		Files []FileConfig `json:"files"`
+	}
+	data, err := os.ReadFile(outputPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &document); err != nil {
+			return fmt.Errorf("ошибка парсинга %s: %w", outputPath, err)
+		}
+		if err := compileFilenamePatterns(document.Files); err != nil {
+			return fmt.Errorf("%s: %w", outputPath, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	configured := func(name string) bool {
+		for _, fc := range document.Files {
+			if !fc.external() && matchName(fc.Filename, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать директорию: %w", err)
+	}
+	input := bufio.NewReader(os.Stdin)
+	var entries []generatedEntry
+	for _, file := range files {
+		if file.IsDir() || !isInputFile(file.Name()) || isArchiveFile(file.Name()) {
+			continue
+		}
+		if configured(file.Name()) {
+			fmt.Printf("%s: уже есть в %s, пропущен\n", file.Name(), outputPath)
+			continue
+		}
+
+		entry, err := generateEntry(filepath.Join(dirPath, file.Name()), input, assumeYes)
+		if err != nil {
+			fmt.Printf("%s: пропущен: %v\n", file.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		fmt.Println("Новых файлов нет, конфигурация не изменена")
+		return nil
+	}
+
+	result, err := appendConfigFiles(data, entries)
+	if err != nil {
+		return fmt.Errorf("не удалось дописать %s: %w", outputPath, err)
+	}
+	if err := os.WriteFile(outputPath, result, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Добавлено записей: %d, конфигурация записана в %s\n", len(entries), outputPath)
+	return nil
+}
+
+// Вставка записей в конец массива files JSON документа data (пустой data —
+// новый документ). Текст документа до и после вставки сохраняется как есть:
+// порядок ключей, отступы и записанные вручную значения не меняются.
+func appendConfigFiles(data []byte, entries []generatedEntry) ([]byte, error) {
+	var items []string
+	for _, entry := range entries {
+		item, err := json.MarshalIndent(entry, "    ", "  ")
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, "    "+string(item))
+	}
+	list := strings.Join(items, ",\n")
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []byte("{\n  \"files\": [\n" + list + "\n  ]\n}\n"), nil
+	}
+
+	// Позиции в тексте: конец последней записи files (или открывающая скобка
+	// пустого массива) и закрывающая скобка документа
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("конфигурация не является объектом JSON")
+	}
+	keys, filesEnd, filesCount := 0, int64(-1), 0
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys++
+		if key != "files" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+			return nil, errors.New("files не является массивом")
+		}
+		filesEnd = dec.InputOffset()
+		for dec.More() {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			filesCount++
+			filesEnd = dec.InputOffset()
+		}
+		if _, err := dec.Token(); err != nil { // ]
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil { // }
+		return nil, err
+	}
+	documentEnd := dec.InputOffset() - 1
+
+	switch {
+	case filesEnd < 0:
+		// Массива files нет: добавляется последним ключом документа
+		at := documentEnd
+		for at > 0 && isJSONSpace(data[at-1]) {
+			at--
+		}
+		insert := "\n  \"files\": [\n" + list + "\n  ]\n"
+		if keys > 0 {
+			insert = "," + insert
+		}
+		return slices.Concat(data[:at], []byte(insert), data[documentEnd:]), nil
+	case filesCount == 0:
+		// Пустой массив: пробелы между скобками заменяются записями
+		end := filesEnd
+		for isJSONSpace(data[end]) {
+			end++
+		}
+		return slices.Concat(data[:filesEnd], []byte("\n"+list+"\n  "), data[end:]), nil
+	default:
+		return slices.Concat(data[:filesEnd], []byte(",\n"+list), data[filesEnd:]), nil
+	}
+}
+
+// Пробельный символ JSON
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// Запись конфигурации для одного файла
+func generateEntry(filePath string, input *bufio.Reader, assumeYes bool) (generatedEntry, error) {
+	f, err := openWorkbook(filePath, FileConfig{})
+	if err != nil {
+		return generatedEntry{}, err
+	}
+	defer f.Close()
+
+	sheets := f.Sheets()
+	if len(sheets) == 0 {
+		return generatedEntry{}, errors.New("нет листов")
+	}
+	rows, err := f.Rows(sheets[0])
+	if err != nil {
+		return generatedEntry{}, err
+	}
+	rows = rows[:min(len(rows), generatePreviewRows)]
+
+	fmt.Printf("\n%s, лист %s:\n", filepath.Base(filePath), sheets[0])
+	printPreview(rows)
+
+	headerRow, guess := guessColumns(rows)
+	entry := generatedEntry{Filename: filepath.Base(filePath), Columns: guess, AutoSkipRows: headerRow >= 0}
+	if assumeYes {
+		if guess.Article == 0 || guess.Name == 0 {
+			return generatedEntry{}, errors.New("не удалось угадать колонки артикула и названия")
+		}
+		return entry, nil
+	}
+
+	for _, col := range []struct {
+		label string
+		index *int
+	}{
+		{"бренд", &entry.Columns.Brand},
+		{"артикул", &entry.Columns.Article},
+		{"название", &entry.Columns.Name},
+	} {
+		if err := askColumn(input, col.label, col.index); err != nil {
+			return generatedEntry{}, err
+		}
+	}
+	if entry.Columns.Article == 0 || entry.Columns.Name == 0 {
+		return generatedEntry{}, errors.New("не заданы колонки артикула и названия")
+	}
+	return entry, nil
+}
+
+// Предпросмотр строк с буквами колонок
+func printPreview(rows [][]string) {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var header strings.Builder
+	header.WriteString("     ")
+	for col := 1; col <= width; col++ {
+		letter, _ := excelize.ColumnNumberToName(col)
+		fmt.Fprintf(&header, "| %-20s", letter)
+	}
+	fmt.Println(header.String())
+	for i, row := range rows {
+		var line strings.Builder
+		fmt.Fprintf(&line, "%4d ", i+1)
+		for col := 0; col < width; col++ {
+			value := ""
+			if col < len(row) {
+				value = truncateRunes(strings.TrimSpace(row[col]), 20)
+			}
+			fmt.Fprintf(&line, "| %-20s", value)
+		}
+		fmt.Println(line.String())
+	}
+}
+
+// Угадывание колонок по строке заголовка. Возвращает индекс строки заголовка
+// (-1, если не найдена) и найденные колонки.
+func guessColumns(rows [][]string) (int, ColumnSettings) {
+	for i, row := range rows {
+		var settings ColumnSettings
+		for col, cell := range row {
+			value := strings.ToLower(strings.TrimSpace(cell))
+			if value == "" {
+				continue
+			}
+			for key, hints := range headerHints {
+				for _, hint := range hints {
+					if !strings.Contains(value, hint) {
+						continue
+					}
+					switch {
+					case key == "brand" && settings.Brand == 0:
+						settings.Brand = col + 1
+					case key == "article" && settings.Article == 0:
+						settings.Article = col + 1
+					case key == "name" && settings.Name == 0:
+						settings.Name = col + 1
+					}
+				}
+			}
+		}
+		if settings.Article > 0 && settings.Name > 0 {
+			return i, settings
+		}
+	}
+	return -1, ColumnSettings{}
+}
+
+// Вопрос оператору о колонке: Enter — принять предложенную, "-" — колонки нет
+func askColumn(input *bufio.Reader, label string, index *int) error {
+	suggestion := "нет"
+	if *index > 0 {
+		suggestion, _ = excelize.ColumnNumberToName(*index)
+	}
+	for {
+		fmt.Printf("Колонка %s [%s]: ", label, suggestion)
+		line, err := input.ReadString('\n')
+		if err != nil && line == "" {
+			if errors.Is(err, io.EOF) {
+				return nil // Ввод закончился: принимаем предложенное
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch line {
+		case "":
+			return nil
+		case "-":
+			*index = 0
+			return nil
+		}
+		n, err := parseColumnRef(line)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		*index = n
+		return nil
+	}
+}
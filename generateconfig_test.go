@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Файлы, подходящие под шаблоны конфигурации, пропускаются; записи новых
+// дописываются в конец files без переформатирования остального текста
+func TestGenerateConfigAppends(t *testing.T) {
+	t.Cleanup(func() { delete(filenameRegexps, `re:^stock\d+\.csv$`) })
+	dir := t.TempDir()
+	header := "Артикул;Бренд;Наименование\nAB-100;Bosch;Фильтр\n"
+	for _, name := range []string{"prices_2024.csv", "stock1.csv", "exact.csv", "new.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(header), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	original := `{
+    "table_name": "products",
+    "name_policy": "first",
+    "files": [
+        {"filename": "prices_*.csv", "columns": {"article": 1, "brand": 2, "name": 3}},
+        {"filename": "re:^stock\\d+\\.csv$", "columns": {"article": 1, "brand": 2, "name": 3}},
+        {"filename": "exact.csv", "columns": {"article": 1, "brand": 2, "name": 3}}
+    ],
+    "batch_size": 1000
+}
+`
+	output := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(output, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGenerateConfig(dir, output, true); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := string(data)
+	lastEntry := strings.Index(original, `"exact.csv", "columns": {"article": 1, "brand": 2, "name": 3}}`) +
+		len(`"exact.csv", "columns": {"article": 1, "brand": 2, "name": 3}}`)
+	if !strings.HasPrefix(result, original[:lastEntry]) || !strings.HasSuffix(result, original[lastEntry:]) {
+		t.Fatalf("текст конфигурации изменён:\n%s", result)
+	}
+
+	var document struct {
+		Files []generatedEntry `json:"files"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("результат не разбирается: %v\n%s", err, result)
+	}
+	if len(document.Files) != 4 {
+		t.Fatalf("записей %d, ожидалось 4:\n%s", len(document.Files), result)
+	}
+	added := document.Files[3]
+	want := generatedEntry{Filename: "new.csv", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, AutoSkipRows: true}
+	if added.Filename != want.Filename || added.Columns.Article != 1 || added.Columns.Brand != 2 || added.Columns.Name != 3 || !added.AutoSkipRows {
+		t.Errorf("новая запись %+v, ожидалась %+v", added, want)
+	}
+
+	// Повторный запуск: все файлы настроены, файл не меняется
+	if err := runGenerateConfig(dir, output, true); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := os.ReadFile(output)
+	if string(again) != result {
+		t.Errorf("повторный запуск изменил конфигурацию:\n%s", again)
+	}
+}
+
+func TestAppendConfigFiles(t *testing.T) {
+	entries := []generatedEntry{{Filename: "new.xlsx", Columns: ColumnSettings{Article: 1, Name: 2}}}
+	entry := "    {\n      \"filename\": \"new.xlsx\",\n      \"columns\": {\n        \"brand\": 0,\n        \"article\": 1,\n        \"name\": 2\n      }\n    }"
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"новый файл", "", "{\n  \"files\": [\n" + entry + "\n  ]\n}\n"},
+		{"пустой объект", "{}", "{\n  \"files\": [\n" + entry + "\n  ]\n}"},
+		{"нет files", "{\"table_name\": \"t\"}\n", "{\"table_name\": \"t\",\n  \"files\": [\n" + entry + "\n  ]\n}\n"},
+		{"пустой files", "{\"files\": [ ], \"batch_size\": 10}", "{\"files\": [\n" + entry + "\n  ], \"batch_size\": 10}"},
+		{"files с записями", "{\"files\": [{\"filename\": \"a.xlsx\"}]}", "{\"files\": [{\"filename\": \"a.xlsx\"},\n" + entry + "]}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := appendConfigFiles([]byte(tt.data), entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("получено:\n%s\nожидается:\n%s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("результат не является JSON:\n%s", got)
+			}
+		})
+	}
+
+	if _, err := appendConfigFiles([]byte(`{"files": {}}`), entries); err == nil {
+		t.Error("нет ошибки для files не массивом")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Переменная окружения с путём к ключу сервисного аккаунта, если в записи не задан credentials
+const envGoogleCredentials = "GOOGLE_APPLICATION_CREDENTIALS"
+
+// Адреса Sheets API и область доступа только на чтение
+const (
+	googleSheetsAPI   = "https://sheets.googleapis.com/v4/spreadsheets/"
+	googleSheetsScope = "https://www.googleapis.com/auth/spreadsheets.readonly"
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+)
+
+// Таймаут одного запроса к Google API
+const googleRequestTimeout = time.Minute
+
+var googleClient = &http.Client{Timeout: googleRequestTimeout}
+
+// Таблица Google как источник прайс-листа вместо файла. Строки всех листов
+// (с учётом sheets) читаются через Sheets API и проходят ту же обработку,
+// что и строки xlsx; filename записи служит только именем в журналах.
+type GoogleSheetSource struct {
+	SpreadsheetID string `json:"spreadsheet_id"`        // Идентификатор из адреса таблицы: /spreadsheets/d/<id>/
+	Credentials   string `json:"credentials,omitempty"` // JSON ключ сервисного аккаунта (по умолчанию $GOOGLE_APPLICATION_CREDENTIALS)
+}
+
+// Имя источника в журналах, отчётах и манифесте
+func (s *GoogleSheetSource) sourcePath() string {
+	return "gsheet:" + s.SpreadsheetID
+}
+
+// Ответ Sheets API со значениями диапазонов
+type googleValueRanges struct {
+	ValueRanges []struct {
+		Values [][]any `json:"values"`
+	} `json:"valueRanges"`
+}
+
+// Чтение всех листов таблицы: текст ячеек — отображаемые значения
+// (FORMATTED_VALUE), RawCell — значения без форматирования, даты как серийные
+// числа, как в xlsx. Контрольная сумма считается по полученным значениям.
+func fetchGoogleSheet(source *GoogleSheetSource) (workbook, string, error) {
+	if source.SpreadsheetID == "" {
+		return nil, "", errors.New("не задан spreadsheet_id")
+	}
+	token, err := googleAccessToken(source.Credentials)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	base := googleSheetsAPI + url.PathEscape(source.SpreadsheetID)
+	if err := googleGet(base+"?fields=sheets.properties.title", token, &meta); err != nil {
+		return nil, "", err
+	}
+	if len(meta.Sheets) == 0 {
+		return nil, "", errors.New("таблица не содержит листов")
+	}
+
+	query := url.Values{}
+	for _, sheet := range meta.Sheets {
+		// Диапазон из имени листа целиком; кавычки в имени удваиваются
+		query.Add("ranges", "'"+strings.ReplaceAll(sheet.Properties.Title, "'", "''")+"'")
+	}
+	var formatted, raw googleValueRanges
+	query.Set("valueRenderOption", "FORMATTED_VALUE")
+	if err := googleGet(base+"/values:batchGet?"+query.Encode(), token, &formatted); err != nil {
+		return nil, "", err
+	}
+	query.Set("valueRenderOption", "UNFORMATTED_VALUE")
+	query.Set("dateTimeRenderOption", "SERIAL_NUMBER")
+	if err := googleGet(base+"/values:batchGet?"+query.Encode(), token, &raw); err != nil {
+		return nil, "", err
+	}
+	if len(formatted.ValueRanges) != len(meta.Sheets) || len(raw.ValueRanges) != len(meta.Sheets) {
+		return nil, "", errors.New("Sheets API вернул не все листы")
+	}
+
+	hash := sha256.New()
+	book := make(memoryWorkbook, len(meta.Sheets))
+	for i, sheet := range meta.Sheets {
+		book[i] = memorySheet{
+			Name: sheet.Properties.Title,
+			Rows: googleCells(formatted.ValueRanges[i].Values),
+			Raw:  googleCells(raw.ValueRanges[i].Values),
+		}
+		json.NewEncoder(hash).Encode(book[i])
+	}
+	return book, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Значения ячеек ответа в виде текста; логические значения — "TRUE"/"FALSE",
+// как в canonical_cell_types
+func googleCells(values [][]any) [][]string {
+	rows := make([][]string, len(values))
+	for i, row := range values {
+		cells := make([]string, len(row))
+		for j, value := range row {
+			switch v := value.(type) {
+			case string:
+				cells[j] = v
+			case float64:
+				cells[j] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				cells[j] = strings.ToUpper(strconv.FormatBool(v))
+			}
+		}
+		rows[i] = cells
+	}
+	return trimRowTails(rows)
+}
+
+// GET запрос к Google API с разбором JSON ответа
+func googleGet(address, token string, target any) error {
+	req, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := googleClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Sheets API: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// Ключ сервисного аккаунта (файл, выданный Google Cloud Console)
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Токен доступа, полученный по ключу сервисного аккаунта
+type googleToken struct {
+	value   string
+	expires time.Time
+}
+
+// Токены по пути к ключу: таблицы одного аккаунта не запрашивают токен заново
+var (
+	googleTokensMu sync.Mutex
+	googleTokens   = make(map[string]googleToken)
+)
+
+// Токен доступа для ключа сервисного аккаунта (OAuth 2.0, JWT с подписью RS256).
+// Токен переиспользуется, пока до его истечения больше минуты.
+func googleAccessToken(credentialsPath string) (string, error) {
+	if credentialsPath == "" {
+		credentialsPath = os.Getenv(envGoogleCredentials)
+	}
+	if credentialsPath == "" {
+		return "", fmt.Errorf("не задан ключ сервисного аккаунта: credentials или %s", envGoogleCredentials)
+	}
+
+	googleTokensMu.Lock()
+	defer googleTokensMu.Unlock()
+	if token, ok := googleTokens[credentialsPath]; ok && time.Until(token.expires) > time.Minute {
+		return token.value, nil
+	}
+
+	data, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return "", err
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return "", fmt.Errorf("ключ сервисного аккаунта %s: %w", credentialsPath, err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURL
+	}
+	assertion, err := googleJWT(account, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("ключ сервисного аккаунта %s: %w", credentialsPath, err)
+	}
+
+	resp, err := googleClient.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("не удалось получить токен Google: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	googleTokens[credentialsPath] = googleToken{
+		value:   result.AccessToken,
+		expires: time.Now().Add(time.Duration(result.ExpiresIn) * time.Second),
+	}
+	return result.AccessToken, nil
+}
+
+// Подписанное утверждение JWT для обмена на токен доступа
+func googleJWT(account googleServiceAccount, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", errors.New("private_key не содержит ключа в формате PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("private_key не является ключом RSA")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": googleSheetsScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
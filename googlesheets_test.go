@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Ключ в формате PEM (PKCS #8), как в JSON ключе сервисного аккаунта
+func pkcs8PEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// Проверка подписи RS256 и разбор заголовка и утверждений JWT
+func verifyGoogleJWT(t *testing.T, token string, public *rsa.PublicKey) (header map[string]string, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT из %d частей: %q", len(parts), token)
+	}
+	encoding := base64.RawURLEncoding
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("подпись JWT не прошла проверку: %v", err)
+	}
+
+	for i, target := range []any{&header, &claims} {
+		data, err := encoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return header, claims
+}
+
+func TestGoogleJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := googleServiceAccount{
+		ClientEmail: "importer@project.iam.gserviceaccount.com",
+		PrivateKey:  pkcs8PEM(t, key),
+		TokenURI:    googleTokenURL,
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	token, err := googleJWT(account, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, claims := verifyGoogleJWT(t, token, &key.PublicKey)
+
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("заголовок JWT = %v", header)
+	}
+	want := map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": googleSheetsScope,
+		"aud":   googleTokenURL,
+		"iat":   float64(now.Unix()),
+		"exp":   float64(now.Add(time.Hour).Unix()),
+	}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("%s = %v, ожидается %v", name, claims[name], value)
+		}
+	}
+	if len(claims) != len(want) {
+		t.Errorf("лишние утверждения JWT: %v", claims)
+	}
+
+	// Подпись другим ключом не проходит проверку
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	parts := strings.Split(token, ".")
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if rsa.VerifyPKCS1v15(&other.PublicKey, crypto.SHA256, digest[:], signature) == nil {
+		t.Error("подпись JWT прошла проверку чужим ключом")
+	}
+}
+
+func TestGoogleJWTInvalidKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"не PEM", "not a key", "PEM"},
+		{"не RSA", pkcs8PEM(t, ecKey), "RSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := googleJWT(googleServiceAccount{PrivateKey: tt.key}, time.Now())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидается упоминание %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// Обмен JWT на токен доступа: сервер токенов получает подписанное утверждение,
+// повторный запрос с тем же ключом берёт токен из кэша
+func TestGoogleAccessToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", r.Form.Get("grant_type"))
+		}
+		_, claims := verifyGoogleJWT(t, r.Form.Get("assertion"), &key.PublicKey)
+		if claims["aud"] != server.URL {
+			t.Errorf("aud = %v, ожидается %s", claims["aud"], server.URL)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "ya29.test", "expires_in": 3600})
+	}))
+	defer server.Close()
+
+	credentials := filepath.Join(t.TempDir(), "key.json")
+	data, _ := json.Marshal(googleServiceAccount{
+		ClientEmail: "importer@project.iam.gserviceaccount.com",
+		PrivateKey:  pkcs8PEM(t, key),
+		TokenURI:    server.URL,
+	})
+	if err := os.WriteFile(credentials, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		googleTokensMu.Lock()
+		delete(googleTokens, credentials)
+		googleTokensMu.Unlock()
+	})
+
+	for range 2 {
+		token, err := googleAccessToken(credentials)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "ya29.test" {
+			t.Errorf("токен = %q", token)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("запросов к серверу токенов: %d, ожидается 1", got)
+	}
+}
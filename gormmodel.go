@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Генерация описания модели для другого сервиса: Go структура Product с тегами gorm,
+// включающая дополнительные колонки из конфигурации. Результат — готовый к
+// AutoMigrate фрагмент файла пакета package, отформатированный gofmt.
+func writeGORMModel(path, pkg string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Код сгенерирован XlsxToSQL %s по модели импорта. Не редактировать.\n\n", version)
+	fmt.Fprintf(&b, "package %s\n\nimport \"time\"\n\n", pkg)
+	b.WriteString("type Product struct {\n")
+
+	t := reflect.TypeOf(Product{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fmt.Fprintf(&b, "\t%s %s `gorm:%q`\n", field.Name, field.Type.String(), field.Tag.Get("gorm"))
+	}
+	for _, col := range config.CustomColumns {
+		tag := fmt.Sprintf("column:%s;type:%s", col.Name, strings.ToLower(col.sqlType()))
+		fmt.Fprintf(&b, "\t%s %s `gorm:%q json:%q`\n", goFieldName(col.Name), goTypeForSQL(col.sqlType()), tag, col.Name+",omitempty")
+	}
+
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "func (Product) TableName() string {\n\treturn %q\n}\n", Product{}.TableName())
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return fmt.Errorf("не удалось отформатировать модель: %w", err)
+	}
+	return os.WriteFile(path, source, 0644)
+}
+
+// Имя поля Go для колонки: category_id → CategoryID
+func goFieldName(column string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(column, "_") {
+		if part == "" {
+			continue
+		}
+		switch part {
+		case "id", "url", "ean", "sku":
+			b.WriteString(strings.ToUpper(part))
+		default:
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	if b.Len() == 0 || (b.String()[0] >= '0' && b.String()[0] <= '9') {
+		return "Column" + b.String()
+	}
+	return b.String()
+}
+
+// Тип поля Go для SQL типа дополнительной колонки. Колонки необязательны
+// (NULL), поэтому все типы — указатели.
+func goTypeForSQL(sqlType string) string {
+	name := strings.ToUpper(sqlType)
+	if i := strings.IndexAny(name, " ("); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT":
+		return "*int64"
+	case "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE", "REAL":
+		return "*float64"
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return "*time.Time"
+	case "BOOL", "BOOLEAN":
+		return "*bool"
+	default:
+		return "*string"
+	}
+}
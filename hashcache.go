@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Кэш хэшей таблицы товаров (хэш → id) между запусками.
+//
+// Кэш нужен только инкрементальным запускам с -resume: без него таблица очищается
+// перед импортом, отпечаток пустой таблицы с сохранённым не совпадёт, и кэш всё
+// равно пришлось бы строить заново. Поэтому -hash-cache без -resume — ошибка.
+//
+// Формат файла — JSON:
+//
+//	{
+//	  "version": 1,
+//	  "fingerprint": {"rows": 120345, "max_id": 120400, "max_updated_at": "2026-10-14T10:00:00Z"},
+//	  "hashes": {"<hash>": <id>, ...}
+//	}
+//
+// Отпечаток (fingerprint) снимается с таблицы при сохранении кэша. При загрузке он
+// сравнивается с текущим состоянием таблицы: если число записей, максимальный id или
+// максимальный updated_at отличаются, таблицу меняли в обход импорта, и кэш строится
+// заново полным чтением хэшей из базы. Изменения, не затрагивающие ни одно из этих
+// значений (например, правка записи через UpdateColumns без сдвига updated_at),
+// отпечаток не замечает — кэш ускоряет только поиск по хэшу, и ошибка в нём
+// приводит к неудачной вставке дубликата, которая попадёт в журнал.
+const hashCacheVersion = 1
+
+// Отпечаток состояния таблицы для проверки актуальности кэша
+type tableFingerprint struct {
+	Rows         int64     `json:"rows"`
+	MaxID        uint      `json:"max_id"`
+	MaxUpdatedAt time.Time `json:"max_updated_at"`
+}
+
+type hashCacheFile struct {
+	Version     int              `json:"version"`
+	Fingerprint tableFingerprint `json:"fingerprint"`
+	Hashes      map[string]uint  `json:"hashes"`
+}
+
+// Кэш хэшей, используемый при поиске записей. Пока кэш не загружен, поиск идёт в базу.
+type HashCache struct {
+	mu      sync.Mutex
+	enabled bool
+	ids     map[string]uint
+}
+
+var hashCache = &HashCache{}
+
+// Загрузка кэша из файла; при отсутствии файла или устаревшем отпечатке — чтение хэшей из базы.
+// Возвращает true, если кэш взят из файла.
+func loadHashCache(db *gorm.DB, path string, batchSize, workers int) (bool, error) {
+	current, err := fingerprintTable(db)
+	if err != nil {
+		return false, err
+	}
+
+	var cached hashCacheFile
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return false, fmt.Errorf("повреждённый кэш хэшей %s: %w", path, err)
+		}
+	}
+
+	hashCache.mu.Lock()
+	defer hashCache.mu.Unlock()
+
+	if cached.Version == hashCacheVersion && cached.Hashes != nil && sameFingerprint(cached.Fingerprint, current) {
+		hashCache.ids = cached.Hashes
+		hashCache.enabled = true
+		return true, nil
+	}
+
+	ids, err := scanHashes(db, batchSize, workers)
+	if err != nil {
+		return false, err
+	}
+	hashCache.ids = ids
+	hashCache.enabled = true
+	return false, nil
+}
+
+// Сохранение кэша вместе с отпечатком таблицы на момент окончания запуска
+func saveHashCache(db *gorm.DB, path string) error {
+	current, err := fingerprintTable(db)
+	if err != nil {
+		return err
+	}
+
+	hashCache.mu.Lock()
+	data, err := json.Marshal(hashCacheFile{Version: hashCacheVersion, Fingerprint: current, Hashes: hashCache.ids})
+	hashCache.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Запись через временный файл, чтобы прерывание не оставило битый кэш
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// id записи с данным хэшем. known == false, если кэш не загружен и нужно искать в базе.
+func (c *HashCache) lookup(hash string) (id uint, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return 0, false
+	}
+	return c.ids[hash], true
+}
+
+// Запоминание новой записи
+func (c *HashCache) add(hash string, id uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enabled {
+		c.ids[hash] = id
+	}
+}
+
+// Поиск записи по хэшу: через кэш, если он загружен, иначе запросом по хэшу
+func findByHash(db *gorm.DB, hash string, dest *Product) error {
+	id, known := hashCache.lookup(hash)
+	if !known {
+		return db.Where("hash = ?", hash).First(dest).Error
+	}
+	if id == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return db.First(dest, id).Error
+}
+
+func fingerprintTable(db *gorm.DB) (tableFingerprint, error) {
+	var row struct {
+		Total        int64
+		MaxID        *uint
+		MaxUpdatedAt *time.Time
+	}
+	err := db.Model(&Product{}).
+		Select("COUNT(*) AS total, MAX(id) AS max_id, MAX(updated_at) AS max_updated_at").
+		Scan(&row).Error
+	if err != nil {
+		return tableFingerprint{}, err
+	}
+
+	fp := tableFingerprint{Rows: row.Total}
+	if row.MaxID != nil {
+		fp.MaxID = *row.MaxID
+	}
+	if row.MaxUpdatedAt != nil {
+		fp.MaxUpdatedAt = *row.MaxUpdatedAt
+	}
+	return fp, nil
+}
+
+func sameFingerprint(a, b tableFingerprint) bool {
+	return a.Rows == b.Rows && a.MaxID == b.MaxID && a.MaxUpdatedAt.Equal(b.MaxUpdatedAt)
+}
+
+// Полное чтение хэшей из таблицы. Диапазон id делится на workers непересекающихся
+// отрезков, каждый читается своей горутиной постранично по id (keyset-пагинация),
+// результаты сливаются в общую карту под мьютексом.
+func scanHashes(db *gorm.DB, batchSize, workers int) (map[string]uint, error) {
+	var bounds struct {
+		MinID *uint
+		MaxID *uint
+	}
+	if err := db.Model(&Product{}).Select("MIN(id) AS min_id, MAX(id) AS max_id").Scan(&bounds).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint)
+	if bounds.MinID == nil || bounds.MaxID == nil {
+		return ids, nil // Таблица пуста
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	lo, hi := *bounds.MinID-1, *bounds.MaxID
+	span := (hi - lo + uint(workers) - 1) / uint(workers)
+
+	var (
+		mergeMu  sync.Mutex
+		wgScan   sync.WaitGroup
+		firstErr error
+	)
+	for start := lo; start < hi; start += span {
+		end := min(start+span, hi)
+		wgScan.Add(1)
+		go func(start, end uint) {
+			defer wgScan.Done()
+			local, err := scanHashRange(db, start, end, batchSize)
+			mergeMu.Lock()
+			defer mergeMu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for hash, id := range local {
+				ids[hash] = id
+			}
+		}(start, end)
+	}
+	wgScan.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return ids, nil
+}
+
+// Чтение хэшей записей с id в полуинтервале (after, upTo]
+func scanHashRange(db *gorm.DB, after, upTo uint, batchSize int) (map[string]uint, error) {
+	ids := make(map[string]uint)
+	for {
+		var page []Product
+		err := db.Select("id", "hash").Where("id > ? AND id <= ?", after, upTo).Order("id").Limit(batchSize).Find(&page).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return ids, nil
+		}
+		for _, p := range page {
+			ids[p.Hash] = p.ID
+		}
+		after = page[len(page)-1].ID
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Таблица из n записей с хэшами h0..h{n-1}
+func seedProducts(tb testing.TB, n int) *gorm.DB {
+	tb.Helper()
+	db := newTestDB(tb)
+	products := make([]Product, n)
+	for i := range products {
+		products[i] = Product{Article: fmt.Sprintf("ab%d", i), Brand: "bosch", Name: "Фильтр", Hash: fmt.Sprintf("h%d", i)}
+	}
+	if err := db.CreateInBatches(products, 1000).Error; err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// Все хэши читаются при любом числе горутин, в том числе большем числа записей
+func TestScanHashes(t *testing.T) {
+	db := seedProducts(t, 250)
+	for _, workers := range []int{0, 1, 3, 8, 500} {
+		ids, err := scanHashes(db, 40, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 250 {
+			t.Errorf("workers=%d: прочитано хэшей %d, ожидалось 250", workers, len(ids))
+		}
+		if ids["h0"] == 0 || ids["h249"] == 0 || ids["h0"] == ids["h249"] {
+			t.Errorf("workers=%d: id хэшей h0=%d, h249=%d", workers, ids["h0"], ids["h249"])
+		}
+	}
+
+	empty := newTestDB(t)
+	if ids, err := scanHashes(empty, 40, 4); err != nil || len(ids) != 0 {
+		t.Errorf("пустая таблица: %d хэшей, ошибка %v", len(ids), err)
+	}
+}
+
+// Ускорение предзагрузки хэшей от числа горутин (-hash-preload-workers)
+func BenchmarkScanHashes(b *testing.B) {
+	const rows = 20000
+	db := seedProducts(b, rows)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for range b.N {
+				ids, err := scanHashes(db, 1000, workers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(ids) != rows {
+					b.Fatalf("прочитано хэшей %d, ожидалось %d", len(ids), rows)
+				}
+			}
+		})
+	}
+}
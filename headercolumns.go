@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Буква колонки Excel в настройках колонок
+var columnLetter = regexp.MustCompile(`^[A-Z]{1,3}$`)
+
+// Префикс названия заголовка, которое иначе было бы принято за букву колонки
+const columnHeaderPrefix = "header:"
+
+// Разбор настроек колонок: каждая колонка задаётся номером (с единицы), буквой
+// колонки Excel ("A", "AC") или названием заголовка, например
+// {"brand": "Бренд", "article": "B", "name": 3}. Одна–три заглавные латинские
+// буквы считаются буквой колонки; заголовок, похожий на букву (например "EAN"),
+// записывается с префиксом "header:" ("header:EAN"). Названия собираются
+// в HeaderNames и сопоставляются со строкой заголовка листа.
+func (c *ColumnSettings) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var settings ColumnSettings
+	targets := map[string]struct {
+		index  *int
+		header *string
+	}{
+		"brand":   {&settings.Brand, &settings.HeaderNames.Brand},
+		"article": {&settings.Article, &settings.HeaderNames.Article},
+		"name":    {&settings.Name, &settings.HeaderNames.Name},
+		"date":    {&settings.Date, &settings.HeaderNames.Date},
+		"ean":     {&settings.EAN, &settings.HeaderNames.EAN},
+		"price":   {&settings.Price, &settings.HeaderNames.Price},
+	}
+	for key, raw := range fields {
+		target, ok := targets[strings.ToLower(key)]
+		if !ok {
+			continue // Неизвестные ключи игнорируются, как и в остальной конфигурации
+		}
+		if err := json.Unmarshal(raw, target.index); err == nil {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("колонка %s: ожидается номер, буква или название заголовка, получено %s", key, raw)
+		}
+		if header, ok := strings.CutPrefix(value, columnHeaderPrefix); ok {
+			*target.header = header
+			continue
+		}
+		if columnLetter.MatchString(value) {
+			index, err := excelize.ColumnNameToNumber(value)
+			if err != nil {
+				return fmt.Errorf("колонка %s: %w", key, err)
+			}
+			*target.index = index
+			continue
+		}
+		*target.header = value
+	}
+	*c = settings
+	return nil
+}
+
+// Не задано ни одного заголовка
+func (h HeaderSettings) empty() bool {
+	return strings.TrimSpace(h.Brand) == "" && strings.TrimSpace(h.Article) == "" &&
+		strings.TrimSpace(h.Name) == "" && strings.TrimSpace(h.Date) == "" &&
+		strings.TrimSpace(h.EAN) == "" && strings.TrimSpace(h.Price) == ""
+}
+
+// Заголовки для поиска колонок файла: блок headers, иначе названия из columns.
+// nil — колонки заданы только номерами.
+func (fc FileConfig) headerSettings() *HeaderSettings {
+	if fc.Headers != nil {
+		return fc.Headers
+	}
+	if fc.Columns.HeaderNames.empty() {
+		return nil
+	}
+	headers := fc.Columns.HeaderNames
+	return &headers
+}
+
+// Колонки, найденные по заголовку, поверх заданных номерами
+func (c ColumnSettings) merge(resolved ColumnSettings) ColumnSettings {
+	pick := func(index, found int) int {
+		if found > 0 {
+			return found
+		}
+		return index
+	}
+	return ColumnSettings{
+		Brand:   pick(c.Brand, resolved.Brand),
+		Article: pick(c.Article, resolved.Article),
+		Name:    pick(c.Name, resolved.Name),
+		Date:    pick(c.Date, resolved.Date),
+		EAN:     pick(c.EAN, resolved.EAN),
+		Price:   pick(c.Price, resolved.Price),
+	}
+}
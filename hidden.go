@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/xuri/excelize/v2"
+)
+
+// Чтение листа xlsx без скрытого (skip_hidden): скрытые строки возвращаются
+// пустыми и запоминаются, чтобы обработка пропустила их без отклонения,
+// ячейки скрытых колонок считаются пустыми — номера колонок не сдвигаются.
+// Видимость колонок хранится в описании листа, поэтому при первой проверке
+// лист загружается в память целиком.
+type hiddenRowStream struct {
+	rowStream
+	rows    *excelize.Rows
+	file    *excelize.File
+	sheet   string
+	row     int          // Номер текущей строки
+	cols    map[int]bool // Видимость уже проверенных колонок: true — скрыта
+	skipped map[int]bool // Номера пропущенных скрытых строк
+}
+
+func newHiddenRowStream(file *excelize.File, sheet string, rows *excelize.Rows, stream rowStream) *hiddenRowStream {
+	return &hiddenRowStream{
+		rowStream: stream,
+		rows:      rows,
+		file:      file,
+		sheet:     sheet,
+		cols:      make(map[int]bool),
+		skipped:   make(map[int]bool),
+	}
+}
+
+func (s *hiddenRowStream) Next() bool {
+	s.row++
+	return s.rowStream.Next()
+}
+
+func (s *hiddenRowStream) Columns() ([]string, error) {
+	row, err := s.rowStream.Columns()
+	if err != nil || len(row) == 0 {
+		return row, err
+	}
+	if s.rows.GetRowOpts().Hidden {
+		s.skipped[s.row] = true
+		return nil, nil
+	}
+	for col := range row {
+		hidden, err := s.hiddenColumn(col + 1)
+		if err != nil {
+			return nil, err
+		}
+		if hidden {
+			row[col] = ""
+		}
+	}
+	return row, nil
+}
+
+// Колонка скрыта
+func (s *hiddenRowStream) hiddenColumn(col int) (bool, error) {
+	if hidden, ok := s.cols[col]; ok {
+		return hidden, nil
+	}
+	name, err := excelize.ColumnNumberToName(col)
+	if err != nil {
+		return false, err
+	}
+	visible, err := s.file.GetColVisible(s.sheet, name)
+	if err != nil {
+		return false, err
+	}
+	s.cols[col] = !visible
+	return !visible, nil
+}
+
+// Строка пропущена как скрытая. Вызывается обработчиками строк уже
+// прочитанной порции, когда чтение листа стоит.
+func (s *hiddenRowStream) hiddenRow(row int) bool {
+	return s.skipped[row]
+}
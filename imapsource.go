@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset" // Имена вложений в koi8-r и windows-1251
+	"github.com/emersion/go-message/mail"
+)
+
+// Почтовый ящик, из которого -imap забирает прайс-листы поставщиков.
+// Пароль лучше задавать через ${VAR}.
+type IMAPSettings struct {
+	Address  string   `json:"address,omitempty"`  // Сервер IMAP, например "imap.example.com:993"
+	Username string   `json:"username,omitempty"` // Имя пользователя
+	Password string   `json:"password,omitempty"` // Пароль
+	Mailbox  string   `json:"mailbox,omitempty"`  // Папка (по умолчанию INBOX)
+	StartTLS bool     `json:"starttls,omitempty"` // Подключение без TLS с командой STARTTLS (порт 143) вместо TLS (порт 993)
+	Senders  []string `json:"senders,omitempty"`  // Адреса поставщиков; письма от других адресов не трогаются
+}
+
+// Сохранение вложений непрочитанных писем от поставщиков в директорию
+// прайс-листов. Сохраняются вложения поддерживаемых форматов; файл с тем же
+// именем заменяется (письма разбираются от старых к новым, остаётся последнее).
+// Письмо, все вложения которого сохранены, помечается прочитанным; письмо без
+// прайс-листов остаётся непрочитанным. Возвращает число сохранённых файлов.
+func fetchMailAttachments(settings IMAPSettings, dir string) (int, error) {
+	if settings.Address == "" {
+		return 0, errors.New("не задан imap.address")
+	}
+	if len(settings.Senders) == 0 {
+		return 0, errors.New("не заданы imap.senders")
+	}
+	mailbox := settings.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+
+	c, err := dialIMAP(settings)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось подключиться к %s: %w", settings.Address, err)
+	}
+	defer c.Logout()
+	if err := c.Login(settings.Username, settings.Password); err != nil {
+		return 0, fmt.Errorf("не удалось войти в %s: %w", settings.Address, err)
+	}
+	if _, err := c.Select(mailbox, false); err != nil {
+		return 0, fmt.Errorf("папка %s: %w", mailbox, err)
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	unseen, err := c.UidSearch(criteria)
+	if err != nil {
+		return 0, err
+	}
+	if len(unseen) == 0 {
+		return 0, nil
+	}
+
+	// Сначала конверты: тела писем скачиваются только у писем поставщиков
+	uids, err := supplierMessages(c, unseen, settings.Senders)
+	if err != nil || len(uids) == 0 {
+		return 0, err
+	}
+	slices.Sort(uids)
+
+	saved := 0
+	section := &imap.BodySectionName{Peek: true} // Без пометки прочитанным до сохранения вложений
+	for _, uid := range uids {
+		set := new(imap.SeqSet)
+		set.AddNum(uid)
+		messages := make(chan *imap.Message, 1)
+		done := make(chan error, 1)
+		go func() { done <- c.UidFetch(set, []imap.FetchItem{section.FetchItem()}, messages) }()
+
+		var names []string
+		var saveErr error
+		for msg := range messages {
+			if body := msg.GetBody(section); body != nil {
+				names, saveErr = saveMailAttachments(body, dir)
+			}
+		}
+		if err := <-done; err != nil {
+			return saved, err
+		}
+		if saveErr != nil {
+			log.Printf("Письмо %d: не удалось сохранить вложения: %v\n", uid, saveErr)
+			continue
+		}
+		if len(names) == 0 {
+			log.Printf("Письмо %d не содержит прайс-листов и оставлено непрочитанным\n", uid)
+			continue
+		}
+		saved += len(names)
+		for _, name := range names {
+			fmt.Println("Сохранено вложение ", filepath.Join(dir, name))
+		}
+
+		flags := []interface{}{imap.SeenFlag}
+		if err := c.UidStore(set, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+			log.Printf("Письмо %d не помечено прочитанным: %v\n", uid, err)
+		}
+	}
+	return saved, nil
+}
+
+// Подключение к серверу: TLS или STARTTLS
+func dialIMAP(settings IMAPSettings) (*client.Client, error) {
+	host, _, err := net.SplitHostPort(settings.Address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	if !settings.StartTLS {
+		return client.DialTLS(settings.Address, tlsConfig)
+	}
+	c, err := client.Dial(settings.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.StartTLS(tlsConfig); err != nil {
+		c.Logout()
+		return nil, err
+	}
+	return c, nil
+}
+
+// UID писем, отправитель которых есть в senders (без учёта регистра)
+func supplierMessages(c *client.Client, uids []uint32, senders []string) ([]uint32, error) {
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() { done <- c.UidFetch(set, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope}, messages) }()
+
+	var matched []uint32
+	for msg := range messages {
+		if msg.Envelope == nil {
+			continue
+		}
+		for _, from := range msg.Envelope.From {
+			if slices.ContainsFunc(senders, func(sender string) bool {
+				return strings.EqualFold(strings.TrimSpace(sender), from.Address())
+			}) {
+				matched = append(matched, msg.Uid)
+				break
+			}
+		}
+	}
+	return matched, <-done
+}
+
+// Запись вложений поддерживаемых форматов в dir. Имя файла — имя вложения
+// без каталогов. Возвращает имена сохранённых файлов.
+func saveMailAttachments(body io.Reader, dir string) ([]string, error) {
+	reader, err := mail.CreateReader(body)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		header, ok := part.Header.(*mail.AttachmentHeader)
+		if !ok {
+			continue
+		}
+		filename, err := header.Filename()
+		if err != nil {
+			return names, err
+		}
+		name := filepath.Base(filepath.FromSlash(strings.ReplaceAll(filename, `\`, "/")))
+		if !isInputFile(name) || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		file, err := createAtomic(filepath.Join(dir, name), defaultOutputFileMode, false)
+		if err != nil {
+			return names, err
+		}
+		if _, err := io.Copy(file, part.Body); err != nil {
+			file.abort()
+			return names, err
+		}
+		if err := file.commit(); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+}
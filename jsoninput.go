@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Расширения файлов JSON: массив объектов или по объекту в строке (NDJSON)
+var jsonExtensions = []string{".json", ".jsonl", ".ndjson"}
+
+// Чтение JSON файла. Каждый объект — строка прайс-листа, а первая строка листа —
+// имена полей, поэтому колонки задаются названиями полей, как заголовками:
+// {"brand": "vendor", "article": "sku", "name": "title"}. Поля вложенных
+// объектов и элементы массивов называются через точку ("price.value", "images.0").
+type JSONSettings struct {
+	// Поля в порядке колонок (для custom и колонок, заданных номером);
+	// пусто — все поля в порядке их появления в файле
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Объект фида (JSON, YML) в плоском виде: поля в порядке появления и их текст
+type flatObject struct {
+	fields []string
+	values map[string]string
+}
+
+// JSON файл как прайс-лист (см. feedWorkbook)
+func openJSONWorkbook(filePath string, settings *JSONSettings) (workbook, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := parseJSONObjects(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	if settings != nil {
+		fields = settings.Fields
+	}
+	return feedWorkbook(filePath, objects, fields), nil
+}
+
+// Объекты фида как прайс-лист с одним листом, названным по имени файла без
+// расширения (как у CSV). Первая строка — имена полей: заданные fields, иначе
+// все поля в порядке их появления.
+func feedWorkbook(filePath string, objects []flatObject, fields []string) workbook {
+	if len(fields) == 0 {
+		seen := make(map[string]bool)
+		for _, object := range objects {
+			for _, field := range object.fields {
+				if !seen[field] {
+					seen[field] = true
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+
+	rows := make([][]string, 0, len(objects)+1)
+	rows = append(rows, fields)
+	for _, object := range objects {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = object.values[field]
+		}
+		rows = append(rows, row)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return memoryWorkbook{{Name: name, Rows: trimRowTails(rows)}}
+}
+
+// Объекты файла: массив объектов или объекты, разделённые переводами строк (NDJSON)
+func parseJSONObjects(data []byte) ([]flatObject, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	array := bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))
+	if array {
+		decoder.Token() // Открывающая скобка массива
+	}
+
+	var objects []flatObject
+	for decoder.More() {
+		object := flatObject{values: make(map[string]string)}
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("объект %d: %w", len(objects)+1, err)
+		}
+		if token != json.Delim('{') {
+			return nil, fmt.Errorf("объект %d: ожидается объект JSON, получено %v", len(objects)+1, token)
+		}
+		if err := readJSONFields(decoder, "", &object); err != nil {
+			return nil, fmt.Errorf("объект %d: %w", len(objects)+1, err)
+		}
+		objects = append(objects, object)
+	}
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// Поля объекта после открывающей скобки, с сохранением их порядка
+func readJSONFields(decoder *json.Decoder, prefix string, object *flatObject) error {
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if err := readJSONValue(decoder, prefix+key, object); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token() // Закрывающая скобка
+	return err
+}
+
+// Значение поля: вложенные объекты и массивы раскладываются на поля через точку
+func readJSONValue(decoder *json.Decoder, field string, object *flatObject) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return readJSONFields(decoder, field+".", object)
+		}
+		for i := 0; decoder.More(); i++ {
+			if err := readJSONValue(decoder, field+"."+strconv.Itoa(i), object); err != nil {
+				return err
+			}
+		}
+		_, err := decoder.Token()
+		return err
+	case string:
+		object.set(field, value)
+	case json.Number:
+		object.set(field, value.String())
+	case bool:
+		object.set(field, strings.ToUpper(strconv.FormatBool(value)))
+	case nil:
+		object.set(field, "")
+	}
+	return nil
+}
+
+// Значение поля; поле добавляется в порядок полей при первой записи
+func (o *flatObject) set(field, value string) {
+	if _, ok := o.values[field]; !ok {
+		o.fields = append(o.fields, field)
+	}
+	o.values[field] = value
+}
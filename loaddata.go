@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Экранирование значения для LOAD DATA с FIELDS ESCAPED BY '\\': обратная косая
+// черта удваивается, табуляция, переводы строк и NUL заменяются escape-последовательностями,
+// иначе они разорвали бы поле или строку
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+
+// NULL в формате LOAD DATA
+const tsvNull = `\N`
+
+// Выгрузка для быстрой загрузки MySQL: данные в TSV файл и SQL файл с CREATE TABLE
+// и единственным LOAD DATA LOCAL INFILE, ссылающимся на него. TSV файл указывается
+// по имени, поэтому загружать нужно из его директории (или поправить путь).
+func exportLoadData(db *gorm.DB, tsvPath, sqlPath string, opts ExportOptions) error {
+	q := exportIdent(opts.IdentQuote)
+	table := exportTable(opts)
+
+	count, err := writeTSV(db, tsvPath, opts.BatchSize, opts.fileMode())
+	if err != nil {
+		return err
+	}
+
+	file, err := createAtomic(sqlPath, opts.fileMode(), false)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if !opts.NoCreateTable {
+		writeCreateTable(writer, table, q)
+	}
+	switch opts.Clear {
+	case ExportClearTruncate:
+		writer.WriteString(fmt.Sprintf("TRUNCATE TABLE %s;\n\n", table))
+	case ExportClearDelete:
+		writer.WriteString(fmt.Sprintf("DELETE FROM %s;\n\n", table))
+	}
+	writer.WriteString(fmt.Sprintf("-- Записей: %d\n", count))
+	writer.WriteString(fmt.Sprintf("LOAD DATA LOCAL INFILE '%s'\nINTO TABLE %s\nCHARACTER SET utf8mb4\n", escapeSQL(filepath.Base(tsvPath)), table))
+	writer.WriteString("FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\'\nLINES TERMINATED BY '\\n'\n")
+	writer.WriteString(fmt.Sprintf("(%s);\n", strings.Join(exportColumns(q), ", ")))
+	if err := writer.Flush(); err != nil {
+		file.abort()
+		return err
+	}
+	return file.commit()
+}
+
+// Запись таблицы в TSV в порядке колонок exportColumns. Возвращает число строк.
+func writeTSV(db *gorm.DB, path string, batchSize int, mode os.FileMode) (int, error) {
+	file, err := createAtomic(path, mode, false)
+	if err != nil {
+		return 0, err
+	}
+	writer := bufio.NewWriterSize(file, 1<<20)
+
+	count := 0
+	var lastID uint
+	for {
+		var page []Product
+		if err := db.Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&page).Error; err != nil {
+			file.abort()
+			return count, fmt.Errorf("ошибка при выборке данных: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		ids := make([]uint, len(page))
+		for i, p := range page {
+			ids[i] = p.ID
+		}
+		extras, err := loadCustomValues(db, ids)
+		if err != nil {
+			file.abort()
+			return count, fmt.Errorf("ошибка при выборке дополнительных колонок: %w", err)
+		}
+
+		for _, p := range page {
+			fields := []string{tsvEscaper.Replace(p.Article), tsvEscaper.Replace(p.Brand), tsvEscaper.Replace(p.Name), tsvNull}
+			if p.Price != nil {
+				fields[3] = strconv.FormatFloat(*p.Price, 'f', 2, 64)
+			}
+			for _, col := range config.CustomColumns {
+				fields = append(fields, tsvValue(extras[p.ID][col.Name]))
+			}
+			fields = append(fields, tsvValue(p.UpdatedAt))
+			writer.WriteString(strings.Join(fields, "\t") + "\n")
+			count++
+		}
+		lastID = page[len(page)-1].ID
+	}
+	if err := writer.Flush(); err != nil {
+		file.abort()
+		return count, err
+	}
+	return count, file.commit()
+}
+
+// Значение поля TSV
+func tsvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return tsvNull
+	case []byte:
+		return tsvEscaper.Replace(string(v))
+	case string:
+		return tsvEscaper.Replace(v)
+	case time.Time:
+		if v.IsZero() {
+			return tsvNull
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return tsvEscaper.Replace(fmt.Sprint(v))
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"compress/flate"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"gorm.io/gorm/logger"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/xuri/excelize/v2"
 	"gorm.io/driver/mysql"
@@ -22,333 +31,2230 @@ import (
 
 // Модель для хранения уникальных записей
 type Product struct {
-	ID      uint   `gorm:"primaryKey;autoIncrement"`                                                  // BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT
-	Article string `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci;index:article_brand"` // VARCHAR(255) NOT NULL
-	Brand   string `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci;index:article_brand"` // VARCHAR(255) NOT NULL
-	Name    string `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci"`                     // VARCHAR(255) NOT NULL
-	Hash    string `gorm:"type:varchar(64);not null;unique;collate:utf8mb4_unicode_ci"`               // VARCHAR(64) NOT NULL UNIQUE
+	ID       uint     `gorm:"primaryKey;autoIncrement"`                                                  // BIGINT(20) UNSIGNED NOT NULL AUTO_INCREMENT
+	Article  string   `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci;index:article_brand"` // VARCHAR(255) NOT NULL
+	Brand    string   `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci;index:article_brand"` // VARCHAR(255) NOT NULL
+	Name     string   `gorm:"type:varchar(255);not null;collate:utf8mb4_unicode_ci"`                     // VARCHAR(255) NOT NULL
+	Hash     string   `gorm:"type:varchar(64);not null;unique;collate:utf8mb4_unicode_ci"`               // VARCHAR(64) NOT NULL UNIQUE
+	RawKey   string   `gorm:"type:varchar(512);not null;default:'';collate:utf8mb4_unicode_ci"`          // VARCHAR(512) NOT NULL — сырые article+brand до нормализации (длинные — SHA-256, см. rawKeyOf)
+	EAN      string   `gorm:"type:varchar(32);not null;default:'';index"`                                // VARCHAR(32) NOT NULL — штрихкод EAN/UPC (если есть)
+	Price    *float64 `gorm:"type:decimal(14,2)"`                                                        // DECIMAL(14,2) NULL — цена (NULL, если неизвестна)
+	Priority int      `gorm:"not null;default:0"`                                                        // INT NOT NULL — приоритет источника текущего названия
+	Source   string   `gorm:"type:varchar(512);not null;default:''"`                                     // VARCHAR(512) NOT NULL — происхождение текущего названия: файл!лист!Rстрока
+
+	UpdatedAt time.Time `gorm:"autoUpdateTime;index"` // DATETIME — время последнего создания или изменения записи
+}
+
+// Имя таблицы товаров по умолчанию
+const defaultTableName = "products"
+
+// TableName Указывает имя таблицы: table_name из конфигурации или products
+func (Product) TableName() string {
+	if config.TableName != "" {
+		return config.TableName
+	}
+	return defaultTableName
+}
+
+// Структура для хранения настроек колонок
+type ColumnSettings struct {
+	Brand   int `json:"brand"`           // Индекс колонки для бренда
+	Article int `json:"article"`         // Индекс колонки для артикула
+	Name    int `json:"name"`            // Индекс колонки для названия
+	Date    int `json:"date,omitempty"`  // Индекс колонки с датой изменения (для -since)
+	EAN     int `json:"ean,omitempty"`   // Индекс колонки со штрихкодом EAN/UPC
+	Price   int `json:"price,omitempty"` // Индекс колонки с ценой
+
+	// Заголовки колонок, заданных в конфигурации названием вместо индекса
+	// (например "brand": "Бренд"), см. ColumnSettings.UnmarshalJSON
+	HeaderNames HeaderSettings `json:"-"`
+}
+
+// Структура для хранения информации о каждом файле
+type FileConfig struct {
+	Filename string          `json:"filename"`          // Имя файла, glob (price_*.xlsx) или "re:" и регулярное выражение
+	Columns  ColumnSettings  `json:"columns"`           // Настройки колонок
+	Headers  *HeaderSettings `json:"headers,omitempty"` // Поиск колонок по строке заголовка (опционально)
+
+	// Выбор листов и колонки отдельных листов (опционально)
+	Sheets *SheetSettings `json:"sheets,omitempty"`
+
+	// Символы, удаляемые из артикула; если не задано — defaultArticleStripChars.
+	// Символы из стандартного набора, не попавшие в список, сохраняются и в хэше.
+	ArticleStripChars []string `json:"article_strip_chars,omitempty"`
+
+	// Пары обрамляющих символов, снимаемых с названия (например "«»", "[]", "\"\"")
+	NameWrappers []string `json:"name_wrappers,omitempty"`
+
+	// Повторяющиеся группы колонок: каждая группа описывает отдельный товар в строке.
+	// Если задано, используется вместо columns; пустые группы пропускаются.
+	Groups []ColumnSettings `json:"groups,omitempty"`
+
+	// Артикул и бренд из логических и числовых ячеек приводятся к канонической строке
+	// по типу ячейки (число 12345 — "12345", а не "12,345.00" по формату ячейки)
+	CanonicalCellTypes bool `json:"canonical_cell_types,omitempty"`
+
+	// Колонки, не заданные в конфигурации, определяются по содержимому листа
+	Autodetect bool `json:"autodetect,omitempty"`
+
+	// Число строк шапки в начале каждого листа (баннер, заголовок), которые
+	// пропускаются всегда; headers и auto_skip_rows работают с оставшимися строками
+	HeaderRows int `json:"header_rows,omitempty"`
+
+	// Пропуск служебных строк в начале листа: данные начинаются с первой строки,
+	// где артикул и бренд заполнены и артикул содержит цифру (не похож на заголовок)
+	AutoSkipRows bool `json:"auto_skip_rows,omitempty"`
+
+	// Маркеры итоговых строк (например "Итого", "TOTAL"): строка, где ячейка одной из
+	// настроенных колонок начинается с маркера, пропускается. Регистр не учитывается.
+	SummaryMarkers []string `json:"summary_markers,omitempty"`
+
+	// Проверка числовых артикулов на потерю точности: warn — в журнал, reject — отклонять строку
+	ArticlePrecisionCheck string `json:"article_precision_check,omitempty"`
+
+	// Формат текстовых дат в колонке даты (раскладка Go, например "02.01.2006" для
+	// 31.12.2024) и часовой пояс дат без смещения (например "Europe/Moscow")
+	DateFormat string `json:"date_format,omitempty"`
+	Timezone   string `json:"timezone,omitempty"`
+
+	// Бренд берётся из имени листа (один бренд на лист), колонка бренда не используется
+	BrandFromSheet bool `json:"brand_from_sheet,omitempty"`
+
+	// Бренд всех товаров файла (прайс одного производителя), колонка бренда
+	// не используется; для отдельных листов задаётся в sheets.columns
+	Brand string `json:"brand,omitempty"`
+
+	// Индексы колонок файла для дополнительных колонок (имя колонки → индекс с единицы)
+	Custom map[string]int `json:"custom,omitempty"`
+
+	// Регистр артикула значим ("ABC" и "abc" — разные товары)
+	CaseSensitiveArticle bool `json:"case_sensitive_article,omitempty"`
+
+	// Соль хэша: пространство имён источника. Товары с одинаковыми article+brand
+	// из файлов с разной солью не объединяются (кроме совпадения по dedup_by_ean)
+	HashSalt string `json:"hash_salt,omitempty"`
+
+	// Приоритет источника для name_tie_break=priority: при равной длине названий
+	// побеждает файл с большим приоритетом
+	Priority int `json:"priority,omitempty"`
+
+	// Число горутин, обрабатывающих строки одного листа (0 или 1 — по одной).
+	// Помогает для файлов с одним очень большим листом.
+	RowWorkers int `json:"row_workers,omitempty"`
+
+	// Максимум строк данных в файле; при превышении файл не обрабатывается (0 — без ограничения)
+	MaxRows int `json:"max_rows,omitempty"`
+
+	// Отклонять строки, где после нормализации пуст артикул, бренд или название
+	RequireAllColumns bool `json:"require_all_columns,omitempty"`
+
+	// Значения для пустых после нормализации ячеек
+	ColumnDefaults ColumnDefaults `json:"column_defaults"`
+
+	// Разделитель и кодировка CSV файла (по умолчанию определяются по содержимому)
+	CSV *CSVSettings `json:"csv,omitempty"`
+
+	// Формулы без сохранённого в файле значения (файл собран программой, а не
+	// сохранён в Excel) вычисляются при чтении; иначе такие ячейки пусты
+	EvaluateFormulas bool `json:"evaluate_formulas,omitempty"`
+
+	// Скрытые в xlsx строки не импортируются (поставщики скрывают снятые с
+	// продажи товары), ячейки скрытых колонок считаются пустыми
+	SkipHidden bool `json:"skip_hidden,omitempty"`
+
+	// Порядок полей JSON/NDJSON файла (по умолчанию — порядок появления в файле)
+	JSON *JSONSettings `json:"json,omitempty"`
+
+	// Таблица Google вместо файла директории; filename — имя в журналах
+	GoogleSheet *GoogleSheetSource `json:"google_sheet,omitempty"`
+
+	// Адрес HTTP(S), по которому поставщик публикует прайс-лист; файл скачивается
+	// в -download-dir и обрабатывается как файл директории
+	URL string `json:"url,omitempty"`
+
+	// Настройки взяты из секции defaults: файла нет в конфигурации
+	FromDefaults bool `json:"-"`
+}
+
+// Значения колонок по умолчанию. В значении можно сослаться на другую колонку
+// строки: "{article}", "{brand}" или "{name}" (например, название по артикулу).
+type ColumnDefaults struct {
+	Brand   string `json:"brand,omitempty"`   // Бренд по умолчанию, например "noname"
+	Article string `json:"article,omitempty"` // Артикул по умолчанию
+	Name    string `json:"name,omitempty"`    // Название по умолчанию, например "{article}"
+}
+
+// Подстановка значений по умолчанию для пустых колонок
+func (d ColumnDefaults) apply(brand, article, name *string, rules NormalizeRules) {
+	expand := func(template string) string {
+		return strings.NewReplacer("{article}", *article, "{brand}", *brand, "{name}", *name).Replace(template)
+	}
+	if *brand == "" && d.Brand != "" {
+		*brand = normalizeBrand(expand(d.Brand))
+	}
+	if *article == "" && d.Article != "" {
+		*article = normalizeArticle(expand(d.Article), rules)
+	}
+	if *name == "" && d.Name != "" {
+		*name = strings.TrimSpace(expand(d.Name))
+	}
+}
+
+// Символы, удаляемые из артикула по умолчанию
+var defaultArticleStripChars = []string{"-", "_", ".", "/", "+", " ", ","}
+
+// Правила нормализации файла, общие для хранимого значения и хэша
+type NormalizeRules struct {
+	ArticleStripChars []string // Символы, удаляемые из артикула
+	HashSalt          string   // Соль хэша источника (пусто — без соли)
+
+	CaseSensitiveArticle bool // Регистр артикула значим: не приводится к нижнему ни в хранимом значении, ни в хэше
+
+	LegacyHash bool // Хэш без разделителя между артикулом и брендом (config.LegacyHash)
+}
+
+// Правила нормализации для файла
+func rulesFor(fc FileConfig) NormalizeRules {
+	rules := NormalizeRules{
+		ArticleStripChars:    defaultArticleStripChars,
+		HashSalt:             fc.HashSalt,
+		CaseSensitiveArticle: fc.CaseSensitiveArticle,
+		LegacyHash:           config.LegacyHash,
+	}
+	if fc.ArticleStripChars != nil {
+		rules.ArticleStripChars = fc.ArticleStripChars
+	}
+	return rules
+}
+
+// Символы стандартного набора, которые правила не удаляют: они значимы
+// для артикула и должны сохраниться при глубокой очистке перед хэшированием
+func (r NormalizeRules) articleKeepChars() string {
+	keep := ""
+	for _, char := range defaultArticleStripChars {
+		stripped := false
+		for _, s := range r.ArticleStripChars {
+			if s == char {
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			keep += char
+		}
+	}
+	return keep
+}
+
+// Структура для хранения названий колонок в строке заголовка
+type HeaderSettings struct {
+	Brand   string `json:"brand"`           // Заголовок колонки бренда
+	Article string `json:"article"`         // Заголовок колонки артикула
+	Name    string `json:"name"`            // Заголовок колонки названия
+	Date    string `json:"date,omitempty"`  // Заголовок колонки даты изменения (необязательно)
+	EAN     string `json:"ean,omitempty"`   // Заголовок колонки EAN (необязательно)
+	Price   string `json:"price,omitempty"` // Заголовок колонки цены (необязательно)
+
+	// Что делать, если заголовок встречается в строке несколько раз:
+	// "first" (по умолчанию) — первая колонка, "last" — последняя, "error" — ошибка
+	Duplicates string `json:"duplicates,omitempty"`
+}
+
+// Стратегии выбора колонки при повторяющихся заголовках
+const (
+	DuplicateHeadersFirst = "first"
+	DuplicateHeadersLast  = "last"
+	DuplicateHeadersError = "error"
+)
+
+// Ошибка: ни одна строка листа не содержит всех требуемых заголовков
+var errHeaderNotFound = errors.New("строка заголовка не найдена")
+
+// Стандартная раскладка колонок для файлов, которых нет в files. В отличие от
+// default_columns применяется только по явному включению (enabled или
+// -use-defaults), а такие файлы перечисляются в предупреждении в конце запуска.
+type DefaultsSettings struct {
+	Enabled bool           `json:"enabled"` // Обрабатывать неизвестные файлы
+	Columns ColumnSettings `json:"columns"` // Колонки стандартной раскладки
+}
+
+// Глобальная структура для хранения всех настроек
+type Config struct {
+	DSN               string                     `json:"dsn"`                 // Строка подключения MySQL, например "${DB_USER}:${DB_PASSWORD}@tcp(${DB_HOST})/testdb?..."
+	Profiles          map[string]ProfileSettings `json:"profiles"`            // Профили окружений, выбираются -profile
+	Files             []FileConfig               `json:"files"`               // Список файлов и их настроек
+	Include           []string                   `json:"include"`             // Фрагменты конфигурации с записями files (пути или glob шаблоны)
+	TableName         string                     `json:"table_name"`          // Имя таблицы товаров (по умолчанию products), в базе и в выгрузке
+	OutputPath        string                     `json:"output_path"`         // Файл SQL выгрузки (по умолчанию output.sql; -output важнее)
+	ExportColumnNames map[string]string          `json:"export_column_names"` // Имена колонок в выгрузке: {"article": "sku", "name": "product_name"}
+	DefaultColumns    *ColumnSettings            `json:"default_columns"`     // Колонки для файлов без записи в files (заменяются -columns)
+	Defaults          *DefaultsSettings          `json:"defaults"`            // Стандартная раскладка для неизвестных прайс-листов (по явному включению)
+	Autodetect        bool                       `json:"autodetect"`          // Определять колонки файлов без настроек по содержимому (как -autodetect)
+	VerifyRawKey      bool                       `json:"verify_raw_key"`      // Сверять сырые article+brand при совпадении хэша
+	LegacyHash        bool                       `json:"legacy_hash"`         // Прежняя схема хэша без разделителя (для таблиц, заполненных до его введения)
+	ExportBatchSize   int                        `json:"export_batch_size"`   // Размер страницы выборки при экспорте
+	ExportDedup       bool                       `json:"export_dedup"`        // Пропускать повторные хэши при экспорте
+	ExportHeader      bool                       `json:"export_header"`       // Комментарий с метаданными в начале выгрузки
+	IdentifierQuote   string                     `json:"identifier_quote"`    // Кавычки идентификаторов в выгрузке: backtick, double, none
+	ExportClear       string                     `json:"export_clear"`        // Очистка таблицы перед вставками: truncate, delete (по умолчанию нет)
+	ExportProvenance  bool                       `json:"export_provenance"`   // Комментарий с файлом, листом и строкой перед каждым INSERT
+	OutputFileMode    string                     `json:"output_file_mode"`    // Права файлов выгрузки, например "0640"
+
+	XLSXExport   XLSXExportSettings `json:"xlsx_export"`   // Форматы выгрузки в xlsx
+	RejectLimits RejectLimits       `json:"reject_limits"` // Пороги отклонённых строк
+
+	ConnRetry ConnRetrySettings `json:"conn_retry"` // Повторы при "Too many connections"
+
+	S3     S3Settings     `json:"s3"`     // Хранилище для -input s3://bucket/prefix
+	Remote RemoteSettings `json:"remote"` // Сервер для -input sftp://... или ftp://...
+	IMAP   IMAPSettings   `json:"imap"`   // Почтовый ящик для -imap
+
+	CustomColumns []CustomColumn `json:"custom_columns"` // Дополнительные колонки таблицы товаров
+
+	// Вид хранимых артикула и бренда; хэш всегда строится по полной нормализации
+	StoredForm StoredFormSettings `json:"stored_form"`
+
+	// Правила нормализации для сравнения с текущими в режиме -compare-rules
+	ProposedRules ProposedRules `json:"proposed_rules"`
+
+	DedupByEAN bool `json:"dedup_by_ean"` // Сопоставлять товары по штрихкоду, если он есть, иначе по хэшу
+
+	// Правила выбора значений при повторной встрече товара; каждое поле
+	// оценивается по своему правилу независимо от остальных
+	NamePolicy   string `json:"name_policy"`    // longest (по умолчанию), first, last
+	NameTieBreak string `json:"name_tie_break"` // Для longest при равной длине: keep (по умолчанию), alphabetical, priority
+	PricePolicy  string `json:"price_policy"`   // min (по умолчанию), max, first, last
+
+	MaxCellLength    int    `json:"max_cell_length"`    // Максимальная длина ячейки в символах (0 — без ограничения)
+	CellLengthPolicy string `json:"cell_length_policy"` // "truncate" (по умолчанию) или "reject"
+
+	InvalidUTF8Policy string `json:"invalid_utf8_policy"` // "sanitize" (по умолчанию) или "reject"
+
+	SheetErrorPolicy string `json:"sheet_error_policy"` // Нечитаемый лист: "skip" (по умолчанию) или "abort"
 }
 
-// TableName Указывает имя таблицы (опционально)
-func (Product) TableName() string {
-	return "products"
-}
+// Реакция на числовой артикул с признаками потери точности
+const (
+	PrecisionCheckWarn   = "warn"
+	PrecisionCheckReject = "reject"
+)
+
+// Политики обработки листов, которые не удалось прочитать
+const (
+	SheetErrorSkip  = "skip"  // Лист пропускается, остальные листы файла обрабатываются
+	SheetErrorAbort = "abort" // Обработка всего файла прекращается
+)
+
+// Вид хранимых значений article и brand
+type StoredFormSettings struct {
+	Article string `json:"article"` // normalized (по умолчанию), lower, trim
+	Brand   string `json:"brand"`   // normalized (по умолчанию), lower, trim
+}
+
+// Виды хранимого значения. Нормализация для сопоставления (хэш) от них не зависит.
+const (
+	StoredFormNormalized = "normalized" // Как для сопоставления: нижний регистр, без разделителей
+	StoredFormLower      = "lower"      // Только нижний регистр: "ABC-123" хранится как "abc-123"
+	StoredFormTrim       = "trim"       // Исходное значение без невидимых символов и краевых пробелов
+)
+
+// Политики обработки ячеек с некорректной UTF-8 последовательностью
+const (
+	InvalidUTF8Sanitize = "sanitize"
+	InvalidUTF8Reject   = "reject"
+)
+
+// Политики обработки слишком длинных ячеек
+const (
+	CellLengthTruncate = "truncate"
+	CellLengthReject   = "reject"
+)
+
+// Параметры экспорта в SQL файл
+type ExportOptions struct {
+	BatchSize int  // Количество записей за одну выборку
+	Dedup     bool // Не записывать хэш, уже попавший в файл
+	Header    bool // Писать в начало выгрузки комментарий с описанием содержимого
+
+	IdentQuote string // Кавычки идентификаторов: backtick (по умолчанию), double, none
+	Clear      string // Очистка таблицы перед вставками: "", truncate, delete
+
+	Provenance    bool    // Комментарий с происхождением перед каждым INSERT
+	Schema        string  // Схема, которой уточняется имя таблицы (пусто — без уточнения)
+	Brand         *string // Выгружать только записи этого бренда (nil — все)
+	NoCreateTable bool    // Не писать CREATE TABLE даже в новый файл
+
+	FileMode os.FileMode // Права файлов выгрузки (0 — defaultOutputFileMode)
+
+	Archive          string // Упаковка выгрузки: "" — обычный файл, zip — архив с единственным файлом
+	CompressionLevel int    // Уровень сжатия архива: от 1 (быстрее) до 9 (меньше), -1 — по умолчанию
+}
+
+// Способы очистки таблицы в начале выгрузки. TRUNCATE быстрее, но в MySQL
+// не откатывается транзакцией; DELETE FROM работает в любой СУБД.
+const (
+	ExportClearNone     = ""
+	ExportClearTruncate = "truncate"
+	ExportClearDelete   = "delete"
+)
+
+// Форматы упаковки SQL выгрузки
+const (
+	ArchiveNone = ""
+	ArchiveZip  = "zip"
+)
+
+// Стили кавычек для идентификаторов в SQL выгрузке
+const (
+	IdentQuoteBacktick = "backtick" // `products` — MySQL
+	IdentQuoteDouble   = "double"   // "products" — ANSI SQL (MySQL с ANSI_QUOTES, PostgreSQL)
+	IdentQuoteNone     = "none"     // products — без кавычек
+)
+
+// Идентификатор в кавычках выбранного стиля; кавычка внутри имени удваивается
+func quoteIdent(name, style string) string {
+	switch style {
+	case IdentQuoteNone:
+		return name
+	case IdentQuoteDouble:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	default:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+}
+
+// Версия программы (задаётся при сборке: -ldflags "-X main.version=1.2.3")
+var version = "dev"
+
+// Размер страницы экспорта по умолчанию
+const defaultExportBatchSize = 1000
+
+// Строка подключения к базе, если в конфигурации не задан dsn
+const defaultDSN = "root:1234@tcp(127.0.0.1:3306)/testdb?charset=utf8mb4&parseTime=True&loc=Local"
+
+var mu sync.Mutex
+var wg sync.WaitGroup
+var config Config
+
+// Нижняя граница даты изменения строки (нулевое значение — без фильтра)
+var sinceCutoff time.Time
+
+// Флаги командной строки (имеют приоритет над конфигурационным файлом)
+var (
+	flagConfig             = flag.String("config", "", "конфигурационный файл JSON, YAML или TOML (по умолчанию $XLSXTOSQL_CONFIG или ./config.json, ./config.yaml, ./config.toml; без файла нужны -columns)")
+	flagProfile            = flag.String("profile", "", "профиль окружения из секции profiles конфигурации (по умолчанию $XLSXTOSQL_PROFILE)")
+	flagInput              = flag.String("input", "./prices", "директория с прайс-листами (xlsx, xlsb, xls, ods, csv, json, ndjson, yml), s3://bucket/prefix, sftp://user@host/dir или ftp://user@host/dir")
+	flagOutput             = flag.String("output", "output.sql", "файл SQL выгрузки (от его имени образуются имена архива, файлов по брендам и -load-data)")
+	flagColumns            = flag.String("columns", "", "колонки по умолчанию для всех файлов: brand=1,article=2,name=3[,date=N,ean=N,price=N]")
+	flagGORMModel          = flag.String("gorm-model", "", "записать Go структуру модели Product с тегами gorm (с дополнительными колонками) и завершить работу")
+	flagGORMPackage        = flag.String("gorm-package", "models", "имя пакета в файле -gorm-model")
+	flagExportBatchSize    = flag.Int("export-batch-size", 0, "размер страницы выборки при экспорте (по умолчанию из конфигурации или 1000)")
+	flagExportHeader       = flag.Bool("export-header", false, "писать в начало выгрузки комментарий: время, версия, число записей, исходные файлы")
+	flagExportProvenance   = flag.Bool("export-provenance", false, "писать перед каждым INSERT комментарий с источником: -- файл!лист!Rстрока")
+	flagExportClear        = flag.String("export-clear", "", "очищать таблицу в начале выгрузки: truncate или delete (по умолчанию из конфигурации или не очищать)")
+	flagIdentQuote         = flag.String("identifier-quote", "", "кавычки идентификаторов в выгрузке: backtick, double, none (по умолчанию из конфигурации или backtick)")
+	flagExportDedup        = flag.Bool("export-dedup", false, "пропускать при экспорте записи с уже выгруженным хэшем")
+	flagWatch              = flag.Bool("watch", false, "после первичной обработки следить за директорией и обрабатывать новые файлы")
+	flagStdin              = flag.Bool("stdin", false, "читать один прайс-лист из стандартного ввода вместо -input (формат задаёт -format, настройки — запись stdin.<формат>)")
+	flagFormat             = flag.String("format", "xlsx", "формат прайс-листа из -stdin: xlsx, xlsb, xls, ods, csv, json, jsonl, ndjson, yml, xml или zip")
+	flagIMAP               = flag.Bool("imap", false, "перед обработкой сохранить в -input вложения непрочитанных писем поставщиков (секция imap конфигурации)")
+	flagDiffAgainst        = flag.String("diff-against", "", "предыдущая выгрузка (снимок .json или .sql) для отчёта об изменениях")
+	flagDiffReport         = flag.String("diff-report", "diff.json", "путь к отчёту об изменениях")
+	flagRejects            = flag.String("rejects", "", "записать отклонённые строки в файл (JSON Lines)")
+	flagRejectsXLSX        = flag.String("rejects-xlsx", "", "записать отклонённые строки в xlsx для поставщиков (лист на исходный файл)")
+	flagVerify             = flag.Bool("verify", false, "только проверить существующую таблицу по исходным файлам, не изменяя её")
+	flagCompareRules       = flag.String("compare-rules", "", "без записи в базу сравнить текущие правила нормализации с proposed_rules и записать отчёт в файл")
+	flagVerifyReport       = flag.String("verify-report", "verify.json", "путь к отчёту о расхождениях в режиме -verify")
+	flagManifest           = flag.String("manifest", "manifest.json", "файл манифеста с контрольными суммами обработанных файлов")
+	flagResume             = flag.Bool("resume", false, "возобновить прерванный импорт: не очищать таблицу и пропустить уже обработанные файлы")
+	flagStats              = flag.String("stats", "", "записать сводку запуска (счётчики файлов и строк) в JSON файл")
+	flagLoadData           = flag.Bool("load-data", false, "вместо INSERT выгрузить output.tsv и output_load.sql с LOAD DATA LOCAL INFILE")
+	flagExportByBrand      = flag.Bool("export-by-brand", false, "выгружать каждый бренд в отдельный файл output_<бренд>.sql")
+	flagExportBrandWorkers = flag.Int("export-brand-workers", 4, "число файлов брендов, записываемых одновременно")
+	flagBrandCreateTable   = flag.Bool("brand-create-table", true, "писать CREATE TABLE в начало каждого файла бренда")
+	flagSkipExport         = flag.Bool("skip-export", false, "не писать output.sql; отчёты (-rejects, -stats, -metrics) пишутся как обычно")
+	flagMetrics            = flag.String("metrics", "", "записать метрики запуска в .prom файл для textfile collector node_exporter")
+	flagSince              = flag.String("since", "", "импортировать только строки с датой изменения не раньше указанной (2006-01-02 или RFC3339)")
+	flagLogMode            = flag.String("log-mode", LogModePrefix, "вывод журнала по файлам: prefix — строки с именем файла, buffer — блоком по окончании файла")
+	flagSequential         = flag.Bool("sequential", false, "обрабатывать файлы по одному в порядке конфигурации (детерминированный выбор названия при равной длине)")
+	flagYes                = flag.Bool("yes", false, "generate-config: принимать угаданные колонки без вопросов")
+	flagAutodetect         = flag.Bool("autodetect", false, "определять колонки файлов без настроек по содержимому (артикул, бренд, название)")
+	flagUseDefaults        = flag.Bool("use-defaults", false, "обрабатывать файлы без настроек со стандартной раскладкой из секции defaults конфигурации")
+	flagReverse            = flag.Bool("reverse", false, "обрабатывать файлы, листы и строки с конца (результат тот же, что и без -reverse; политики last выполняются как first, по одной записи на товар)")
+	flagBumpUpdatedAt      = flag.Bool("bump-updated-at", true, "обновлять updated_at при изменении записи")
+	flagXLSXOut            = flag.String("xlsx-out", "", "дополнительно выгрузить каталог в xlsx файл")
+	flagSnapshot           = flag.String("snapshot", "", "сохранить снимок каталога (хэш → товар) для будущих сравнений")
+	flagHashCache          = flag.String("hash-cache", "", "файл кэша хэшей (хэш → id) между запусками с -resume; без флага поиск идёт запросами к базе")
+	flagOutputMode         = flag.String("output-mode", "", "права файлов выгрузки в восьмеричной записи (по умолчанию из конфигурации или 0644)")
+	flagArchive            = flag.String("archive", ArchiveNone, "упаковать SQL выгрузку: zip — output.zip с output.sql внутри")
+	flagArchiveLevel       = flag.Int("archive-level", flate.DefaultCompression, "уровень сжатия архива: 1 (быстрее) … 9 (меньше), -1 — по умолчанию")
+	flagMaxRuntime         = flag.Duration("max-runtime", 0, "максимальное время обработки; по истечении выгружается импортированное и, если обработаны не все файлы, процесс завершается с кодом 3")
+	flagHashPreloadWorkers = flag.Int("hash-preload-workers", 4, "число горутин, читающих хэши из таблицы, когда кэш хэшей строится заново")
+	flagPoolCheck          = flag.String("pool-check", PoolCheckWarn, "сверка пула соединений с max_connections сервера: warn, error, clamp или off")
+	flagSchema             = flag.String("schema", "", "работать в отдельной схеме (создаётся при необходимости); auto — import_<время запуска>")
+	flagDownloadDir        = flag.String("download-dir", filepath.Join(os.TempDir(), "xlsxtosql-downloads"), "директория для прайс-листов, скачанных по url (с ETag и Last-Modified для повторных запусков)")
+	flagWatchSettle        = flag.Duration("watch-settle", 2*time.Second, "время без записи в файл, после которого он считается загруженным")
+)
+
+// Подкоманды этапов: import читает xlsx в промежуточную таблицу, export выгружает
+// её в SQL, clean очищает её, run выполняет импорт и выгрузку. Запуск без
+// подкоманды равносилен run. Раздельные этапы позволяют выгрузить уже
+// импортированное с другими параметрами, не читая xlsx заново.
+const (
+	commandRun    = "run"
+	commandImport = "import"
+	commandExport = "export"
+	commandClean  = "clean"
+)
+
+// Все подкоманды
+var commands = []string{commandRun, commandImport, commandExport, commandClean, commandValidateConfig, commandGenerateConfig}
+
+func main() {
+	// xlsxToSQL [run] [-config путь] [-input директория] [-output файл] ...
+	// xlsxToSQL import [-config путь] [-input директория] ...
+	// xlsxToSQL export [-config путь] [-output файл] ...
+	// xlsxToSQL clean [-config путь]
+	// xlsxToSQL validate-config [-config путь] [-input директория]
+	// xlsxToSQL generate-config [-config путь] [-input директория] [-yes]
+	args := os.Args[1:]
+	command := commandRun
+	if len(args) > 0 && slices.Contains(commands, args[0]) {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	if flag.NArg() > 0 {
+		log.Fatalf("Неизвестная подкоманда или аргумент %q (ожидается одна из %s и флаги)",
+			flag.Arg(0), strings.Join(commands, ", "))
+	}
+	switch command {
+	case commandValidateConfig:
+		os.Exit(runValidateConfig(*flagInput))
+	case commandGenerateConfig:
+		output := *flagConfig
+		if output == "" {
+			output = defaultConfigPaths[0]
+		}
+		if err := runGenerateConfig(*flagInput, output, *flagYes); err != nil {
+			log.Fatalf("Ошибка генерации конфигурации: %v", err)
+		}
+		return
+	}
+	if *flagWatch && (strings.HasPrefix(*flagInput, s3Scheme) || isRemoteInput(*flagInput)) {
+		log.Fatalf("Флаг -watch следит за локальной директорией и не используется с хранилищем или сервером")
+	}
+	if *flagIMAP && (strings.HasPrefix(*flagInput, s3Scheme) || isRemoteInput(*flagInput)) {
+		log.Fatalf("Флаг -imap сохраняет вложения в локальную директорию и не используется с хранилищем или сервером")
+	}
+	if command != commandRun && command != commandImport && (*flagVerify || *flagWatch || *flagStdin) {
+		log.Fatalf("Флаги -verify, -watch и -stdin используются только с подкомандами %s и %s", commandRun, commandImport)
+	}
+	if *flagStdin && (*flagWatch || *flagIMAP) {
+		log.Fatalf("Флаг -stdin читает один прайс-лист и не используется с -watch и -imap")
+	}
+	if *flagHashCache != "" && !*flagResume {
+		// Без -resume таблица очищается перед импортом, и кэш прошлого запуска к ней не подходит
+		log.Fatalf("Флаг -hash-cache используется только вместе с -resume")
+	}
+
+	// Чтение конфигурации: файл, окружение, флаги
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Ошибка конфигурации: %v", err)
+	}
+
+	since, err := parseSince(*flagSince)
+	if err != nil {
+		log.Fatalf("Некорректное значение -since: %v", err)
+	}
+	sinceCutoff = since
+
+	if err := validateCustomColumns(config.CustomColumns); err != nil {
+		log.Fatalf("Ошибка в описании дополнительных колонок: %v", err)
+	}
+
+	// Описание модели не требует базы: пишем его и завершаем работу
+	if *flagGORMModel != "" {
+		if err := writeGORMModel(*flagGORMModel, *flagGORMPackage); err != nil {
+			log.Fatalf("Не удалось записать модель: %v", err)
+		}
+		fmt.Println("Модель записана в", *flagGORMModel)
+		return
+	}
+
+	switch *flagArchive {
+	case ArchiveNone, ArchiveZip:
+	default:
+		log.Fatalf("Недопустимое значение -archive: %q (ожидается zip)", *flagArchive)
+	}
+	if *flagArchiveLevel < flate.DefaultCompression || *flagArchiveLevel > flate.BestCompression {
+		log.Fatalf("Недопустимое значение -archive-level: %d (ожидается от -1 до 9)", *flagArchiveLevel)
+	}
+
+	switch *flagPoolCheck {
+	case PoolCheckOff, PoolCheckWarn, PoolCheckError, PoolCheckClamp:
+	default:
+		log.Fatalf("Недопустимое значение -pool-check: %q", *flagPoolCheck)
+	}
+
+	outputModeValue := config.OutputFileMode
+	if *flagOutputMode != "" {
+		outputModeValue = *flagOutputMode
+	}
+	outputMode := defaultOutputFileMode
+	if outputModeValue != "" {
+		if outputMode, err = parseFileMode(outputModeValue); err != nil {
+			log.Fatalf("Некорректные права файлов выгрузки: %v", err)
+		}
+	}
+
+	exportBatchSize, err := resolveExportBatchSize()
+	if err != nil {
+		log.Fatalf("Некорректный размер страницы экспорта: %v", err)
+	}
+
+	identQuote := config.IdentifierQuote
+	if *flagIdentQuote != "" {
+		identQuote = *flagIdentQuote
+	}
+	switch identQuote {
+	case "", IdentQuoteBacktick, IdentQuoteDouble, IdentQuoteNone:
+	default:
+		log.Fatalf("Неизвестный стиль кавычек идентификаторов: %q", identQuote)
+	}
+
+	exportClear := config.ExportClear
+	if *flagExportClear != "" {
+		exportClear = *flagExportClear
+	}
+	switch exportClear {
+	case ExportClearNone, ExportClearTruncate, ExportClearDelete:
+	default:
+		log.Fatalf("Неизвестный способ очистки таблицы в выгрузке: %q", exportClear)
+	}
+
+	// Сравнение правил нормализации выполняется без базы
+	if *flagCompareRules != "" {
+		if err := runRulesComparison(*flagInput, *flagCompareRules); err != nil {
+			log.Fatalf("Ошибка сравнения правил: %v", err)
+		}
+		return
+	}
+
+	// Подключение к временной MySQL базе для обработки данных
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = defaultDSN
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
+	}
+
+	// Отдельная схема запуска для изолированных параллельных импортов
+	schema := ""
+	if *flagSchema != "" {
+		schema, err = resolveSchemaName(*flagSchema, time.Now())
+		if err != nil {
+			log.Fatalf("Некорректное значение -schema: %v", err)
+		}
+		db, err = openSchema(db, dsn, schema)
+		if err != nil {
+			log.Fatalf("Не удалось подключиться к схеме: %v", err)
+		}
+		fmt.Println("Используется схема", schema)
+	}
+
+	// Манифест обработанных файлов: при возобновлении продолжаем с сохранённого состояния,
+	// иначе начинаем новый запуск с пустым манифестом. Проверка и выгрузка манифест
+	// не изменяют (выгрузка читает его для списка источников в заголовке); очистка
+	// таблицы удаляет его и с диска (см. cleanTable).
+	switch {
+	case command == commandExport:
+		err = loadManifestReadOnly(*flagManifest)
+	case *flagVerify:
+		err = loadManifest("", false)
+	default:
+		err = loadManifest(*flagManifest, *flagResume)
+	}
+	if err != nil {
+		log.Fatalf("Не удалось загрузить манифест: %v", err)
+	}
+
+	// Настройка пула соединений
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("не удалось получить доступ к базовым соединениям:", err)
+	}
+	// Предварительная проверка: сервер может не выдать столько соединений
+	maxOpenConns, err := checkPoolSize(db, defaultMaxOpenConns, *flagPoolCheck)
+	if err != nil {
+		log.Fatalf("Проверка пула соединений: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)                           // Максимум открытых соединений (по умолчанию 50)
+	sqlDB.SetMaxIdleConns(min(defaultMaxIdleConns, maxOpenConns)) // Максимум простаивающих соединений (по умолчанию 20)
+	sqlDB.SetConnMaxLifetime(time.Minute * 5)                     // Время жизни соединения
+
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	startTime := time.Now() // Запоминаем начальное время
+
+	// Общий срок запуска: по его истечении файлы не начинаются, начатые прерываются
+	// после текущей строки, а импортированное выгружается
+	stopDeadline := startDeadline(*flagMaxRuntime)
+	defer stopDeadline()
+
+	if *flagVerify || command == commandExport {
+		// Проверять и выгружать можно только уже заполненную таблицу
+		if !db.Migrator().HasTable(&Product{}) {
+			log.Fatalf("Таблица '%s' не существует, сначала выполните %s", Product{}.TableName(), commandImport)
+		}
+	} else {
+		// Создание таблицы, если её нет
+		err = db.AutoMigrate(&Product{})
+		if err != nil {
+			log.Fatalf("Не удалось создать таблицу: %v", err)
+		}
+		if err := migrateCustomColumns(db, config.CustomColumns); err != nil {
+			log.Fatalf("Не удалось создать дополнительные колонки: %v", err)
+		}
+	}
+
+	// Очистка промежуточной таблицы без импорта и выгрузки. Как и перед импортом,
+	// после AutoMigrate: на пустой базе таблица уже существует.
+	if command == commandClean {
+		if err := cleanTable(db, *flagManifest); err != nil {
+			log.Fatalf("Не удалось очистить таблицу: %v", err)
+		}
+		fmt.Println("Таблица очищена")
+		return
+	}
+
+	// Очистка таблицы перед началом работы. Выполняется после AutoMigrate, чтобы
+	// на пустой базе таблица уже существовала. В режиме проверки и при возобновлении
+	// таблица не очищается, при выгрузке — тем более.
+	if command != commandExport && !*flagVerify && !*flagResume {
+		if err := clearTable(db, Product{}.TableName()); err != nil {
+			log.Fatalf("Не удалось очистить таблицу: %v", err)
+		}
+	}
+
+	// Импорт xlsx в таблицу (кроме подкоманды export)
+	if command != commandExport {
+		// Кэш хэшей: из файла, если таблица с прошлого запуска не менялась, иначе из базы
+		if *flagHashCache != "" {
+			fromFile, err := loadHashCache(db, *flagHashCache, exportBatchSize, *flagHashPreloadWorkers)
+			if err != nil {
+				log.Fatalf("Не удалось загрузить кэш хэшей: %v", err)
+			}
+			if !fromFile {
+				log.Printf("Кэш хэшей %s отсутствует или устарел, построен по таблице\n", *flagHashCache)
+			}
+		}
+
+		dirPath := *flagInput // Путь к директории с файлами
+		// Завершение после обработки всех файлов: перенос файлов сервера, удаление копии stdin
+		finishInput := func() {}
+		switch {
+		case *flagStdin:
+			// Один прайс-лист из конвейера (curl, unzip) вместо директории
+			localPath, err := readStdinInput(*flagFormat)
+			if err != nil {
+				log.Fatalf("Не удалось прочитать стандартный ввод: %v", err)
+			}
+			finishInput = func() { os.Remove(localPath) }
+			dispatchFileFrom(db, stdinSourceName+filepath.Ext(localPath), localPath)
+		case strings.HasPrefix(dirPath, s3Scheme):
+			// Прайс-листы из хранилища вместо локальной директории
+			if err := dispatchS3(db, dirPath); err != nil {
+				log.Fatalf("Ошибка чтения хранилища: %v", err)
+			}
+		case isRemoteInput(dirPath):
+			// Прайс-листы с сервера SFTP/FTP; обработанные переносятся после wg.Wait
+			finishInput, err = dispatchRemote(db, dirPath)
+			if err != nil {
+				log.Fatalf("Ошибка чтения сервера: %v", err)
+			}
+		default:
+			// Вложения писем поставщиков сохраняются в директорию до её чтения
+			if *flagIMAP {
+				saved, err := fetchMailAttachments(config.IMAP, dirPath)
+				if err != nil {
+					log.Fatalf("Ошибка чтения почты: %v", err)
+				}
+				fmt.Printf("Сохранено вложений из почты: %d\n", saved)
+			}
+
+			files, err := os.ReadDir(dirPath)
+			if err != nil {
+				log.Fatalf("Не удалось прочитать директорию: %v", err)
+			}
+
+			// В последовательном режиме файлы идут в порядке их записей в конфигурации.
+			// Правило выбора названия (более длинное заменяет сохранённое) от порядка не зависит,
+			// кроме названий равной длины: остаётся записанное первым (если не задан name_tie_break).
+			// При параллельной обработке «первым» окажется случайный файл, в последовательном —
+			// стоящий раньше в конфигурации. То же относится к name_policy=first.
+			if *flagSequential || *flagReverse {
+				sort.SliceStable(files, func(i, j int) bool {
+					return configOrder(files[i].Name()) < configOrder(files[j].Name())
+				})
+			}
+			if *flagReverse {
+				slices.Reverse(files)
+			}
+
+			for _, file := range files {
+				if isInputFile(file.Name()) {
+					dispatchFile(db, filepath.Join(dirPath, file.Name()))
+				}
+			}
+		}
+		if !*flagStdin {
+			dispatchExternalSources(db) // Таблицы Google и адреса из конфигурации
+		}
+
+		// Ждём завершения всех горутин
+		wg.Wait()
+		finishInput()
+		removeArchiveDirs()
+
+		// В режиме проверки вместо экспорта строится отчёт о расхождениях
+		if *flagVerify {
+			_, err := writeVerifyReport(db, *flagVerifyReport, exportBatchSize)
+			writeRunReports(time.Since(startTime)) // Отчёты о строках пишутся и при ошибке проверки
+			if err != nil {
+				log.Fatalf("Ошибка проверки: %v", err)
+			}
+			fmt.Println("Время выполнения:", time.Since(startTime))
+			exitIfDeadlineExceeded()
+			return
+		}
+
+		// В режиме наблюдения продолжаем принимать новые файлы до сигнала остановки
+		if *flagWatch {
+			if err := watchDirectory(db, dirPath, *flagWatchSettle); err != nil {
+				log.Fatalf("Ошибка наблюдения за директорией: %v", err)
+			}
+			removeArchiveDirs()
+		}
+
+		if *flagHashCache != "" {
+			if err := saveHashCache(db, *flagHashCache); err != nil {
+				log.Printf("Не удалось сохранить кэш хэшей: %v\n", err)
+			}
+		}
+	}
+
+	// Только импорт: выгрузку выполнит подкоманда export
+	if command == commandImport {
+		elapsedTime := time.Since(startTime)
+		writeRunReports(elapsedTime)
+		fmt.Println("Время выполнения:", elapsedTime)
+		exitIfDeadlineExceeded()
+		return
+	}
+
+	// Экспорт данных в SQL файл (или по файлу на бренд)
+	exportOpts := ExportOptions{
+		BatchSize: exportBatchSize,
+		Dedup:     config.ExportDedup || *flagExportDedup,
+		Header:    config.ExportHeader || *flagExportHeader,
+
+		IdentQuote: identQuote,
+		Clear:      exportClear,
+
+		Archive:          *flagArchive,
+		CompressionLevel: *flagArchiveLevel,
+		FileMode:         outputMode,
+
+		Provenance:    config.ExportProvenance || *flagExportProvenance,
+		Schema:        schema,
+		NoCreateTable: *flagExportByBrand && !*flagBrandCreateTable,
+	}
+	switch {
+	case *flagSkipExport:
+	case *flagLoadData:
+		base := strings.TrimSuffix(*flagOutput, filepath.Ext(*flagOutput))
+		if err := exportLoadData(db, base+".tsv", base+"_load.sql", exportOpts); err != nil {
+			log.Fatalf("Ошибка выгрузки для LOAD DATA: %v", err)
+		}
+	case *flagExportByBrand:
+		if err := exportByBrand(db, *flagOutput, exportOpts, *flagExportBrandWorkers); err != nil {
+			log.Fatalf("Ошибка выгрузки по брендам: %v", err)
+		}
+	default:
+		if err := exportToSQLFile(db, *flagOutput, exportOpts); err != nil {
+			log.Fatalf("Ошибка выгрузки: %v", err)
+		}
+	}
+
+	// Выгрузка в xlsx
+	if *flagXLSXOut != "" {
+		if err := exportToXLSXFile(db, *flagXLSXOut, exportBatchSize, config.XLSXExport); err != nil {
+			log.Printf("Не удалось выгрузить каталог в xlsx: %v\n", err)
+		}
+	}
+
+	// Отчёт об изменениях относительно предыдущей выгрузки
+	if *flagDiffAgainst != "" {
+		if err := writeDiffReport(db, *flagDiffAgainst, *flagDiffReport, exportBatchSize); err != nil {
+			log.Printf("Не удалось построить отчёт об изменениях: %v\n", err)
+		}
+	}
+
+	// Снимок каталога для следующего сравнения
+	if *flagSnapshot != "" {
+		snapshot, err := snapshotFromDB(db, exportBatchSize)
+		if err == nil {
+			err = writeSnapshot(snapshot, *flagSnapshot)
+		}
+		if err != nil {
+			log.Printf("Не удалось сохранить снимок каталога: %v\n", err)
+		}
+	}
+
+	elapsedTime := time.Since(startTime) // Вычисляем время выполнения
+
+	// Отклонённые строки, сводка и метрики
+	writeRunReports(elapsedTime)
+
+	fmt.Printf("Время выполнения (форматированный вывод): %.2f секунд\n", elapsedTime.Seconds())
+	fmt.Println("Время выполнения (стандарный вывод):", elapsedTime)
+	exitIfDeadlineExceeded()
+
+	fmt.Scanln()
+}
+
+// Определение размера страницы экспорта: флаг, затем конфигурация, затем значение по умолчанию
+func resolveExportBatchSize() (int, error) {
+	size := defaultExportBatchSize
+	if config.ExportBatchSize != 0 {
+		size = config.ExportBatchSize
+	}
+	if *flagExportBatchSize != 0 {
+		size = *flagExportBatchSize
+	}
+	if size <= 0 {
+		return 0, fmt.Errorf("ожидается положительное число, получено %d", size)
+	}
+	return size, nil
+}
+
+// Поиск настроек для файла по его имени
+func findFileConfig(fileName string) *FileConfig {
+	if i := fileConfigIndex(fileName); i >= 0 {
+		return &config.Files[i]
+	}
+	// Фиды YML без своей записи читаются по стандартным полям предложения
+	if isYMLFile(fileName) {
+		headers := ymlHeaders
+		return &FileConfig{Filename: fileName, Headers: &headers}
+	}
+	// Файлы без своей записи обрабатываются с колонками по умолчанию, если они заданы
+	if config.DefaultColumns != nil {
+		return &FileConfig{Filename: fileName, Columns: *config.DefaultColumns}
+	}
+	if defaultsEnabled() {
+		return &FileConfig{Filename: fileName, Columns: config.Defaults.Columns, FromDefaults: true}
+	}
+	// Последний вариант — определить колонки по содержимому файла
+	if config.Autodetect || *flagAutodetect {
+		return &FileConfig{Filename: fileName, Autodetect: true}
+	}
+	return nil
+}
+
+// Включена стандартная раскладка для файлов без настроек
+func defaultsEnabled() bool {
+	return config.Defaults != nil && (config.Defaults.Enabled || *flagUseDefaults)
+}
+
+// Порядковый ключ файла: позиция в конфигурации, файлы без настроек — в конце
+func configOrder(fileName string) int {
+	if i := fileConfigIndex(fileName); i >= 0 {
+		return i
+	}
+	return len(config.Files)
+}
+
+// Позиция настроек файла в конфигурации (-1, если настроек нет). Точное имя
+// важнее шаблона; из нескольких подходящих шаблонов берётся первый.
+func fileConfigIndex(fileName string) int {
+	for i := range config.Files {
+		if config.Files[i].Filename == fileName && !config.Files[i].external() {
+			return i
+		}
+	}
+	for i := range config.Files {
+		if !config.Files[i].external() && isFilenamePattern(config.Files[i].Filename) && matchFilenamePattern(config.Files[i].Filename, fileName) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Запуск обработки файла в отдельной горутине, если для него есть настройки
+func dispatchFile(db *gorm.DB, filePath string) {
+	dispatchFileFrom(db, filePath, filePath)
+}
+
+// Обработка локальной копии filePath прайс-листа из источника source (адреса
+// в хранилище): настройки ищутся по имени источника, в журналах и манифесте
+// он же. Для файлов директории source и filePath совпадают.
+func dispatchFileFrom(db *gorm.DB, source, filePath string) {
+	if !isValidFile(filePath) {
+		log.Printf("Файл '%s' не найден или недействителен.\n", filePath)
+		return
+	}
+	if isArchiveFile(filePath) {
+		dispatchArchive(db, source, filePath)
+		return
+	}
+	name := path.Base(filepath.ToSlash(source))
+
+	// Поиск настроек для текущего файла
+	configMu.RLock()
+	foundConfig := findFileConfig(name)
+	configMu.RUnlock()
+	if foundConfig == nil && hasConfigSheet(filePath) {
+		// Самоописанный файл: колонки будут прочитаны из его служебного листа
+		foundConfig = &FileConfig{Filename: name}
+	}
+	if foundConfig == nil {
+		log.Printf("Настройки для файла '%s' не найдены в конфигурации.\n", name)
+		return
+	}
+	if foundConfig.FromDefaults {
+		log.Printf("Внимание: файла '%s' нет в конфигурации, он обрабатывается со стандартной раскладкой колонок\n", name)
+		noteDefaultLayoutFile(name)
+	}
+
+	checksum, err := fileChecksum(filePath)
+	if err != nil {
+		log.Printf("Не удалось вычислить контрольную сумму файла '%s': %v\n", filePath, err)
+		return
+	}
+	fc := *foundConfig
+	startProcessing(db, source, checksum, fc, func() (workbook, error) {
+		return openWorkbook(filePath, fc)
+	})
+}
+
+// Запуск обработки прайс-листа filePath (файла или другого источника с его
+// контрольной суммой checksum). open вызывается уже в горутине обработки.
+func startProcessing(db *gorm.DB, filePath, checksum string, fc FileConfig, open func() (workbook, error)) {
+	// При возобновлении пропускаем файлы, уже полностью обработанные в прерванном запуске
+	if *flagResume && manifest.isCompleted(filePath, checksum) {
+		fmt.Println("Файл уже обработан, пропускаем ", filePath)
+		return
+	}
+
+	if deadlineExceeded() {
+		fmt.Println("Срок работы истёк, файл пропущен ", filePath)
+		counters.FilesDeadline.Add(1)
+		return
+	}
+
+	run := func(filePath string, fc FileConfig) {
+		configMu.RLock()
+		defer configMu.RUnlock()
+		if err := processXLSXFileWithConfig(db, filePath, fc, open); errors.Is(err, errDeadlineExceeded) {
+			log.Printf("Обработка файла %s прервана: %v\n", filePath, err)
+			counters.FilesDeadline.Add(1)
+			return
+		} else if err != nil {
+			log.Printf("Ошибка обработки файла: %v\n", err)
+			counters.FilesFailed.Add(1)
+			return
+		}
+		counters.FilesProcessed.Add(1)
+		if err := manifest.markCompleted(filePath, checksum); err != nil {
+			log.Printf("Не удалось обновить манифест для файла '%s': %v\n", filePath, err)
+		}
+	}
+
+	// В последовательном режиме файл обрабатывается сразу, в текущей горутине
+	if *flagSequential || *flagReverse {
+		run(filePath, fc)
+		return
+	}
+
+	wg.Add(1) // Добавляем задачу в группу ожидания
+	go func(filePath string, fc FileConfig) {
+		defer wg.Done() // Отмечаем задачу как выполненную после завершения
+		run(filePath, fc)
+	}(filePath, fc)
+}
+
+// Функция для проверки существования файла
+func isValidFile(filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// Команда clean: очистка таблицы и манифеста. Манифест удаляется и с диска,
+// иначе следующий import -resume счёл бы все файлы уже загруженными в пустую таблицу.
+func cleanTable(db *gorm.DB, manifestPath string) error {
+	if err := clearTable(db, Product{}.TableName()); err != nil {
+		return err
+	}
+	if err := resetManifest(manifestPath); err != nil {
+		return fmt.Errorf("не удалось сбросить манифест %s: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// Функция для очистки таблицы
+func clearTable(db *gorm.DB, tableName string) error {
+	// Проверяем, существует ли таблица
+	if !db.Migrator().HasTable(tableName) {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+
+	// Очищаем таблицу, если она существует
+	return db.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`", tableName)).Error
+}
+
+// Глубокая очистка строки от всех нежелательных символов (кроме перечисленных в keep).
+// При keepCase регистр букв сохраняется.
+func deepClean(value string, keep string, keepCase bool) string {
+	// Удаляем все пробельные символы (включая табуляции и переносы строк)
+	value = strings.TrimSpace(value)
+	value = strings.ReplaceAll(value, "\t", "")
+	value = strings.ReplaceAll(value, "\n", "")
+	value = strings.ReplaceAll(value, "\r", "")
+
+	// Преобразуем в нижний регистр
+	if !keepCase {
+		value = strings.ToLower(value)
+	}
+
+	// Удаляем все специальные символы (оставляем только буквы и цифры)
+	value = removeNonAlphanumeric(value, keep)
+
+	return value
+}
+
+// Удаление всех не буквенно-цифровых символов (кроме перечисленных в keep)
+func removeNonAlphanumeric(value string, keep string) string {
+	result := ""
+	for _, char := range value {
+		if (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') || strings.ContainsRune(keep, char) {
+			result += string(char)
+		}
+	}
+	return result
+}
+
+// Разделитель артикула и бренда во входе хэша
+const hashSeparator = "\x1f"
+
+// Генерация хэша для комбинации article + brand.
+//
+// Между артикулом и брендом ставится разделитель, иначе пары с неоднозначной
+// границей совпадали: "ab"+"c" и "a"+"bc". Миграция: это меняет хэши всех записей,
+// поэтому таблицу, заполненную прежней версией, нужно загрузить заново (запуск
+// без -resume очищает её) либо продолжать с legacy_hash=true. Снимки и кэш хэшей
+// прежней схемы после перехода тоже следует построить заново.
+func generateHash(article, brand string, rules NormalizeRules) string {
+	article = deepClean(article, rules.articleKeepChars(), rules.CaseSensitiveArticle)
+	brand = deepClean(brand, "", false)
+	hashInput := article + hashSeparator + brand
+	if rules.LegacyHash {
+		hashInput = article + brand
+	}
+	if rules.HashSalt != "" {
+		// Разделитель не даёт соли слиться с артикулом ("ab"+"c" и "a"+"bc")
+		hashInput = rules.HashSalt + "\x00" + hashInput
+	}
+	hash := sha256.Sum256([]byte(hashInput))
+	return hex.EncodeToString(hash[:])
+}
+
+// Индекс первой строки, похожей на данные: артикул и бренд (если он берётся
+// из колонки) не пусты, а в артикуле есть цифра — в отличие от заголовков
+// и служебных строк вроде «Прайс-лист на 01.01». Если таких строк нет, возвращается len(rows).
+func firstDataRow(rows [][]string, settings ColumnSettings, brandFromSheet bool) int {
+	cell := func(row []string, index int) string {
+		if index <= 0 || len(row) < index {
+			return ""
+		}
+		return strings.TrimSpace(row[index-1])
+	}
+	for i, row := range rows {
+		article := cell(row, settings.Article)
+		if article == "" || !strings.ContainsFunc(article, unicode.IsDigit) {
+			continue
+		}
+		if !brandFromSheet && cell(row, settings.Brand) == "" {
+			continue
+		}
+		return i
+	}
+	return len(rows)
+}
+
+// Поиск маркера итоговой строки в настроенных колонках строки. Ячейка считается
+// итоговой, если совпадает с маркером или начинается с него и следующий символ
+// не буква («Итого:», «ИТОГО по листу», но не «Totalizer»).
+func summaryRowMarker(row []string, groups []ColumnSettings, markers []string) (string, bool) {
+	if len(markers) == 0 {
+		return "", false
+	}
+	for _, group := range groups {
+		for _, index := range []int{group.Article, group.Brand, group.Name, group.EAN, group.Price} {
+			if index <= 0 || len(row) < index {
+				continue
+			}
+			value := strings.ToLower(strings.TrimSpace(row[index-1]))
+			for _, marker := range markers {
+				marker = strings.ToLower(strings.TrimSpace(marker))
+				if marker == "" || !strings.HasPrefix(value, marker) {
+					continue
+				}
+				rest := []rune(value[len(marker):])
+				if len(rest) == 0 || !unicode.IsLetter(rest[0]) {
+					return marker, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// Длина колонки raw_key в символах
+const rawKeyMaxLength = 512
+
+// Сырой ключ строки: article и brand без нормализации, через разделитель.
+// Ключ длиннее колонки raw_key заменяется его SHA-256: обрезанный ключ
+// совпал бы у разных товаров, а строгий режим MySQL не дал бы его записать.
+func rawKeyOf(article, brand string) string {
+	key := strings.TrimSpace(article) + "\x1f" + strings.TrimSpace(brand)
+	if utf8.RuneCountInString(key) <= rawKeyMaxLength {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Солёный хэш для различных товаров, чьи нормализованные значения совпали
+func saltHash(hash, rawKey string) string {
+	salted := sha256.Sum256([]byte(hash + rawKey))
+	return hex.EncodeToString(salted[:])
+}
+
+// Поиск строки заголовка: первая строка, содержащая все требуемые названия колонок.
+// Возвращает номер строки (с нуля) и найденные индексы колонок (с единицы).
+func findHeaderRow(rows [][]string, headers HeaderSettings) (int, ColumnSettings, error) {
+	for i, row := range rows {
+		settings, ok, err := resolveHeaderColumns(row, headers)
+		if err != nil {
+			return i, ColumnSettings{}, fmt.Errorf("строка %d: %w", i+1, err)
+		}
+		if ok {
+			return i, settings, nil
+		}
+	}
+	return -1, ColumnSettings{}, errHeaderNotFound
+}
+
+// Определение индексов колонок по названиям в строке заголовка
+func resolveHeaderColumns(row []string, headers HeaderSettings) (ColumnSettings, bool, error) {
+	var ambiguous []string
+
+	index := func(header string) int {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			return 0
+		}
+		var matches []int
+		for i, cell := range row {
+			if strings.EqualFold(strings.TrimSpace(cell), header) {
+				matches = append(matches, i+1)
+			}
+		}
+		if len(matches) == 0 {
+			return 0
+		}
+		if len(matches) > 1 {
+			switch headers.Duplicates {
+			case DuplicateHeadersLast:
+				return matches[len(matches)-1]
+			case DuplicateHeadersError:
+				letters := make([]string, 0, len(matches))
+				for _, col := range matches {
+					letter, _ := excelize.ColumnNumberToName(col)
+					letters = append(letters, letter)
+				}
+				ambiguous = append(ambiguous, fmt.Sprintf("'%s' в колонках %s", header, strings.Join(letters, ", ")))
+			}
+		}
+		return matches[0]
+	}
+
+	settings := ColumnSettings{
+		Brand:   index(headers.Brand),
+		Article: index(headers.Article),
+		Name:    index(headers.Name),
+		Date:    index(headers.Date),
+		EAN:     index(headers.EAN),
+		Price:   index(headers.Price),
+	}
+	// Строка подходит, если в ней есть все заданные заголовки. Незаданные колонки
+	// берутся из номеров в конфигурации (или не нужны, как бренд при brand_from_sheet).
+	found := func(index int, header string) bool { return index > 0 || strings.TrimSpace(header) == "" }
+	ok := !headers.empty() &&
+		found(settings.Brand, headers.Brand) && found(settings.Article, headers.Article) &&
+		found(settings.Name, headers.Name) && found(settings.Date, headers.Date) &&
+		found(settings.EAN, headers.EAN) && found(settings.Price, headers.Price)
+	if ok && len(ambiguous) > 0 {
+		return ColumnSettings{}, false, fmt.Errorf("неоднозначный заголовок %s", strings.Join(ambiguous, "; "))
+	}
+	return settings, ok, nil
+}
+
+// Чтение листа потоком: строк начала листа, в которых ищутся шапка, заголовок
+// и колонки при автоопределении, и размер последующих порций
+const (
+	streamHeadRows  = 1000
+	streamBatchRows = 1000
+)
+
+// Обработка одного прайс-листа (xlsx, xlsb, xls, ods, csv или таблицы Google) с учетом конфигурации
+func processXLSXFileWithConfig(db *gorm.DB, filePath string, fc FileConfig, open func() (workbook, error)) error {
+	f, err := open()
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	sheetList := f.Sheets()
+	if len(sheetList) == 0 {
+		return fmt.Errorf("файл %s не содержит листов", filePath)
+	}
+
+	flog := newFileLogger(filePath)
+	defer flog.Flush()
+
+	flog.Println("Начата обработка файла ", filePath)
+
+	// Колонки, описанные поставщиком в самом файле, важнее config.json
+	if found, err := applyConfigSheet(f, &fc); err != nil {
+		return fmt.Errorf("файл %s: %w", filePath, err)
+	} else if found {
+		flog.Printf("Колонки заданы листом %s: %+v\n", configSheetName, fc.Columns)
+	}
+
+	rules := rulesFor(fc)
+	stats := &fileStats{File: filePath}
+
+	dates, err := dateRulesFor(fc)
+	if err != nil {
+		return fmt.Errorf("файл %s: %w", filePath, err)
+	}
+
+	// В обратном режиме листы (а ниже строки и группы) идут с конца
+	if *flagReverse {
+		sheetList = slices.Clone(sheetList)
+		slices.Reverse(sheetList)
+	}
+
+	// Проходим по всем листам
+	for _, currentSheet := range sheetList {
+		if currentSheet == configSheetName {
+			continue // Служебный лист не содержит данных
+		}
+		if !fc.Sheets.included(currentSheet) {
+			flog.Printf("Лист %s пропущен по настройкам sheets\n", currentSheet)
+			continue
+		}
+		sheetConfig := fc.forSheet(currentSheet)
+
+		// Бренд без колонки: заданный для листа или файла, иначе имя листа
+		fixedBrand := sheetConfig.Brand
+		if fixedBrand == "" && fc.BrandFromSheet {
+			fixedBrand = currentSheet
+		}
+
+		// Минимальное число заполненных колонок в строке данных
+		minColumns := 3
+		if fixedBrand != "" {
+			minColumns = 2
+		}
+
+		// Лист читается потоком: в памяти только начало листа (для шапки, заголовка
+		// и автоопределения колонок) и очередная порция строк. Обратному порядку
+		// и max_rows нужен весь лист сразу. Типы ячеек (canonical_cell_types),
+		// исходные значения дат (-since) и формулы (evaluate_formulas) читаются
+		// по ячейке, а это загружает лист xlsx в память целиком, так что с ними
+		// поток ничего не экономит — лист читается сразу.
+		headLimit := streamHeadRows
+		if *flagReverse || fc.MaxRows > 0 || fc.CanonicalCellTypes || fc.EvaluateFormulas || !sinceCutoff.IsZero() {
+			headLimit = -1
+		}
+		var rows [][]string
+		stream, err := f.StreamRows(currentSheet)
+		reader := &sheetReader{stream: stream}
+		hidden, _ := stream.(*hiddenRowStream) // Скрытые строки при skip_hidden
+		if err == nil {
+			rows, err = reader.read(headLimit)
+			if err != nil {
+				stream.Close()
+			}
+		}
+		if err != nil {
+			if config.SheetErrorPolicy == SheetErrorAbort {
+				return fmt.Errorf("не удалось прочитать лист %s в файле %s: %w", currentSheet, filePath, err)
+			}
+			flog.Printf("Лист %s пропущен: не удалось прочитать: %v\n", currentSheet, err)
+			continue
+		}
+
+		// Колонки по умолчанию берутся из конфигурации, а в режиме заголовков
+		// определяются по найденной строке заголовка; данные идут после неё
+		settings := sheetConfig.Columns
+		firstRow := 0 // Индекс первой строки данных на листе
+		headerFound := false
+
+		// Шапка листа фиксированной высоты пропускается до поиска заголовка
+		if skip := sheetConfig.HeaderRows; skip > 0 {
+			skip = min(skip, len(rows))
+			rows = rows[skip:]
+			firstRow += skip
+		}
+
+		// Колонки не заданы — определяем по содержимому, служебные строки
+		// в начале листа пропускаются как при auto_skip_rows
+		if sheetConfig.Autodetect && settings == (ColumnSettings{}) && sheetConfig.headerSettings() == nil {
+			detected, confidence := detectColumns(rows, autodetectSampleRows)
+			if confidence < autodetectMinConfidence {
+				log.Printf("Файл %s, лист %s пропущен: колонки не определены автоматически (уверенность %.2f)\n",
+					filePath, currentSheet, confidence)
+				stream.Close()
+				continue
+			}
+			log.Printf("Файл %s, лист %s: колонки определены автоматически: артикул %d, бренд %d, название %d (уверенность %.2f)\n",
+				filePath, currentSheet, detected.Article, detected.Brand, detected.Name, confidence)
+			settings = detected
+			sheetConfig.AutoSkipRows = true
+		}
+		if headers := sheetConfig.headerSettings(); headers != nil {
+			headerRow, resolved, err := findHeaderRow(rows, *headers)
+			switch {
+			case err == nil:
+				settings = settings.merge(resolved)
+				rows = rows[headerRow+1:]
+				firstRow += headerRow + 1
+				headerFound = true
+			case errors.Is(err, errHeaderNotFound) && settings.Article > 0 && settings.Name > 0:
+				// Заголовка нет, но номера колонок заданы — работаем по ним
+				flog.Printf("Лист %s: строка заголовка не найдена, используются номера колонок из конфигурации\n", currentSheet)
+			default:
+				flog.Printf("Не удалось определить колонки на листе %s в файле %s: %v\n", currentSheet, filePath, err)
+				stream.Close()
+				continue
+			}
+			if settings.Article <= 0 || settings.Name <= 0 {
+				flog.Printf("Не удалось определить колонки на листе %s в файле %s: не заданы колонки артикула и названия\n", currentSheet, filePath)
+				stream.Close()
+				continue
+			}
+		}
+		if !headerFound && sheetConfig.AutoSkipRows {
+			skip := firstDataRow(rows, settings, fixedBrand != "")
+			if skip > 0 {
+				flog.Printf("Лист %s: пропущено служебных строк в начале: %d\n", currentSheet, skip)
+			}
+			rows = rows[skip:]
+			firstRow += skip
+		}
 
-// Структура для хранения настроек колонок
-type ColumnSettings struct {
-	Brand   int `json:"brand"`   // Индекс колонки для бренда
-	Article int `json:"article"` // Индекс колонки для артикула
-	Name    int `json:"name"`    // Индекс колонки для названия
-}
+		// Защитная блокировка для файлов-дельт: слишком большой файл, скорее всего,
+		// прислан по ошибке (например, полный каталог вместо изменений)
+		if fc.MaxRows > 0 && stats.Rows+len(rows) > fc.MaxRows {
+			stream.Close()
+			return fmt.Errorf("файл %s содержит больше %d строк данных (лист %s), обработка прервана",
+				filePath, fc.MaxRows, currentSheet)
+		}
 
-// Структура для хранения информации о каждом файле
-type FileConfig struct {
-	Filename string         `json:"filename"` // Имя файла
-	Columns  ColumnSettings `json:"columns"`  // Настройки колонок
-}
+		// Группы колонок: по умолчанию одна, из настроек колонок листа
+		groups := []ColumnSettings{settings}
+		if len(fc.Groups) > 0 {
+			groups = fc.Groups
+		}
+		if *flagReverse {
+			groups = slices.Clone(groups)
+			slices.Reverse(groups)
+		}
 
-// Глобальная структура для хранения всех настроек
-type Config struct {
-	Files []FileConfig `json:"files"` // Список файлов и их настроек
-}
+		// Обработка одной строки листа (rowNum — номер строки на листе с единицы).
+		// Может выполняться параллельно для разных строк, см. row_workers.
+		handleRow := func(row []string, rowNum int) error {
+			stats.countRow()
+			counters.Rows.Add(1)
 
-var mu sync.Mutex
-var wg sync.WaitGroup
-var config Config
-var products []Product
+			if hidden != nil && hidden.hiddenRow(rowNum) {
+				flog.Printf("Лист %s, строка %d: пропущена скрытая строка\n", currentSheet, rowNum)
+				return nil
+			}
 
-func main() {
-	// Чтение конфигурационного файла
-	configData, err := os.ReadFile("./config.json")
-	if err != nil {
-		log.Fatalf("Не удалось прочитать конфигурационный файл: %v", err)
-	}
+			// Итоговые строки вроде «Итого» не товар, даже если в колонке цены есть число
+			if marker, ok := summaryRowMarker(row, groups, fc.SummaryMarkers); ok {
+				flog.Printf("Лист %s, строка %d: пропущена итоговая строка (%q)\n", currentSheet, rowNum, marker)
+				return nil
+			}
 
-	if err := json.Unmarshal(configData, &config); err != nil {
-		log.Fatalf("Ошибка парсинга конфигурационного файла: %v", err)
-	}
+			if len(row) < minColumns {
+				// Пропускаем строки, где недостаточно данных
+				if err := stats.reject(currentSheet, rowNum, "недостаточно данных", row, config.RejectLimits); err != nil {
+					return abortOnRejects(flog, filePath, err)
+				}
+				return nil
+			}
 
-	// Подключение к временной MySQL базе для обработки данных
-	dsn := "root:1234@tcp(127.0.0.1:3306)/testdb?charset=utf8mb4&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Не удалось подключиться к базе данных: %v", err)
-	}
+			// Фильтр по дате изменения строки
+			if !sinceCutoff.IsZero() && settings.Date > 0 {
+				modified, err := rowDate(f, currentSheet, settings.Date, rowNum, dates)
+				if err != nil {
+					if err := stats.reject(currentSheet, rowNum, "не удалось разобрать дату: "+err.Error(), row, config.RejectLimits); err != nil {
+						return abortOnRejects(flog, filePath, err)
+					}
+					return nil
+				}
+				if modified.Before(sinceCutoff) {
+					return nil // Строка не менялась с момента отсечки
+				}
+			}
 
-	// Очистка таблицы перед началом работы
-	if err := clearTable(db, "products"); err != nil {
-		fmt.Printf("Таблица не найдена. Очистка не требуется: %v\n", err)
-	}
+			// Строка может содержать несколько товаров в повторяющихся группах колонок
+			for _, group := range groups {
+				// Проверяем существование всех необходимых ключей
+				var rawBrand string
+				var rawArticle string
+				var rawName string
+				var rawEAN string
+				var rawPrice string
 
-	// Настройка пула соединений
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatal("не удалось получить доступ к базовым соединениям:", err)
-	}
-	sqlDB.SetMaxOpenConns(50)                 // Максимум 50 открытых соединений
-	sqlDB.SetMaxIdleConns(20)                 // Максимум 20 простаивающих соединений
-	sqlDB.SetConnMaxLifetime(time.Minute * 5) // Время жизни соединения
+				// Извлекаем значения согласно конфигурации
+				if fixedBrand != "" {
+					rawBrand = fixedBrand // Бренд задан листом или настройками
+				} else if group.Brand > 0 && len(row) >= group.Brand { // проверка наличия элемента для Brand
+					rawBrand = row[group.Brand-1]
+					if fc.CanonicalCellTypes {
+						rawBrand = canonicalCell(f, currentSheet, group.Brand, rowNum, rawBrand)
+					}
+				}
 
-	db.Logger = logger.Default.LogMode(logger.Silent)
+				if group.Article > 0 && len(row) >= group.Article { // проверка наличия элемента для Article
+					rawArticle = row[group.Article-1]
+					if fc.CanonicalCellTypes {
+						rawArticle = canonicalCell(f, currentSheet, group.Article, rowNum, rawArticle)
+					}
+				}
 
-	startTime := time.Now() // Запоминаем начальное время
+				if group.Name > 0 && len(row) >= group.Name { // проверка наличия элемента для Name
+					rawName = row[group.Name-1]
+				}
 
-	// Создание таблицы, если её нет
-	err = db.AutoMigrate(&Product{})
-	if err != nil {
-		log.Fatalf("Не удалось создать таблицу: %v", err)
-	}
+				if group.EAN > 0 && len(row) >= group.EAN { // проверка наличия элемента для EAN
+					rawEAN = row[group.EAN-1]
+				}
 
-	dirPath := "./prices" // Путь к директории с файлами
-	files, err := os.ReadDir(dirPath)
-	if err != nil {
-		log.Fatalf("Не удалось прочитать директорию: %v", err)
-	}
+				if group.Price > 0 && len(row) >= group.Price { // проверка наличия элемента для Price
+					rawPrice = row[group.Price-1]
+				}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".xlsx" {
-			filePath := filepath.Join(dirPath, file.Name())
+				// Пустые группы колонок в многотоварной строке пропускаются
+				if len(fc.Groups) > 0 && strings.TrimSpace(rawArticle) == "" && strings.TrimSpace(rawName) == "" {
+					continue
+				}
 
-			if !isValidFile(filePath) {
-				log.Printf("Файл '%s' не найден или недействителен.\n", filePath)
-				continue
-			}
+				// Некорректная кодировка: очищаем ячейки или отклоняем строку
+				if invalid := sanitizeUTF8(&rawBrand, &rawArticle, &rawName, &rawEAN); invalid {
+					flog.Printf("Некорректная UTF-8 последовательность в файле %s, лист %s, строка %d\n",
+						filePath, currentSheet, rowNum)
+					if config.InvalidUTF8Policy == InvalidUTF8Reject {
+						if err := stats.reject(currentSheet, rowNum, "некорректная кодировка", row, config.RejectLimits); err != nil {
+							return abortOnRejects(flog, filePath, err)
+						}
+						continue
+					}
+				}
+
+				// Защита от патологически длинных ячеек
+				if overlong := guardCellLengths(&rawBrand, &rawArticle, &rawName, &rawEAN); overlong {
+					flog.Printf("Слишком длинная ячейка в файле %s, лист %s, строка %d (лимит %d символов)\n",
+						filePath, currentSheet, rowNum, config.MaxCellLength)
+					if config.CellLengthPolicy == CellLengthReject {
+						if err := stats.reject(currentSheet, rowNum, "слишком длинная ячейка", row, config.RejectLimits); err != nil {
+							return abortOnRejects(flog, filePath, err)
+						}
+						continue
+					}
+				}
+
+				// Числовой артикул, потерявший точность при хранении как число
+				if fc.ArticlePrecisionCheck != "" {
+					if problem := articlePrecisionLoss(rawArticle); problem != "" {
+						flog.Printf("Возможная потеря точности артикула %q в файле %s, лист %s, строка %d: %s\n",
+							rawArticle, filePath, currentSheet, rowNum, problem)
+						if fc.ArticlePrecisionCheck == PrecisionCheckReject {
+							if err := stats.reject(currentSheet, rowNum, "потеря точности артикула: "+problem, row, config.RejectLimits); err != nil {
+								return abortOnRejects(flog, filePath, err)
+							}
+							continue
+						}
+					}
+				}
+
+				brand := normalizeBrand(rawBrand)                                      // Нормализуем бренд
+				article := normalizeArticle(rawArticle, rules)                         // Нормализуем артикул
+				name := stripNameWrappers(strings.TrimSpace(rawName), fc.NameWrappers) // Очищаем название
+
+				// Значения по умолчанию для пустых колонок
+				fc.ColumnDefaults.apply(&brand, &article, &name, rules)
+
+				// Строгий режим: неполные записи отклоняются, а не сохраняются частично
+				if fc.RequireAllColumns {
+					if missing := missingColumns(brand, article, name); missing != "" {
+						if err := stats.reject(currentSheet, rowNum, "пустые колонки: "+missing, row, config.RejectLimits); err != nil {
+							return abortOnRejects(flog, filePath, err)
+						}
+						continue
+					}
+				}
 
-			// Поиск настроек для текущего файла
-			var foundConfig *FileConfig
-			for _, fc := range config.Files {
-				if fc.Filename == file.Name() {
-					foundConfig = &fc
-					break
+				// Сравнение правил нормализации: только учитываем хэши, базу не трогаем
+				if comparison != nil {
+					comparison.record(rawArticle, rawBrand, article, brand, rules)
+					continue
 				}
+
+				storeProduct(db, flog, rowProduct{
+					Article:  storedForm(rawArticle, article, config.StoredForm.Article),
+					Brand:    storedForm(rawBrand, brand, config.StoredForm.Brand),
+					Name:     name,
+					Hash:     generateHash(article, brand, rules), // Хэш для комбинации article + brand
+					RawKey:   rawKeyOf(rawArticle, rawBrand),
+					EAN:      normalizeEAN(rawEAN),
+					Price:    parsePrice(rawPrice),
+					Priority: fc.Priority,
+					Extra:    customValues(row, fc, rules),
+					File:     filePath,
+					Sheet:    currentSheet,
+					Row:      rowNum,
+				})
 			}
+			return nil
+		}
 
-			if foundConfig == nil {
-				log.Printf("Настройки для файла '%s' не найдены в конфигурации.\n", file.Name())
-				continue
+		// Первая порция — оставшееся начало листа, дальше лист дочитывается
+		// порциями по streamBatchRows строк
+		batch, batchStart := rows, firstRow
+		for {
+			err := forEachRow(len(batch), rowWorkers(fc), *flagReverse, func(i int) error {
+				return handleRow(batch[i], batchStart+i+1)
+			})
+			if err != nil {
+				stream.Close()
+				return err
 			}
+			if reader.finished() {
+				break
+			}
+			batchStart += len(batch)
+			if batch, err = reader.read(streamBatchRows); err != nil {
+				stream.Close()
+				return fmt.Errorf("не удалось прочитать лист %s в файле %s: %w", currentSheet, filePath, err)
+			}
+		}
+		stream.Close()
+	}
 
-			wg.Add(1) // Добавляем задачу в группу ожидания
-			go func(filePath string, settings ColumnSettings) {
-				defer wg.Done() // Отмечаем задачу как выполненную после завершения
-				processXLSXFileWithConfig(db, filePath, settings)
-			}(filePath, foundConfig.Columns)
+	flog.Println("Закончена обработка файла ", filePath)
+	return nil
+}
+
+// Товар, извлечённый из строки исходного файла
+type rowProduct struct {
+	Article  string
+	Brand    string
+	Name     string
+	Hash     string
+	RawKey   string
+	EAN      string            // Штрихкод (пусто, если нет)
+	Price    *float64          // Цена (nil, если неизвестна)
+	Priority int               // Приоритет файла-источника
+	Extra    map[string]string // Значения дополнительных колонок
+	File     string            // Источник: файл, лист и номер строки
+	Sheet    string
+	Row      int
+}
+
+// Происхождение строки для колонки source: "vendorX.xlsx!Sheet1!R42"
+func (p rowProduct) source() string {
+	return fmt.Sprintf("%s!%s!R%d", filepath.Base(p.File), p.Sheet, p.Row)
+}
+
+// Сохранение товара: поиск записи по хэшу, создание новой или обновление названия
+func storeProduct(db *gorm.DB, flog *fileLogger, p rowProduct) {
+	// Солёные варианты хэша выводятся из исходного, поэтому достаточно блокировки по нему.
+	// При dedup_by_ean ещё и по штрихкоду: строки с одним EAN и разными article+brand
+	// иначе обе не найдут запись по ean и обе её создадут.
+	locks := []string{p.Hash}
+	if config.DedupByEAN && p.EAN != "" {
+		locks = append(locks, "ean:"+p.EAN)
+	}
+	defer lockHash(locks...)()
+
+	// Штрихкод надёжнее article+brand: если запись с таким EAN уже есть, это тот же товар
+	if config.DedupByEAN && p.EAN != "" {
+		var byEAN Product
+		err := withConnRetry(func() error {
+			return db.Where("ean = ?", p.EAN).First(&byEAN).Error
+		})
+		if err == nil {
+			if *flagVerify {
+				p.Hash = byEAN.Hash
+				recordVerify(p)
+				return
+			}
+			updateProduct(db, flog, p, byEAN)
+			return
 		}
 	}
 
-	// Ждём завершения всех горутин
-	wg.Wait()
+	// Ищем существующую запись по хэшу
+	var existing Product
+	lookupErr := withConnRetry(func() error {
+		return findByHash(db, p.Hash, &existing)
+	})
 
-	// Экспорт данных в SQL файл
-	exportToSQLFile(db, "output.sql")
+	// Тот же article+brand, но другой штрихкод — отдельный товар с солёным хэшем
+	if config.DedupByEAN && p.EAN != "" && existing.ID != 0 && existing.EAN != "" && existing.EAN != p.EAN {
+		p.Hash = saltHash(p.Hash, "ean:"+p.EAN)
+		existing = Product{}
+		lookupErr = withConnRetry(func() error {
+			return findByHash(db, p.Hash, &existing)
+		})
+	}
 
-	elapsedTime := time.Since(startTime) // Вычисляем время выполнения
-	fmt.Printf("Время выполнения (форматированный вывод): %.2f секунд\n", elapsedTime.Seconds())
-	fmt.Println("Время выполнения (стандарный вывод):", elapsedTime)
+	// Хэш совпал, но сырые значения различаются — это другой товар,
+	// который нормализация свела к тому же ключу. Переходим на солёный хэш.
+	if config.VerifyRawKey && existing.ID != 0 && existing.RawKey != p.RawKey {
+		flog.Printf("Совпадение хэша при разных исходных значениях: id=%d, raw=%q, new_raw=%q\n",
+			existing.ID, existing.RawKey, p.RawKey)
+		p.Hash = saltHash(p.Hash, p.RawKey)
+		existing = Product{}
+		lookupErr = withConnRetry(func() error {
+			return findByHash(db, p.Hash, &existing)
+		})
+	}
 
-	fmt.Scanln()
-}
+	// В режиме проверки только запоминаем ожидаемую запись, не изменяя таблицу
+	if *flagVerify {
+		recordVerify(p)
+		return
+	}
 
-// Функция для проверки существования файла
-func isValidFile(filePath string) bool {
-	info, err := os.Stat(filePath)
-	if err != nil {
-		return false
+	if errors.Is(lookupErr, gorm.ErrRecordNotFound) {
+		// Проверяем, есть ли запись с такими же article и brand
+		var duplicate Product
+		if db.Where("article = ? AND brand = ?", p.Article, p.Brand).Find(&duplicate).RowsAffected > 0 {
+			flog.Printf("Найдена запись с такими же article и brand, но другим хэшем: id=%d, hash=%s, expected_hash=%s\n",
+				duplicate.ID, duplicate.Hash, p.Hash)
+		}
 	}
-	return !info.IsDir()
-}
 
-// Функция для очистки таблицы
-func clearTable(db *gorm.DB, tableName string) error {
-	// Проверяем, существует ли таблица
-	if !db.Migrator().HasTable(tableName) {
-		return fmt.Errorf("таблица '%s' не существует", tableName)
+	if existing.ID == 0 {
+		// Создаем новую запись, если она еще не существует
+		product := Product{Article: p.Article, Brand: p.Brand, Name: p.Name, Hash: p.Hash, RawKey: p.RawKey, EAN: p.EAN, Price: p.Price, Priority: p.Priority, Source: p.source()}
+		err := withConnRetry(func() error {
+			return db.Create(&product).Error
+		})
+		if err == nil {
+			err = withConnRetry(func() error {
+				return storeCustomValues(db, product.ID, p.Extra, false)
+			})
+		}
+		if err != nil {
+			flog.Printf("Не удалось создать запись hash=%s (файл %s, строка %d): %v\n", p.Hash, p.File, p.Row, err)
+			return
+		}
+		hashCache.add(p.Hash, product.ID)
+		counters.Inserted.Add(1)
+		return
 	}
 
-	// Очищаем таблицу, если она существует
-	return db.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`", tableName)).Error
+	updateProduct(db, flog, p, existing)
 }
 
-// Глубокая очистка строки от всех нежелательных символов
-func deepClean(value string) string {
-	// Удаляем все пробельные символы (включая табуляции и переносы строк)
-	value = strings.TrimSpace(value)
-	value = strings.ReplaceAll(value, "\t", "")
-	value = strings.ReplaceAll(value, "\n", "")
-	value = strings.ReplaceAll(value, "\r", "")
+// Обновление найденной записи: название и цена выбираются каждое по своему правилу
+// (name_policy, price_policy), а штрихкод дописывается, если у записи его ещё не было
+func updateProduct(db *gorm.DB, flog *fileLogger, p rowProduct, existing Product) {
+	updates := map[string]any{}
+	if preferName(existing.Name, p.Name, existing.Priority, p.Priority) {
+		updates["name"] = p.Name
+		updates["priority"] = p.Priority
+		updates["source"] = p.source()
+	}
+	if preferPrice(existing.Price, p.Price) {
+		updates["price"] = *p.Price
+	}
+	if p.EAN != "" && existing.EAN == "" {
+		updates["ean"] = p.EAN
+	}
 
-	// Преобразуем в нижний регистр
-	value = strings.ToLower(value)
+	// Поля, совпадающие с сохранёнными, не пишем: повторный импорт неизменного
+	// файла не должен трогать запись и сдвигать updated_at
+	dropUnchanged(updates, existing)
 
-	// Удаляем все специальные символы (оставляем только буквы и цифры)
-	value = removeNonAlphanumeric(value)
+	// Дополнительные колонки дозаполняются, только если у записи их ещё нет
+	if err := withConnRetry(func() error {
+		return storeCustomValues(db, existing.ID, p.Extra, true)
+	}); err != nil {
+		flog.Printf("Не удалось записать дополнительные колонки hash=%s (файл %s, строка %d): %v\n", existing.Hash, p.File, p.Row, err)
+	}
 
-	return value
+	if len(updates) == 0 {
+		counters.Duplicates.Add(1)
+		return
+	}
+
+	// Updates через Model сам проставляет updated_at; UpdateColumns оставляет его прежним
+	mu.Lock()
+	err := withConnRetry(func() error {
+		query := db.Model(&Product{}).Where("hash = ?", existing.Hash)
+		if *flagBumpUpdatedAt {
+			return query.Updates(updates).Error
+		}
+		return query.UpdateColumns(updates).Error
+	})
+	mu.Unlock()
+	if err != nil {
+		flog.Printf("Не удалось обновить запись hash=%s (файл %s, строка %d): %v\n", existing.Hash, p.File, p.Row, err)
+		return
+	}
+	counters.Updated.Add(1)
 }
 
-// Удаление всех не буквенно-цифровых символов
-func removeNonAlphanumeric(value string) string {
-	result := ""
-	for _, char := range value {
-		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') {
-			result += string(char)
+// Удаление из набора обновлений полей, значение которых уже совпадает с сохранённым
+func dropUnchanged(updates map[string]any, existing Product) {
+	// Приоритет и происхождение записываются только вместе с названием
+	if name, ok := updates["name"]; ok && name == existing.Name {
+		delete(updates, "name")
+		delete(updates, "priority")
+		delete(updates, "source")
+	}
+	if price, ok := updates["price"].(float64); ok && existing.Price != nil && price == *existing.Price {
+		delete(updates, "price")
+	}
+	if ean, ok := updates["ean"]; ok && ean == existing.EAN {
+		delete(updates, "ean")
+	}
+}
+
+// Нормализация штрихкода: остаются только цифры (пробелы, апострофы и дефисы удаляются)
+func normalizeEAN(ean string) string {
+	var digits strings.Builder
+	for _, char := range ean {
+		if char >= '0' && char <= '9' {
+			digits.WriteRune(char)
 		}
 	}
-	return result
+	return digits.String()
 }
 
-// Генерация хэша для комбинации article + brand
-func generateHash(article, brand string) string {
-	article = deepClean(article)
-	brand = deepClean(brand)
-	hashInput := article + brand
-	hash := sha256.Sum256([]byte(hashInput))
-	return hex.EncodeToString(hash[:])
+// Перечень пустых обязательных колонок (пустая строка, если все заполнены)
+func missingColumns(brand, article, name string) string {
+	var missing []string
+	if article == "" {
+		missing = append(missing, "article")
+	}
+	if brand == "" {
+		missing = append(missing, "brand")
+	}
+	if name == "" {
+		missing = append(missing, "name")
+	}
+	return strings.Join(missing, ", ")
 }
 
-// Обработка одного xlsx файла с учетом конфигурации
-func processXLSXFileWithConfig(db *gorm.DB, filePath string, settings ColumnSettings) {
-	f, err := excelize.OpenFile(filePath)
-	if err != nil {
-		log.Printf("Не удалось открыть файл %s: %v\n", filePath, err)
-		return
+// Прекращение обработки файла (или всего запуска) при превышении порога отклонённых строк
+func abortOnRejects(flog *fileLogger, filePath string, err error) error {
+	if config.RejectLimits.AbortRun {
+		flog.Flush()
+		log.Fatalf("Запуск остановлен при обработке файла %s: %v", filePath, err)
 	}
+	return fmt.Errorf("обработка файла %s прервана: %w", filePath, err)
+}
 
-	sheetList := f.GetSheetList()
-	if len(sheetList) == 0 {
-		log.Printf("Файл %s не содержит листов.\n", filePath)
-		return
+// Нормализация артикула (убираем специальные символы и преобразуем в нижний регистр)
+func normalizeArticle(article string, rules NormalizeRules) string {
+	cleaned := strings.TrimSpace(stripInvisible(article)) // Удаляем невидимые символы и лишние пробелы
+	if !rules.CaseSensitiveArticle {
+		cleaned = strings.ToLower(cleaned)
+	}
+	for _, char := range rules.ArticleStripChars {
+		cleaned = strings.ReplaceAll(cleaned, char, "")
 	}
+	return cleaned
+}
 
-	fmt.Println("Начата обработка файла ", filePath)
+// Проверка длины ячеек относительно config.MaxCellLength. Слишком длинные значения
+// обрезаются на месте (при политике reject строку затем отклоняет вызывающий код).
+func guardCellLengths(values ...*string) bool {
+	limit := config.MaxCellLength
+	if limit <= 0 {
+		return false
+	}
 
-	// Проходим по всем листам
-	for _, currentSheet := range sheetList {
-		rows, err := f.GetRows(currentSheet)
-		if err != nil {
-			log.Printf("Не удалось прочитать лист %s в файле %s: %v\n", currentSheet, filePath, err)
-			return
+	overlong := false
+	for _, value := range values {
+		if len(*value) <= limit { // Быстрая проверка: байтов не больше лимита — символов тоже
+			continue
 		}
+		chars := []rune(*value)
+		if len(chars) > limit {
+			*value = string(chars[:limit])
+			overlong = true
+		}
+	}
+	return overlong
+}
+
+// Удаление некорректных UTF-8 последовательностей из значений.
+// Возвращает true, если хотя бы одно значение пришлось очистить.
+func sanitizeUTF8(values ...*string) bool {
+	invalid := false
+	for _, value := range values {
+		if !utf8.ValidString(*value) {
+			*value = strings.ToValidUTF8(*value, "")
+			invalid = true
+		}
+	}
+	return invalid
+}
 
-		for _, row := range rows {
-			if len(row) < 3 {
-				continue // Пропускаем строки, где недостаточно данных
+// Снятие парных обрамляющих символов с названия, в том числе многократного: «[Диск]» → Диск
+func stripNameWrappers(name string, pairs []string) string {
+	for {
+		stripped := false
+		for _, pair := range pairs {
+			chars := []rune(pair)
+			if len(chars) != 2 {
+				continue
+			}
+			if inner, ok := unwrap(name, chars[0], chars[1]); ok {
+				name = strings.TrimSpace(inner)
+				stripped = true
 			}
+		}
+		if !stripped {
+			return name
+		}
+	}
+}
 
-			// Проверяем существование всех необходимых ключей
-			var brand string
-			var article string
-			var name string
+// Снятие обрамления, если открывающий символ в начале строки парен закрывающему в конце.
+// Для строки «А» и «Б» обрамление не снимается: первая кавычка закрывается раньше.
+func unwrap(value string, open, close rune) (string, bool) {
+	chars := []rune(value)
+	if len(chars) < 2 || chars[0] != open || chars[len(chars)-1] != close {
+		return value, false
+	}
 
-			// Извлекаем значения согласно конфигурации
-			if len(row) > settings.Brand-1 { // проверка наличия элемента для Brand
-				brand = normalizeBrand(row[settings.Brand-1]) // Нормализуем бренд
+	inner := chars[1 : len(chars)-1]
+	depth := 0
+	for _, char := range inner {
+		switch {
+		case open == close && char == open:
+			return value, false
+		case char == open:
+			depth++
+		case char == close:
+			depth--
+			if depth < 0 {
+				return value, false
 			}
+		}
+	}
+	return string(inner), depth == 0
+}
 
-			if len(row) > settings.Article-1 { // проверка наличия элемента для Article
-				article = normalizeArticle(row[settings.Article-1]) // Нормализуем артикул
-			}
+// Нормализация бренда (удаляем невидимые символы, схлопываем пробелы,
+// преобразуем в нижний регистр)
+func normalizeBrand(brand string) string {
+	return strings.ToLower(collapseSpaces(stripInvisible(brand)))
+}
 
-			if len(row) > settings.Name-1 { // проверка наличия элемента для Name
-				name = strings.TrimSpace(row[settings.Name-1]) // Очищаем название
-			}
+// Удаление невидимых символов Unicode: форматирующих (пробелы нулевой ширины,
+// метки направления текста, BOM) и управляющих, кроме пробельных
+func stripInvisible(value string) string {
+	return strings.Map(func(char rune) rune {
+		if unicode.Is(unicode.Cf, char) || (unicode.IsControl(char) && !unicode.IsSpace(char)) {
+			return -1
+		}
+		return char
+	}, value)
+}
 
-			// Генерируем хэш для комбинации article + brand
-			hash := generateHash(article, brand)
+// Хранимое значение колонки: normalized — форма для сопоставления (она же в хэше),
+// lower и trim — исходное значение с меньшей обработкой. Если исходное значение
+// пусто (например, подставлено значение по умолчанию), хранится нормализованное.
+func storedForm(raw, normalized, mode string) string {
+	value := strings.TrimSpace(stripInvisible(raw))
+	if value == "" {
+		return normalized
+	}
+	switch mode {
+	case StoredFormLower:
+		return strings.ToLower(value)
+	case StoredFormTrim:
+		return value
+	default:
+		return normalized
+	}
+}
 
-			// Ищем существующую запись по хэшу
-			var existing Product
-			if errors.Is(db.Where("hash = ?", hash).First(&existing).Error, gorm.ErrRecordNotFound) {
-				// Проверяем, есть ли запись с такими же article и brand
-				var duplicate Product
-				if db.Where("article = ? AND brand = ?", article, brand).Find(&duplicate).RowsAffected > 0 {
-					log.Printf("Найдена запись с такими же article и brand, но другим хэшем: id=%d, hash=%s, expected_hash=%s\n",
-						duplicate.ID, duplicate.Hash, hash)
-				}
-			}
+// Замена любых последовательностей пробельных символов Unicode (включая
+// неразрывный пробел) одним пробелом и обрезка по краям
+func collapseSpaces(value string) string {
+	return strings.Join(strings.FieldsFunc(value, unicode.IsSpace), " ")
+}
 
-			// Если записи нет или новое название длиннее, обновляем запись
-			if existing.ID == 0 || len(name) > len(existing.Name) {
-				if existing.ID == 0 {
-					// Создаем новую запись, если она еще не существует
-					db.Create(&Product{Article: article, Brand: brand, Name: name, Hash: hash})
-				} else {
-					// Обновляем запись, если новое название длиннее
-					mu.Lock()
-					db.Model(&Product{}).Where("hash = ?", hash).Update("name", name)
-					mu.Unlock()
-				}
-			}
+// Права файлов выгрузки: заданные или по умолчанию
+func (opts ExportOptions) fileMode() os.FileMode {
+	if opts.FileMode == 0 {
+		return defaultOutputFileMode
+	}
+	return opts.FileMode
+}
+
+// Кавычки идентификаторов колонок выгрузки с заменой имён из export_column_names
+func exportIdent(style string) func(string) string {
+	return func(column string) string {
+		if renamed := config.ExportColumnNames[column]; renamed != "" {
+			column = renamed
 		}
+		return quoteIdent(column, style)
 	}
+}
 
-	fmt.Println("Закончена обработка файла ", filePath)
+// Имя выгружаемой таблицы в кавычках, со схемой при -schema
+func exportTable(opts ExportOptions) string {
+	table := quoteIdent(Product{}.TableName(), opts.IdentQuote)
+	if opts.Schema != "" {
+		table = quoteIdent(opts.Schema, opts.IdentQuote) + "." + table
+	}
+	return table
 }
 
-// Нормализация артикула (убираем специальные символы и преобразуем в нижний регистр)
-func normalizeArticle(article string) string {
-	specialChars := []string{"-", "_", ".", "/", "+", " ", ","}
-	cleaned := strings.ToLower(strings.TrimSpace(article)) // Удаляем лишние пробелы
-	for _, char := range specialChars {
-		cleaned = strings.ReplaceAll(cleaned, char, "")
+// CREATE TABLE выгружаемой таблицы
+func writeCreateTable(writer *bufio.Writer, table string, q func(string) string) {
+	writer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n", table))
+	writer.WriteString(q("id") + " INT AUTO_INCREMENT PRIMARY KEY,\n")
+	writer.WriteString(q("article") + " VARCHAR(255) NOT NULL,\n")
+	writer.WriteString(q("brand") + " VARCHAR(255) NOT NULL,\n")
+	writer.WriteString(q("name") + " VARCHAR(255) NOT NULL,\n")
+	writer.WriteString(q("price") + " DECIMAL(14,2) NULL,\n")
+	for _, col := range config.CustomColumns {
+		writer.WriteString(q(col.Name) + " " + col.sqlType() + " NULL,\n")
 	}
-	return cleaned
+	writer.WriteString(q("updated_at") + " DATETIME NULL\n")
+	writer.WriteString(");\n\n")
 }
 
-// Нормализация бренда (преобразуем в нижний регистр и удаляем пробелы)
-func normalizeBrand(brand string) string {
-	return strings.ToLower(strings.TrimSpace(brand))
+// Выгружаемые колонки в порядке значений: article, brand, name, price, дополнительные, updated_at
+func exportColumns(q func(string) string) []string {
+	columns := []string{q("article"), q("brand"), q("name"), q("price")}
+	for _, col := range config.CustomColumns {
+		columns = append(columns, q(col.Name))
+	}
+	return append(columns, q("updated_at"))
 }
 
-// Экспорт данных в SQL файл
-func exportToSQLFile(db *gorm.DB, outputPath string) {
-	// Проверяем существование файла
-	_, err := os.Stat(outputPath)
-	fileExists := !os.IsNotExist(err)
+// Экспорт данных в SQL файл. Запись идёт во временный файл, который при ошибке
+// удаляется: файл под итоговым именем остаётся прежним.
+func exportToSQLFile(db *gorm.DB, outputPath string, opts ExportOptions) (err error) {
+	var file *atomicFile
+	var archive *zip.Writer
+	var fileExists bool
 
-	// Открываем файл для записи (создаем или открываем для добавления)
-	file, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("Не удалось открыть/создать SQL файл: %v", err)
+	if opts.Archive == ArchiveZip {
+		// Архив не дописывается: каждый раз создаётся заново с output.sql внутри
+		archivePath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".zip"
+		file, err = createAtomic(archivePath, opts.fileMode(), false)
+		if err != nil {
+			return fmt.Errorf("не удалось создать архив: %w", err)
+		}
+	} else {
+		// Проверяем существование файла
+		_, statErr := os.Stat(outputPath)
+		fileExists = !os.IsNotExist(statErr)
+
+		// Дописывание идёт в копию файла, которая заменяет его по окончании выгрузки
+		file, err = createAtomic(outputPath, opts.fileMode(), fileExists)
+		if err != nil {
+			return fmt.Errorf("не удалось открыть/создать SQL файл: %w", err)
+		}
+	}
+	defer func() {
+		if err != nil {
+			file.abort()
+		}
+	}()
+	var out io.Writer = file
+
+	if opts.Archive == ArchiveZip {
+		archive = zip.NewWriter(file)
+		archive.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, opts.CompressionLevel)
+		})
+		out, err = archive.CreateHeader(&zip.FileHeader{Name: filepath.Base(outputPath), Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("не удалось создать файл в архиве: %w", err)
+		}
+	}
+
+	writer := bufio.NewWriterSize(out, 1<<20) // 1 MB буфер
+
+	// Отбор записей выгрузки: вся таблица или один бренд
+	filter := func(tx *gorm.DB) *gorm.DB {
+		if opts.Brand != nil {
+			return tx.Where("brand = ?", *opts.Brand)
+		}
+		return tx
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriterSize(file, 1<<20) // 1 MB буфер
-	defer writer.Flush()
+	// Комментарий с описанием выгрузки
+	if opts.Header {
+		var total int64
+		if err := db.Model(&Product{}).Scopes(filter).Count(&total).Error; err != nil {
+			return fmt.Errorf("ошибка при подсчёте записей: %w", err)
+		}
+		writeExportHeader(writer, total, manifest.completedFiles())
+	}
 
 	// Если файл не существовал, записываем заголовок создания таблицы
-	if !fileExists {
-		tableName := "products"
-		writer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n", tableName))
-		writer.WriteString("`id` INT AUTO_INCREMENT PRIMARY KEY,\n")
-		writer.WriteString("`article` VARCHAR(255) NOT NULL,\n")
-		writer.WriteString("`brand` VARCHAR(255) NOT NULL,\n")
-		writer.WriteString("`name` VARCHAR(255) NOT NULL\n")
-		writer.WriteString(");\n\n")
+	q := exportIdent(opts.IdentQuote)
+	table := exportTable(opts)
+	if !fileExists && !opts.NoCreateTable {
+		writeCreateTable(writer, table, q)
 	}
 
+	// Очистка таблицы перед вставками, чтобы повторное применение выгрузки
+	// полностью заменяло содержимое. Пишется только в начало нового файла:
+	// посреди дописываемого файла она удалила бы вставки предыдущих запусков.
+	if opts.Clear != ExportClearNone {
+		if fileExists {
+			log.Printf("Файл %s уже существует и дописывается, очистка таблицы в выгрузку не добавлена\n", outputPath)
+		} else if opts.Brand != nil {
+			// Файл одного бренда очищает только свой бренд, не трогая остальные
+			writer.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s = '%s';\n\n", table, q("brand"), escapeSQL(*opts.Brand)))
+		} else if opts.Clear == ExportClearTruncate {
+			writer.WriteString(fmt.Sprintf("TRUNCATE TABLE %s;\n\n", table))
+		} else {
+			writer.WriteString(fmt.Sprintf("DELETE FROM %s;\n\n", table))
+		}
+	}
+
+	insertPrefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table, strings.Join(exportColumns(q), ", "))
+
 	// Пагинация для выборки данных
-	limit := 1000 // Количество записей за одну итерацию
+	limit := opts.BatchSize // Количество записей за одну итерацию
 	offset := 0
 
+	// Хэши, уже записанные в текущий файл (используется при opts.Dedup)
+	seen := make(map[string]struct{})
+
 	for {
-		err := db.Limit(limit).Offset(offset).Find(&products).Error
-		if err != nil {
-			log.Fatalf("Ошибка при выборке данных: %v", err)
+		// Страница своя у каждой выгрузки и каждой итерации: выгрузки в одном запуске
+		// (SQL, xlsx, файлы брендов параллельно) не делят состояние между собой
+		var products []Product
+		if err := db.Scopes(filter).Order("id").Limit(limit).Offset(offset).Find(&products).Error; err != nil {
+			return fmt.Errorf("ошибка при выборке данных: %w", err)
 		}
 
 		if len(products) == 0 {
 			break // Все записи обработаны
 		}
 
+		// Значения дополнительных колонок для текущей страницы
+		ids := make([]uint, len(products))
+		for i, product := range products {
+			ids[i] = product.ID
+		}
+		extras, err := loadCustomValues(db, ids)
+		if err != nil {
+			return fmt.Errorf("ошибка при выборке дополнительных колонок: %w", err)
+		}
+
 		// Генерируем INSERT запросы для текущей страницы
 		for _, product := range products {
-			writer.WriteString(fmt.Sprintf("INSERT INTO `products` (`article`, `brand`, `name`) VALUES ('%s', '%s', '%s');\n",
-				escapeSQL(product.Article), escapeSQL(product.Brand), escapeSQL(product.Name)))
+			if opts.Dedup {
+				if _, ok := seen[product.Hash]; ok {
+					continue
+				}
+				seen[product.Hash] = struct{}{}
+			}
+			custom := ""
+			for _, col := range config.CustomColumns {
+				custom += sqlCustomValue(extras[product.ID][col.Name]) + ", "
+			}
+			if opts.Provenance && product.Source != "" {
+				writer.WriteString("-- " + strings.NewReplacer("\n", " ", "\r", " ").Replace(product.Source) + "\n")
+			}
+			writer.WriteString(insertPrefix + fmt.Sprintf("('%s', '%s', '%s', %s, %s%s);\n",
+				escapeSQL(product.Article), escapeSQL(product.Brand), escapeSQL(product.Name), sqlPrice(product.Price), custom, sqlTime(product.UpdatedAt)))
 		}
 
 		offset += limit
 	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			return err
+		}
+	}
+	if err := file.commit(); err != nil {
+		return fmt.Errorf("не удалось сохранить файл выгрузки %s: %w", file.target, err)
+	}
+	return nil
+}
+
+// Запись комментария с метаданными выгрузки
+func writeExportHeader(writer *bufio.Writer, total int64, sources []string) {
+	writer.WriteString(fmt.Sprintf("-- Сгенерировано: %s\n", time.Now().Format(time.RFC3339)))
+	writer.WriteString(fmt.Sprintf("-- Версия XlsxToSQL: %s\n", version))
+	writer.WriteString(fmt.Sprintf("-- Записей: %d\n", total))
+	writer.WriteString("-- Исходные файлы:\n")
+	for _, source := range sources {
+		writer.WriteString(fmt.Sprintf("--   %s\n", strings.ReplaceAll(source, "\n", " ")))
+	}
+	writer.WriteString("\n")
+}
+
+// Литерал цены для SQL (NULL, если цена неизвестна)
+func sqlPrice(price *float64) string {
+	if price == nil {
+		return "NULL"
+	}
+	return strconv.FormatFloat(*price, 'f', 2, 64)
+}
+
+// Литерал времени для SQL (NULL для нулевого значения)
+func sqlTime(t time.Time) string {
+	if t.IsZero() {
+		return "NULL"
+	}
+	return "'" + t.Format("2006-01-02 15:04:05") + "'"
 }
 
 // Экранирование строк для SQL
@@ -0,0 +1,1023 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Колонки определяются по строке заголовка, которая ищется на листе:
+// строки над ней (шапка прайс-листа) пропускаются
+func TestHeaderRowDetection(t *testing.T) {
+	headers := &HeaderSettings{Brand: "Бренд", Article: "Артикул", Name: "Наименование"}
+	tests := []struct {
+		name string
+		rows [][]string
+		want [][3]string
+	}{
+		{
+			name: "заголовок в первой строке",
+			rows: [][]string{
+				{"Артикул", "Бренд", "Наименование"},
+				{"AB-100", "Bosch", "Фильтр масляный"},
+			},
+			want: [][3]string{{"ab100", "bosch", "Фильтр масляный"}},
+		},
+		{
+			name: "заголовок в четвёртой строке",
+			rows: [][]string{
+				{"ООО «Поставщик»"},
+				{"Прайс-лист на 01.10.2026"},
+				{},
+				{"Наименование", "Бренд", "Артикул"},
+				{"Фильтр масляный", "Bosch", "AB-100"},
+				{"Колодки тормозные", "TRW", "GDB-1550"},
+			},
+			want: [][3]string{{"ab100", "bosch", "Фильтр масляный"}, {"gdb1550", "trw", "Колодки тормозные"}},
+		},
+		{
+			name: "заголовка нет",
+			rows: [][]string{
+				{"Фильтр масляный", "Bosch", "AB-100"},
+			},
+			want: [][3]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Headers: headers}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: tt.rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Два столбца «Наименование»: выбор первого, последнего или ошибка с буквами колонок
+func TestDuplicateHeaders(t *testing.T) {
+	rows := [][]string{
+		{"Артикул", "Наименование", "Бренд", "Наименование"},
+		{"AB-100", "Фильтр", "Bosch", "Фильтр масляный"},
+	}
+	tests := []struct {
+		duplicates string
+		want       [][3]string
+	}{
+		{"", [][3]string{{"ab100", "bosch", "Фильтр"}}},
+		{DuplicateHeadersFirst, [][3]string{{"ab100", "bosch", "Фильтр"}}},
+		{DuplicateHeadersLast, [][3]string{{"ab100", "bosch", "Фильтр масляный"}}},
+		{DuplicateHeadersError, [][3]string{}},
+	}
+	for _, tt := range tests {
+		t.Run("duplicates="+tt.duplicates, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			headers := &HeaderSettings{Brand: "Бренд", Article: "Артикул", Name: "Наименование", Duplicates: tt.duplicates}
+			importSheets(t, db, FileConfig{Filename: "prices.xlsx", Headers: headers}, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+
+	_, _, err := findHeaderRow(rows, HeaderSettings{Brand: "Бренд", Article: "Артикул", Name: "Наименование", Duplicates: DuplicateHeadersError})
+	if err == nil || !strings.Contains(err.Error(), "B, D") {
+		t.Errorf("ошибка %v, ожидалось указание колонок B, D", err)
+	}
+}
+
+// Набор удаляемых из артикула символов: хранимое значение и хэш согласованы
+func TestArticleStripChars(t *testing.T) {
+	withoutHyphen := []string{"_", ".", "/", "+", " ", ","}
+	tests := []struct {
+		name       string
+		stripChars []string
+		article    string
+		want       string
+	}{
+		{"стандартный набор", nil, "AB-1/2 .x", "ab12x"},
+		{"дефис значим", withoutHyphen, "AB-1/2 .x", "ab-12x"},
+		{"пустой набор", []string{}, "AB-1/2", "ab-1/2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := rulesFor(FileConfig{ArticleStripChars: tt.stripChars})
+			if got := normalizeArticle(tt.article, rules); got != tt.want {
+				t.Errorf("normalizeArticle(%q) = %q, ожидалось %q", tt.article, got, tt.want)
+			}
+			// Хранимая форма даёт тот же хэш, что и исходная
+			if generateHash(tt.article, "Bosch", rules) != generateHash(tt.want, "Bosch", rules) {
+				t.Errorf("хэш %q и хранимой формы %q различаются", tt.article, tt.want)
+			}
+		})
+	}
+
+	// Каталог, где дефис различает товары: без удаления дефиса товаров два
+	rows := [][]string{
+		{"AB-1", "Bosch", "Фильтр левый"},
+		{"AB1", "Bosch", "Фильтр правый"},
+	}
+	for _, tt := range []struct {
+		stripChars []string
+		want       int
+	}{
+		{nil, 1},
+		{withoutHyphen, 2},
+	} {
+		setTestConfig(t, Config{})
+		db := newTestDB(t)
+		fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, ArticleStripChars: tt.stripChars}
+		importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+		if got := len(storedProducts(t, db)); got != tt.want {
+			t.Errorf("article_strip_chars=%q: записей %d, ожидалось %d", tt.stripChars, got, tt.want)
+		}
+	}
+}
+
+// Снятие обрамляющих кавычек и скобок с названия
+func TestStripNameWrappers(t *testing.T) {
+	pairs := []string{"«»", "[]", `""`}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"«Тормозной диск»", "Тормозной диск"},
+		{"[Brake Disc]", "Brake Disc"},
+		{"«[Тормозной диск]»", "Тормозной диск"},
+		{"« «Тормозной диск» »", "Тормозной диск"},
+		{`"Диск"`, "Диск"},
+		{"«Диск» и «Колодки»", "«Диск» и «Колодки»"},
+		{"[Диск", "[Диск"},
+		{"Диск [R16]", "Диск [R16]"},
+		{"(Диск)", "(Диск)"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := stripNameWrappers(tt.name, pairs); got != tt.want {
+			t.Errorf("stripNameWrappers(%q) = %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+	if got := stripNameWrappers("«Диск»", nil); got != "«Диск»" {
+		t.Errorf("без name_wrappers название изменено: %q", got)
+	}
+}
+
+// Ячейка в мегабайты текста обрезается до max_cell_length или отклоняет строку
+func TestMaxCellLength(t *testing.T) {
+	huge := strings.Repeat("Очень длинное название ", 200000)
+	tests := []struct {
+		name        string
+		policy      string
+		wantName    string
+		wantRejects []string
+	}{
+		{"обрезка по умолчанию", "", string([]rune(huge)[:100]), nil},
+		{"обрезка", CellLengthTruncate, string([]rune(huge)[:100]), nil},
+		{"отклонение", CellLengthReject, "", []string{"слишком длинная ячейка"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{MaxCellLength: 100, CellLengthPolicy: tt.policy})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", huge}}}})
+
+			products := storedProducts(t, db)
+			switch {
+			case tt.wantName == "" && len(products) != 0:
+				t.Errorf("строка не отклонена: %d записей", len(products))
+			case tt.wantName != "" && (len(products) != 1 || products[0].Name != tt.wantName):
+				t.Errorf("ожидалась одна запись с названием из 100 символов, получено %d записей", len(products))
+			}
+			if got := rejectedReasons(); !slices.Equal(got, tt.wantRejects) {
+				t.Errorf("отклонено %q, ожидалось %q", got, tt.wantRejects)
+			}
+		})
+	}
+
+	// Без лимита ячейки не меняются
+	setTestConfig(t, Config{})
+	value := huge
+	if guardCellLengths(&value) || value != huge {
+		t.Error("ячейка изменена без max_cell_length")
+	}
+}
+
+// Значения по умолчанию для пустых колонок, в том числе со ссылкой на другую колонку
+func TestColumnDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults ColumnDefaults
+		row      []string
+		want     [][3]string
+	}{
+		{"без значений по умолчанию", ColumnDefaults{}, []string{"AB-100", "", "Фильтр"}, [][3]string{{"ab100", "", "Фильтр"}}},
+		{"бренд по умолчанию", ColumnDefaults{Brand: "NoName"}, []string{"AB-100", "", "Фильтр"}, [][3]string{{"ab100", "noname", "Фильтр"}}},
+		{"название по артикулу", ColumnDefaults{Name: "Деталь {article}"}, []string{"AB-100", "Bosch", ""}, [][3]string{{"ab100", "bosch", "Деталь ab100"}}},
+		{"заполненные ячейки не меняются", ColumnDefaults{Brand: "NoName", Name: "{article}"}, []string{"AB-100", "Bosch", "Фильтр"}, [][3]string{{"ab100", "bosch", "Фильтр"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, ColumnDefaults: tt.defaults}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{tt.row}}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Бренд из имени листа: колонка бренда не используется, нормализация применяется
+func TestBrandFromSheet(t *testing.T) {
+	book := memoryWorkbook{
+		{Name: "Bosch", Rows: [][]string{{"AB-100", "Makita", "Фильтр"}, {"AB-200", "", "Свеча"}}},
+		{Name: " TRW ", Rows: [][]string{{"GDB-1550", "", "Колодки"}}},
+	}
+	tests := []struct {
+		name           string
+		brandFromSheet bool
+		want           [][3]string
+	}{
+		{"бренд из колонки", false, [][3]string{{"ab100", "makita", "Фильтр"}, {"ab200", "", "Свеча"}, {"gdb1550", "", "Колодки"}}},
+		{"бренд из имени листа", true, [][3]string{{"ab100", "bosch", "Фильтр"}, {"ab200", "bosch", "Свеча"}, {"gdb1550", "trw", "Колодки"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, BrandFromSheet: tt.brandFromSheet}
+			importSheets(t, db, fc, book)
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Ячейки с некорректной UTF-8 последовательностью очищаются или отклоняют строку
+func TestInvalidUTF8Policy(t *testing.T) {
+	rows := [][]string{
+		{"AB-100", "Bosch", "Фильтр \xff\xfeмасляный"},
+		{"AB-200", "Bo\xc3sch", "Свеча"},
+		{"AB-300", "Bosch", "Ремень"},
+	}
+	tests := []struct {
+		policy      string
+		want        [][3]string
+		wantRejects int
+	}{
+		{"", [][3]string{{"ab100", "bosch", "Фильтр масляный"}, {"ab200", "bosch", "Свеча"}, {"ab300", "bosch", "Ремень"}}, 0},
+		{InvalidUTF8Sanitize, [][3]string{{"ab100", "bosch", "Фильтр масляный"}, {"ab200", "bosch", "Свеча"}, {"ab300", "bosch", "Ремень"}}, 0},
+		{InvalidUTF8Reject, [][3]string{{"ab300", "bosch", "Ремень"}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run("policy="+tt.policy, func(t *testing.T) {
+			setTestConfig(t, Config{InvalidUTF8Policy: tt.policy})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			products := storedProducts(t, db)
+			if got := productTriples(products); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %q, ожидалось %q", got, tt.want)
+			}
+			for _, p := range products {
+				if strings.ContainsRune(p.Name, utf8.RuneError) || strings.ContainsRune(p.Brand, utf8.RuneError) {
+					t.Errorf("символ замены в записи %q", p.Name)
+				}
+			}
+			if n := len(rejectedReasons()); n != tt.wantRejects {
+				t.Errorf("отклонено %d строк, ожидалось %d", n, tt.wantRejects)
+			}
+		})
+	}
+}
+
+// Первый запуск на пустой схеме: очистка до создания таблицы — ошибка, а не
+// падение; после AutoMigrate (как в main) таблица создаётся и очищается
+func TestClearTableOnEmptySchema(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newEmptyTestDB(t)
+	table := Product{}.TableName()
+
+	if err := clearTable(db, table); err == nil {
+		t.Fatal("очистка несуществующей таблицы прошла без ошибки")
+	}
+
+	if err := db.AutoMigrate(&Product{}); err != nil {
+		t.Fatalf("AutoMigrate на пустой схеме: %v", err)
+	}
+	if err := clearTable(db, table); err != nil {
+		t.Fatalf("очистка новой таблицы: %v", err)
+	}
+
+	fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+	importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", "Фильтр"}}}})
+	if err := clearTable(db, table); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(storedProducts(t, db)); n != 0 {
+		t.Errorf("после очистки осталось %d записей", n)
+	}
+}
+
+// Несколько товаров в одной строке: повторяющиеся группы колонок, пустые пропускаются
+func TestColumnGroups(t *testing.T) {
+	groups := []ColumnSettings{
+		{Article: 1, Brand: 2, Name: 3},
+		{Article: 4, Brand: 5, Name: 6},
+	}
+	tests := []struct {
+		name string
+		row  []string
+		want [][3]string
+	}{
+		{"две группы", []string{"AB-100", "Bosch", "Фильтр", "GDB-1550", "TRW", "Колодки"}, [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}}},
+		{"вторая группа пуста", []string{"AB-100", "Bosch", "Фильтр", "", "", ""}, [][3]string{{"ab100", "bosch", "Фильтр"}}},
+		{"вторая группа за концом строки", []string{"AB-100", "Bosch", "Фильтр"}, [][3]string{{"ab100", "bosch", "Фильтр"}}},
+		{"первая группа пуста", []string{"", "", "", "GDB-1550", "TRW", "Колодки"}, [][3]string{{"gdb1550", "trw", "Колодки"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Groups: groups}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{tt.row}}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Строгий режим: строки с пустым после нормализации артикулом, брендом или
+// названием отклоняются, без него сохраняются частично
+func TestRequireAllColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		row    []string
+		reason string
+	}{
+		{"все колонки заполнены", []string{"AB-100", "Bosch", "Фильтр"}, ""},
+		{"нет артикула", []string{"--", "Bosch", "Фильтр"}, "пустые колонки: article"},
+		{"нет бренда", []string{"AB-100", " ", "Фильтр"}, "пустые колонки: brand"},
+		{"нет названия", []string{"AB-100", "Bosch", " "}, "пустые колонки: name"},
+		{"нет бренда и названия", []string{"AB-100", "", " "}, "пустые колонки: brand, name"},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/strict=%v", tt.name, strict), func(t *testing.T) {
+				setTestConfig(t, Config{})
+				db := newTestDB(t)
+				fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, RequireAllColumns: strict}
+				importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{tt.row}}})
+
+				wantStored, wantRejects := 1, []string{}
+				if strict && tt.reason != "" {
+					wantStored, wantRejects = 0, []string{tt.reason}
+				}
+				if n := len(storedProducts(t, db)); n != wantStored {
+					t.Errorf("записей %d, ожидалось %d", n, wantStored)
+				}
+				if got := rejectedReasons(); !slices.Equal(got, wantRejects) {
+					t.Errorf("отклонено %q, ожидалось %q", got, wantRejects)
+				}
+			})
+		}
+	}
+}
+
+// Сопоставление по штрихкоду при dedup_by_ean: EAN важнее article+brand,
+// без EAN товары сопоставляются по хэшу
+func TestDedupByEAN(t *testing.T) {
+	tests := []struct {
+		name  string
+		dedup bool
+		rows  [][]string
+		want  [][3]string
+	}{
+		{
+			name:  "один EAN, разные article+brand",
+			dedup: true,
+			rows:  [][]string{{"AB-100", "Bosch", "Фильтр", "4006381333931"}, {"0 986 452 044", "Bosch GmbH", "Фильтр масляный", "4006381333931"}},
+			want:  [][3]string{{"ab100", "bosch", "Фильтр масляный"}},
+		},
+		{
+			name:  "EAN с пробелами",
+			dedup: true,
+			rows:  [][]string{{"AB-100", "Bosch", "Фильтр", "4006381333931"}, {"X-1", "Mann", "Фильтр", "4 006381 333931"}},
+			want:  [][3]string{{"ab100", "bosch", "Фильтр"}},
+		},
+		{
+			name:  "без EAN — по article+brand",
+			dedup: true,
+			rows:  [][]string{{"AB-100", "Bosch", "Фильтр", ""}, {"ab100", "BOSCH", "Фильтр масляный", ""}, {"AB-200", "Bosch", "Свеча", ""}},
+			want:  [][3]string{{"ab100", "bosch", "Фильтр масляный"}, {"ab200", "bosch", "Свеча"}},
+		},
+		{
+			name:  "один article+brand, разные EAN",
+			dedup: true,
+			rows:  [][]string{{"AB-100", "Bosch", "Фильтр", "4006381333931"}, {"AB-100", "Bosch", "Фильтр", "4006381333948"}},
+			want:  [][3]string{{"ab100", "bosch", "Фильтр"}, {"ab100", "bosch", "Фильтр"}},
+		},
+		{
+			name:  "dedup_by_ean выключен",
+			dedup: false,
+			rows:  [][]string{{"AB-100", "Bosch", "Фильтр", "4006381333931"}, {"X-1", "Mann", "Фильтр", "4006381333931"}},
+			want:  [][3]string{{"ab100", "bosch", "Фильтр"}, {"x1", "mann", "Фильтр"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{DedupByEAN: tt.dedup})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, EAN: 4}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: tt.rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Строки с одним EAN и разными article+brand, обрабатываемые параллельно,
+// дают одну запись: поиск по ean и создание идут под блокировкой штрихкода
+func TestDedupByEANConcurrent(t *testing.T) {
+	setTestConfig(t, Config{DedupByEAN: true})
+	db := newTestDB(t)
+	var rows [][]string
+	for i := range 40 {
+		rows = append(rows, []string{fmt.Sprintf("AB-%d", i), "Bosch", "Фильтр", "4006381333931"})
+	}
+	fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, EAN: 4}, RowWorkers: 8}
+	importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+	if n := len(storedProducts(t, db)); n != 1 {
+		t.Errorf("записей %d, ожидалась одна", n)
+	}
+}
+
+// updated_at сдвигается при изменении записи (если не выключено
+// -bump-updated-at=false) и не меняется при повторе без изменений
+func TestUpdatedAtBump(t *testing.T) {
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		bump     bool
+		second   string // Название во втором файле
+		wantBump bool
+	}{
+		{"изменение", true, "Фильтр масляный", true},
+		{"изменение без сдвига", false, "Фильтр масляный", false},
+		{"повтор без изменений", true, "Фильтр", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, flagBumpUpdatedAt, tt.bump)
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", "Фильтр"}}}})
+			if err := db.Model(&Product{}).Where("1 = 1").UpdateColumn("updated_at", old).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", tt.second}}}})
+			products := storedProducts(t, db)
+			if len(products) != 1 || products[0].Name != tt.second {
+				t.Fatalf("записи %v, ожидалось название %q", productTriples(products), tt.second)
+			}
+			if bumped := products[0].UpdatedAt.After(old); bumped != tt.wantBump {
+				t.Errorf("updated_at = %v, сдвиг %v, ожидался %v", products[0].UpdatedAt, bumped, tt.wantBump)
+			}
+		})
+	}
+}
+
+// Невидимые символы Unicode и лишние пробелы в бренде не мешают объединению
+func TestNormalizeBrandInvisible(t *testing.T) {
+	tests := []struct {
+		brand string
+		want  string
+	}{
+		{"Bosch\u200b", "bosch"},                   // Пробел нулевой ширины в конце
+		{"\ufeffBosch", "bosch"},                   // BOM в начале
+		{"\u200eMann Filter\u00a0", "mann filter"}, // Метка направления и неразрывный пробел
+		{"Mann \t  Filter", "mann filter"},
+		{"Mann\u200dFilter", "mannfilter"}, // Соединитель нулевой ширины внутри
+		{"Bosch\x07", "bosch"},             // Управляющий символ
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeBrand(tt.brand); got != tt.want {
+			t.Errorf("normalizeBrand(%q) = %q, ожидалось %q", tt.brand, got, tt.want)
+		}
+	}
+
+	// Бренды, различающиеся только невидимыми символами, дают одну запись
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	rows := [][]string{{"AB-100", "Bosch", "Фильтр"}, {"AB-100", "Bosch\u200b", "Фильтр масляный"}}
+	fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+	importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+	if got, want := productTriples(storedProducts(t, db)), [][3]string{{"ab100", "bosch", "Фильтр масляный"}}; !slices.Equal(got, want) {
+		t.Errorf("записи %v, ожидалось %v", got, want)
+	}
+}
+
+// Кавычки идентификаторов выгрузки: обратные (MySQL), двойные (ANSI) или без кавычек
+func TestExportIdentifierQuote(t *testing.T) {
+	tests := []struct {
+		style      string
+		wantTable  string
+		wantInsert string
+	}{
+		{"", "CREATE TABLE IF NOT EXISTS `products` (", "INSERT INTO `products` (`article`, `brand`, `name`, `price`, `updated_at`) VALUES "},
+		{IdentQuoteBacktick, "CREATE TABLE IF NOT EXISTS `products` (", "INSERT INTO `products` (`article`, `brand`, `name`, `price`, `updated_at`) VALUES "},
+		{IdentQuoteDouble, `CREATE TABLE IF NOT EXISTS "products" (`, `INSERT INTO "products" ("article", "brand", "name", "price", "updated_at") VALUES `},
+		{IdentQuoteNone, "CREATE TABLE IF NOT EXISTS products (", "INSERT INTO products (article, brand, name, price, updated_at) VALUES "},
+	}
+	for _, tt := range tests {
+		t.Run("style="+tt.style, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			if err := db.Create(&Product{Article: "ab100", Brand: "bosch", Name: "Фильтр", Hash: "h1"}).Error; err != nil {
+				t.Fatal(err)
+			}
+			dump := exportSQL(t, db, ExportOptions{IdentQuote: tt.style})
+			if !strings.Contains(dump, tt.wantTable) || !strings.Contains(dump, tt.wantInsert) {
+				t.Errorf("выгрузка без %q и %q:\n%s", tt.wantTable, tt.wantInsert, dump)
+			}
+		})
+	}
+
+	// Кавычка внутри имени удваивается
+	if got := quoteIdent("a`b", IdentQuoteBacktick); got != "`a``b`" {
+		t.Errorf("quoteIdent = %s", got)
+	}
+	if got := quoteIdent(`a"b`, IdentQuoteDouble); got != `"a""b"` {
+		t.Errorf("quoteIdent = %s", got)
+	}
+}
+
+// Повторный импорт неизменного файла не пишет в таблицу: изменённые поля
+// сравниваются с сохранёнными до UPDATE
+func TestIdenticalReimportNoWrite(t *testing.T) {
+	price := func(p string) []string {
+		return []string{"AB-100", "Bosch", "Фильтр масляный", p, "4006381333931"}
+	}
+	tests := []struct {
+		name        string
+		second      [][]string
+		wantUpdates int
+	}{
+		{"тот же файл", [][]string{price("120.50")}, 0},
+		{"артикул и бренд в другом написании", [][]string{{"ab100", "BOSCH", "Фильтр масляный", "120.50", "4006381333931"}}, 0},
+		{"без цены и штрихкода", [][]string{{"AB-100", "Bosch", "Фильтр масляный"}}, 0},
+		{"другое название той же длины", [][]string{{"AB-100", "Bosch", "Фильтр маслёный", "120.50"}}, 1},
+		{"другая цена", [][]string{price("99")}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{NamePolicy: NamePolicyLast, PricePolicy: PricePolicyLast})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, Price: 4, EAN: 5}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{price("120.50")}}})
+
+			updates := 0
+			err := db.Callback().Update().Before("gorm:update").Register("test:count_updates", func(*gorm.DB) { updates++ })
+			if err != nil {
+				t.Fatal(err)
+			}
+			duplicates := counters.Duplicates.Load()
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: tt.second}})
+			if updates != tt.wantUpdates {
+				t.Errorf("UPDATE выполнен %d раз, ожидалось %d", updates, tt.wantUpdates)
+			}
+			if got := counters.Duplicates.Load() - duplicates; tt.wantUpdates == 0 && got != 1 {
+				t.Errorf("дубликатов без обновления %d, ожидался 1", got)
+			}
+		})
+	}
+}
+
+// Прайс-лист, один из листов которого не читается
+type brokenSheetWorkbook struct {
+	memoryWorkbook
+	broken string
+}
+
+func (b brokenSheetWorkbook) StreamRows(sheet string) (rowStream, error) {
+	if sheet == b.broken {
+		return nil, errors.New("повреждённый лист")
+	}
+	return b.memoryWorkbook.StreamRows(sheet)
+}
+
+// Нечитаемый лист пропускается (sheet_error_policy=skip) или прерывает
+// обработку файла (abort)
+func TestSheetErrorPolicy(t *testing.T) {
+	book := brokenSheetWorkbook{
+		memoryWorkbook: memoryWorkbook{
+			{Name: "Фильтры", Rows: [][]string{{"AB-100", "Bosch", "Фильтр"}}},
+			{Name: "Свечи", Rows: [][]string{{"AB-200", "Bosch", "Свеча"}}},
+			{Name: "Колодки", Rows: [][]string{{"GDB-1550", "TRW", "Колодки"}}},
+		},
+		broken: "Свечи",
+	}
+	tests := []struct {
+		policy  string
+		wantErr bool
+		want    [][3]string
+	}{
+		{"", false, [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}}},
+		{SheetErrorSkip, false, [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}}},
+		{SheetErrorAbort, true, [][3]string{{"ab100", "bosch", "Фильтр"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			setTestConfig(t, Config{SheetErrorPolicy: tt.policy})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			err := processXLSXFileWithConfig(db, fc.Filename, fc, func() (workbook, error) { return book, nil })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %v", err, tt.wantErr)
+			}
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Одинаковые article+brand в файлах с разной солью хэша (hash_salt) —
+// разные товары; без соли или с одной солью они объединяются
+func TestHashSaltNamespaces(t *testing.T) {
+	rules := func(salt string) NormalizeRules { return rulesFor(FileConfig{HashSalt: salt}) }
+	if generateHash("AB-100", "Bosch", rules("a")) == generateHash("AB-100", "Bosch", rules("b")) {
+		t.Error("хэши с разной солью совпали")
+	}
+	if generateHash("AB-100", "Bosch", rules("")) == generateHash("AB-100", "Bosch", rules("a")) {
+		t.Error("хэши с солью и без неё совпали")
+	}
+	// Граница соли и артикула не смещается
+	if generateHash("1AB", "Bosch", rules("ab")) == generateHash("AB", "Bosch", rules("ab1")) {
+		t.Error("соль слилась с артикулом")
+	}
+
+	tests := []struct {
+		name        string
+		first, last string // Соль первого и второго файла
+		wantRecords int
+	}{
+		{"разная соль", "supplier-a", "supplier-b", 2},
+		{"одна соль", "supplier-a", "supplier-a", 1},
+		{"без соли", "", "", 1},
+		{"соль только у одного", "supplier-a", "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			columns := ColumnSettings{Article: 1, Brand: 2, Name: 3}
+			importSheets(t, db, FileConfig{Filename: "a.xlsx", Columns: columns, HashSalt: tt.first},
+				memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", "Фильтр"}}}})
+			importSheets(t, db, FileConfig{Filename: "b.xlsx", Columns: columns, HashSalt: tt.last},
+				memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", "Фильтр масляный"}}}})
+			if n := len(storedProducts(t, db)); n != tt.wantRecords {
+				t.Errorf("записей %d, ожидалось %d", n, tt.wantRecords)
+			}
+		})
+	}
+}
+
+// Хранимый вид article и brand (stored_form) настраивается отдельно от формы
+// для сопоставления: хэш один и тот же, а записи различаются только видом значений
+func TestStoredForm(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        StoredFormSettings
+		wantArticle string
+		wantBrand   string
+	}{
+		{"по умолчанию", StoredFormSettings{}, "abc123", "mann-filter"},
+		{"normalized", StoredFormSettings{Article: StoredFormNormalized, Brand: StoredFormNormalized}, "abc123", "mann-filter"},
+		{"lower", StoredFormSettings{Article: StoredFormLower, Brand: StoredFormLower}, "abc-123", "mann-filter"},
+		{"trim", StoredFormSettings{Article: StoredFormTrim, Brand: StoredFormTrim}, "ABC-123", "MANN-Filter"},
+		{"только артикул", StoredFormSettings{Article: StoredFormLower}, "abc-123", "mann-filter"},
+	}
+	wantHash := generateHash("ABC-123", "MANN-Filter", rulesFor(FileConfig{}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{StoredForm: tt.form})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			rows := [][]string{{" ABC-123\u200b", "MANN-Filter ", "Фильтр"}, {"abc123", "mann filter", "Фильтр масляный"}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+
+			products := storedProducts(t, db)
+			if len(products) != 1 {
+				t.Fatalf("записи %v, ожидалась одна", productTriples(products))
+			}
+			p := products[0]
+			if p.Article != tt.wantArticle || p.Brand != tt.wantBrand {
+				t.Errorf("хранится %q, %q; ожидалось %q, %q", p.Article, p.Brand, tt.wantArticle, tt.wantBrand)
+			}
+			if p.Hash != wantHash {
+				t.Errorf("хэш %s зависит от хранимого вида, ожидался %s", p.Hash, wantHash)
+			}
+		})
+	}
+}
+
+// auto_skip_rows пропускает служебные строки перед данными, сколько бы их ни было
+func TestAutoSkipRows(t *testing.T) {
+	junk := [][]string{
+		{"Прайс-лист на 01.01.2025"},
+		{"Тел. +7 495 000-00-00", ""},
+		{},
+		{"Артикул", "Бренд", "Название"},
+	}
+	data := [][]string{{"AB-100", "Bosch", "Фильтр"}, {"GDB-1550", "TRW", "Колодки"}}
+	want := [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}}
+	columns := ColumnSettings{Article: 1, Brand: 2, Name: 3}
+
+	for _, skip := range []int{0, 2, 4} {
+		t.Run(fmt.Sprintf("%d строк", skip), func(t *testing.T) {
+			rows := slices.Concat(junk[len(junk)-skip:], data)
+			if got := firstDataRow(rows, columns, false); got != skip {
+				t.Errorf("firstDataRow = %d, ожидалось %d", got, skip)
+			}
+
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: columns, AutoSkipRows: true}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, want) {
+				t.Errorf("записи %v, ожидалось %v", got, want)
+			}
+			if reasons := rejectedReasons(); len(reasons) != 0 {
+				t.Errorf("отклонены строки: %v", reasons)
+			}
+		})
+	}
+
+	// Бренд задан листом: колонка бренда не проверяется
+	rows := [][]string{{"Артикул", "", "Название"}, {"AB-100", "", "Фильтр"}}
+	if got := firstDataRow(rows, columns, true); got != 1 {
+		t.Errorf("firstDataRow с брендом листа = %d, ожидалось 1", got)
+	}
+	if got := firstDataRow(junk, columns, false); got != len(junk) {
+		t.Errorf("firstDataRow без данных = %d, ожидалось %d", got, len(junk))
+	}
+}
+
+// С case_sensitive_article артикулы, различающиеся только регистром, —
+// разные товары: регистр сохраняется и в хранимом значении, и в хэше
+func TestCaseSensitiveArticle(t *testing.T) {
+	for _, tt := range []struct {
+		sensitive bool
+		want      string
+	}{{false, "abc1"}, {true, "ABc1"}} {
+		rules := rulesFor(FileConfig{CaseSensitiveArticle: tt.sensitive})
+		if got := normalizeArticle("AB-c1", rules); got != tt.want {
+			t.Errorf("case_sensitive_article=%v: normalizeArticle = %q, ожидалось %q", tt.sensitive, got, tt.want)
+		}
+		if same := generateHash("ABC", "Bosch", rules) == generateHash("abc", "Bosch", rules); same == tt.sensitive {
+			t.Errorf("case_sensitive_article=%v: хэши ABC и abc совпадают: %v", tt.sensitive, same)
+		}
+	}
+
+	tests := []struct {
+		sensitive bool
+		want      [][3]string
+	}{
+		{false, [][3]string{{"abc", "bosch", "Фильтр масляный"}}},
+		{true, [][3]string{{"ABC", "bosch", "Фильтр"}, {"abc", "bosch", "Фильтр масляный"}}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.sensitive), func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}, CaseSensitiveArticle: tt.sensitive}
+			rows := [][]string{{"ABC", "Bosch", "Фильтр"}, {"abc", "BOSCH", "Фильтр масляный"}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Очистка таблицы в начале выгрузки (export_clear): оператор пишется перед
+// первым INSERT, только в новый файл
+func TestExportClear(t *testing.T) {
+	bosch := "bosch"
+	tests := []struct {
+		name string
+		opts ExportOptions
+		want string // "" — очистки нет
+	}{
+		{"по умолчанию", ExportOptions{}, ""},
+		{"truncate", ExportOptions{Clear: ExportClearTruncate}, "TRUNCATE TABLE `products`;"},
+		{"delete", ExportOptions{Clear: ExportClearDelete}, "DELETE FROM `products`;"},
+		{"ANSI кавычки", ExportOptions{Clear: ExportClearTruncate, IdentQuote: IdentQuoteDouble}, `TRUNCATE TABLE "products";`},
+		{"один бренд", ExportOptions{Clear: ExportClearTruncate, Brand: &bosch}, "DELETE FROM `products` WHERE `brand` = 'bosch';"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			if err := db.Create(&Product{Article: "ab100", Brand: "bosch", Name: "Фильтр", Hash: "h1"}).Error; err != nil {
+				t.Fatal(err)
+			}
+			dump := exportSQL(t, db, tt.opts)
+			clear := strings.Index(dump, tt.want)
+			insert := strings.Index(dump, "INSERT INTO")
+			switch {
+			case insert < 0:
+				t.Fatalf("в выгрузке нет INSERT:\n%s", dump)
+			case tt.want == "" && (strings.Contains(dump, "TRUNCATE") || strings.Contains(dump, "DELETE")):
+				t.Errorf("очистка без export_clear:\n%s", dump)
+			case tt.want != "" && (clear < 0 || clear > insert):
+				t.Errorf("нет %q перед INSERT:\n%s", tt.want, dump)
+			}
+		})
+	}
+
+	// Дописываемый файл не очищает таблицу: это удалило бы вставки прежних запусков
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	if err := db.Create(&Product{Article: "ab100", Brand: "bosch", Name: "Фильтр", Hash: "h1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "output.sql")
+	opts := ExportOptions{BatchSize: defaultExportBatchSize, Clear: ExportClearTruncate}
+	if err := exportToSQLFile(db, output, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := exportToSQLFile(db, output, opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "INSERT INTO"); n != 2 {
+		t.Fatalf("INSERT в файле %d раз, ожидалось два:\n%s", n, data)
+	}
+	if n := strings.Count(string(data), "TRUNCATE TABLE"); n != 1 {
+		t.Errorf("TRUNCATE в файле %d раз, ожидался один:\n%s", n, data)
+	}
+}
+
+// Разделитель между артикулом и брендом в хэше: пары с неоднозначной
+// границей ("ab"+"c" и "a"+"bc") больше не совпадают; legacy_hash
+// сохраняет прежнюю схему для таблиц, заполненных до его введения
+func TestHashSeparator(t *testing.T) {
+	tests := []struct {
+		legacy      bool
+		wantSame    bool
+		wantRecords int
+	}{
+		{false, false, 2},
+		{true, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("legacy=%v", tt.legacy), func(t *testing.T) {
+			setTestConfig(t, Config{LegacyHash: tt.legacy})
+			rules := rulesFor(FileConfig{})
+			if same := generateHash("ab", "c", rules) == generateHash("a", "bc", rules); same != tt.wantSame {
+				t.Errorf("хэши ab+c и a+bc совпадают: %v, ожидалось %v", same, tt.wantSame)
+			}
+
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}
+			rows := [][]string{{"ab", "c", "Фильтр"}, {"a", "bc", "Свеча"}}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			if n := len(storedProducts(t, db)); n != tt.wantRecords {
+				t.Errorf("записей %d, ожидалось %d", n, tt.wantRecords)
+			}
+		})
+	}
+
+	// Прежняя схема — sha256 от склеенных артикула и бренда: хэши старых таблиц совпадают
+	setTestConfig(t, Config{LegacyHash: true})
+	if got, want := generateHash("AB", "c", rulesFor(FileConfig{})), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; got != want {
+		t.Errorf("legacy хэш %s, ожидался sha256(\"abc\") %s", got, want)
+	}
+}
+
+// Несколько выгрузок в одном процессе независимы: каждая выбирает записи
+// в свой буфер, и вторая выгрузка так же полна, как первая
+func TestExportsBackToBack(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	var products []Product
+	for i := range 25 {
+		products = append(products, Product{Article: fmt.Sprintf("ab%03d", i), Brand: "bosch", Name: "Фильтр", Hash: fmt.Sprintf("h%d", i)})
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Размер порции не кратен числу записей: последняя порция неполная
+	opts := ExportOptions{BatchSize: 7}
+	first := exportSQL(t, db, opts)
+	output := filepath.Join(t.TempDir(), "catalog.xlsx")
+	if err := exportToXLSXFile(db, output, opts.BatchSize, XLSXExportSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	second := exportSQL(t, db, opts)
+
+	for i, dump := range []string{first, second} {
+		for _, p := range products {
+			if !strings.Contains(dump, "'"+p.Article+"'") {
+				t.Errorf("выгрузка %d: нет записи %s", i+1, p.Article)
+			}
+		}
+	}
+	if first != second {
+		t.Errorf("повторная выгрузка отличается от первой:\n%s\n---\n%s", first, second)
+	}
+
+	f, err := excelize.OpenFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(products)+1 {
+		t.Errorf("строк в xlsx %d, ожидалось %d (с заголовком)", len(rows), len(products)+1)
+	}
+}
+
+// Итоговые строки («Итого», «TOTAL») с числом в колонке цены не становятся товарами
+func TestSummaryRows(t *testing.T) {
+	columns := ColumnSettings{Article: 1, Brand: 2, Name: 3, Price: 4}
+	groups := []ColumnSettings{columns}
+	markers := []string{"Итого", "total"}
+	tests := []struct {
+		row  []string
+		want bool
+	}{
+		{[]string{"", "", "Итого", "12 345,00"}, true},
+		{[]string{"ИТОГО:", "", "", "12345"}, true},
+		{[]string{"", "", "итого по листу", "12345"}, true},
+		{[]string{"TOTAL", "x", "", "99"}, true},
+		{[]string{"Totalizer-1", "Bosch", "Счётчик", "500"}, false}, // Продолжение маркера — буква
+		{[]string{"AB-100", "Bosch", "Фильтр", "Итого"}, true},
+		{[]string{"AB-100", "Bosch", "Фильтр", "120"}, false},
+		{[]string{"AB-100", "Bosch", "Фильтр", "120", "Итого"}, false}, // Колонка не настроена
+	}
+	for _, tt := range tests {
+		if _, got := summaryRowMarker(tt.row, groups, markers); got != tt.want {
+			t.Errorf("summaryRowMarker(%q) = %v, ожидалось %v", tt.row, got, tt.want)
+		}
+	}
+	if _, got := summaryRowMarker([]string{"Итого"}, groups, nil); got {
+		t.Error("итоговая строка найдена без маркеров")
+	}
+
+	rows := [][]string{
+		{"AB-100", "Bosch", "Фильтр", "120"},
+		{"GDB-1550", "TRW", "Колодки", "1500"},
+		{"Итого:", "", "", "1620"},
+	}
+	for _, tt := range []struct {
+		markers     []string
+		want        [][3]string
+		wantRejects int
+	}{
+		{[]string{"Итого"}, [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}}, 0},
+		{nil, [][3]string{{"ab100", "bosch", "Фильтр"}, {"gdb1550", "trw", "Колодки"}, {"итого:", "", ""}}, 0}, // Без маркеров — ложный товар
+	} {
+		t.Run(fmt.Sprint(tt.markers), func(t *testing.T) {
+			setTestConfig(t, Config{})
+			db := newTestDB(t)
+			fc := FileConfig{Filename: "prices.xlsx", Columns: columns, SummaryMarkers: tt.markers}
+			importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: rows}})
+			if got := productTriples(storedProducts(t, db)); !slices.Equal(got, tt.want) {
+				t.Errorf("записи %v, ожидалось %v", got, tt.want)
+			}
+			if reasons := rejectedReasons(); len(reasons) != tt.wantRejects {
+				t.Errorf("отклонено %v, ожидалось строк: %d", reasons, tt.wantRejects)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Состояние обработки одного файла
+type ManifestEntry struct {
+	SHA256      string    `json:"sha256"`       // Контрольная сумма содержимого файла
+	CompletedAt time.Time `json:"completed_at"` // Время успешного завершения обработки
+}
+
+// Манифест запуска: полностью обработанные файлы с их контрольными суммами.
+// Сохраняется на диск после каждого файла, чтобы прерванный запуск можно было возобновить.
+type Manifest struct {
+	mu    sync.Mutex
+	path  string
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+var manifest = &Manifest{Files: make(map[string]ManifestEntry)}
+
+// Загрузка манифеста. Без resume состояние сбрасывается: начинается новый запуск.
+func loadManifest(path string, resume bool) error {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.path = path
+	manifest.Files = make(map[string]ManifestEntry)
+	if !resume || path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil // Нечего возобновлять — обрабатываем все файлы
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, manifest)
+}
+
+// Чтение манифеста без последующей записи (для выгрузки)
+func loadManifestReadOnly(path string) error {
+	if err := loadManifest(path, true); err != nil {
+		return err
+	}
+	manifest.mu.Lock()
+	manifest.path = ""
+	manifest.mu.Unlock()
+	return nil
+}
+
+// Сброс манифеста в памяти и на диске: следующий запуск с -resume
+// обработает все файлы заново
+func resetManifest(path string) error {
+	manifest.mu.Lock()
+	defer manifest.mu.Unlock()
+
+	manifest.path = path
+	manifest.Files = make(map[string]ManifestEntry)
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Файл уже обработан в этом или прерванном запуске и с тех пор не изменился
+func (m *Manifest) isCompleted(filePath, checksum string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Files[filePath]
+	return ok && entry.SHA256 == checksum
+}
+
+// Отметка о завершении обработки файла с немедленным сохранением на диск
+func (m *Manifest) markCompleted(filePath, checksum string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Files[filePath] = ManifestEntry{SHA256: checksum, CompletedAt: time.Now()}
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Запись через временный файл, чтобы прерывание не оставило битый манифест
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// Список полностью обработанных файлов в алфавитном порядке
+func (m *Manifest) completedFiles() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]string, 0, len(m.Files))
+	for file := range m.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Контрольная сумма SHA-256 содержимого файла
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Манифест на время теста; прежний восстанавливается по его окончании
+func setTestManifest(t *testing.T) {
+	t.Helper()
+	prev := manifest
+	manifest = &Manifest{Files: make(map[string]ManifestEntry)}
+	t.Cleanup(func() { manifest = prev })
+}
+
+// После clean импорт с -resume загружает все файлы заново: манифест
+// удаляется вместе с содержимым таблицы
+func TestCleanThenResume(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "prices.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]string{"AB-100", "Bosch", "Фильтр"})
+	if err := f.SaveAs(input); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	setTestConfig(t, Config{Files: []FileConfig{{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3}}}})
+	setTestManifest(t)
+	setFlag(t, flagResume, true)
+	setFlag(t, flagSequential, true)
+	db := newTestDB(t)
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	// import -resume: файл загружен и отмечен в манифесте
+	if err := loadManifest(manifestPath, true); err != nil {
+		t.Fatal(err)
+	}
+	dispatchFileFrom(db, input, input)
+	if n := len(storedProducts(t, db)); n != 1 {
+		t.Fatalf("после импорта записей %d, ожидалась одна", n)
+	}
+	if _, err := os.Stat(manifestPath); err != nil {
+		t.Fatalf("манифест не записан: %v", err)
+	}
+
+	// clean
+	if err := cleanTable(db, manifestPath); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(storedProducts(t, db)); n != 0 {
+		t.Fatalf("после clean записей %d", n)
+	}
+	if _, err := os.Stat(manifestPath); !os.IsNotExist(err) {
+		t.Errorf("манифест остался на диске после clean: %v", err)
+	}
+
+	// Снова import -resume: файл не считается обработанным
+	if err := loadManifest(manifestPath, true); err != nil {
+		t.Fatal(err)
+	}
+	dispatchFileFrom(db, input, input)
+	if got, want := productTriples(storedProducts(t, db)), [][3]string{{"ab100", "bosch", "Фильтр"}}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("после clean и import -resume записи %v, ожидалось %v", got, want)
+	}
+}
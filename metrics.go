@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Счётчики всего запуска (обновляются из горутин обработки файлов)
+type runCounters struct {
+	FilesProcessed atomic.Int64 // Успешно обработано файлов
+	FilesFailed    atomic.Int64 // Файлов с ошибкой обработки
+	FilesDeadline  atomic.Int64 // Файлов, пропущенных или прерванных по истечении -max-runtime
+	Rows           atomic.Int64 // Просмотрено строк данных
+	Rejected       atomic.Int64 // Отклонено строк
+	Inserted       atomic.Int64 // Создано записей
+	Updated        atomic.Int64 // Обновлено записей
+	Duplicates     atomic.Int64 // Строк, совпавших с уже существующей записью без обновления
+}
+
+var counters runCounters
+
+// Запись метрик запуска в формате textfile collector для node_exporter.
+//
+// Метрики:
+//
+//	xlsxtosql_files_total{status="processed"|"failed"|"deadline"} — файлы по результату обработки
+//	xlsxtosql_rows_total                               — просмотренные строки данных
+//	xlsxtosql_rows_rejected_total                      — отклонённые строки
+//	xlsxtosql_products_inserted_total                  — созданные записи
+//	xlsxtosql_products_updated_total                   — обновлённые записи
+//	xlsxtosql_duplicate_rows_total                     — строки-дубликаты без обновления
+//	xlsxtosql_run_duration_seconds                     — длительность запуска
+//	xlsxtosql_last_run_timestamp_seconds               — время завершения запуска (unix)
+//
+// Файл пишется во временный и переименовывается, чтобы коллектор не прочитал его частично.
+func writePrometheusMetrics(path string, elapsed time.Duration) error {
+	var b strings.Builder
+
+	metric := func(name, help, kind string, samples ...string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, sample := range samples {
+			fmt.Fprintf(&b, "%s%s\n", name, sample)
+		}
+	}
+
+	metric("xlsxtosql_files_total", "Files handled by the import, by status.", "counter",
+		fmt.Sprintf(`{status="processed"} %d`, counters.FilesProcessed.Load()),
+		fmt.Sprintf(`{status="failed"} %d`, counters.FilesFailed.Load()),
+		fmt.Sprintf(`{status="deadline"} %d`, counters.FilesDeadline.Load()))
+	metric("xlsxtosql_rows_total", "Data rows read from source files.", "counter",
+		fmt.Sprintf(" %d", counters.Rows.Load()))
+	metric("xlsxtosql_rows_rejected_total", "Data rows rejected during import.", "counter",
+		fmt.Sprintf(" %d", counters.Rejected.Load()))
+	metric("xlsxtosql_products_inserted_total", "Products inserted into the table.", "counter",
+		fmt.Sprintf(" %d", counters.Inserted.Load()))
+	metric("xlsxtosql_products_updated_total", "Products whose name was updated.", "counter",
+		fmt.Sprintf(" %d", counters.Updated.Load()))
+	metric("xlsxtosql_duplicate_rows_total", "Rows matching an existing product without an update.", "counter",
+		fmt.Sprintf(" %d", counters.Duplicates.Load()))
+	metric("xlsxtosql_run_duration_seconds", "Duration of the import run.", "gauge",
+		fmt.Sprintf(" %.3f", elapsed.Seconds()))
+	metric("xlsxtosql_last_run_timestamp_seconds", "Unix time the import run finished.", "gauge",
+		fmt.Sprintf(" %d", time.Now().Unix()))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".xlsxtosql-*.prom.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Пространства имён OpenDocument, в которых лежат таблицы и их ячейки
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// Файл OpenDocument (LibreOffice Calc): таблицы из content.xml читаются в память.
+// Текст ячейки — отображаемое значение, как GetRows у xlsx; для чисел, дат и
+// логических значений RawCell возвращает само значение (office:value и т. п.).
+func openODSWorkbook(filePath string) (workbook, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != "content.xml" {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer content.Close()
+		return parseODSContent(content)
+	}
+	return nil, errors.New("в файле ods нет content.xml")
+}
+
+// Разбор content.xml. Повторы строк и ячеек (number-rows-repeated,
+// number-columns-repeated) разворачиваются, кроме пустых в конце листа и строки:
+// ими LibreOffice дополняет лист до миллиона строк.
+func parseODSContent(r io.Reader) (memoryWorkbook, error) {
+	decoder := xml.NewDecoder(r)
+
+	var (
+		book        memoryWorkbook
+		sheet       *memorySheet
+		emptyRows   int // Пустые строки, ещё не добавленные в лист
+		text, raw   []string
+		emptyCells  int // Пустые ячейки, ещё не добавленные в строку
+		rowRepeat   int
+		inCell      bool
+		cellRepeat  int
+		cellText    strings.Builder
+		cellRaw     string
+		cellHasText bool
+		paragraphs  int
+		inParagraph bool
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("content.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				book = append(book, memorySheet{Name: odsAttr(t, odsTableNS, "name")})
+				sheet = &book[len(book)-1]
+				emptyRows = 0
+			case sheet != nil && t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				text, raw, emptyCells = nil, nil, 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+			case sheet != nil && t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = true
+				cellRepeat = odsRepeat(t, "number-columns-repeated")
+				cellText.Reset()
+				cellRaw = odsCellValue(t)
+				cellHasText = false
+				paragraphs = 0
+			case inCell && t.Name.Space == odsOfficeNS && t.Name.Local == "annotation":
+				if err := decoder.Skip(); err != nil { // Примечание к ячейке не её значение
+					return nil, fmt.Errorf("content.xml: %w", err)
+				}
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "p":
+				if paragraphs > 0 {
+					cellText.WriteByte('\n')
+				}
+				paragraphs++
+				inParagraph = true
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "s":
+				count := 1
+				if c, err := strconv.Atoi(odsAttr(t, odsTextNS, "c")); err == nil && c > 0 {
+					count = c
+				}
+				cellText.WriteString(strings.Repeat(" ", count))
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "tab":
+				cellText.WriteByte('\t')
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "line-break":
+				cellText.WriteByte('\n')
+			}
+
+		case xml.CharData:
+			if inParagraph {
+				cellText.Write(t)
+				cellHasText = true
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTextNS && t.Name.Local == "p":
+				inParagraph = false
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				if sheet != nil {
+					sheet.Raw = trimRowTails(sheet.Raw)
+					sheet.Rows = trimRowTails(sheet.Rows)
+				}
+				sheet = nil
+			case sheet != nil && t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = false
+				value := cellText.String()
+				if !cellHasText && cellRaw == "" {
+					emptyCells += cellRepeat
+					continue
+				}
+				if cellRaw == "" {
+					cellRaw = value
+				}
+				for range emptyCells {
+					text, raw = append(text, ""), append(raw, "")
+				}
+				emptyCells = 0
+				for range cellRepeat {
+					text, raw = append(text, value), append(raw, cellRaw)
+				}
+			case sheet != nil && t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				if len(text) == 0 {
+					emptyRows += rowRepeat
+					continue
+				}
+				for range emptyRows {
+					sheet.Rows, sheet.Raw = append(sheet.Rows, nil), append(sheet.Raw, nil)
+				}
+				emptyRows = 0
+				for range rowRepeat {
+					sheet.Rows, sheet.Raw = append(sheet.Rows, text), append(sheet.Raw, raw)
+				}
+			}
+		}
+	}
+	return book, nil
+}
+
+// Значение атрибута элемента ("" — атрибута нет)
+func odsAttr(element xml.StartElement, space, local string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Число повторов строки или ячейки (по умолчанию одна)
+func odsRepeat(element xml.StartElement, attr string) int {
+	if n, err := strconv.Atoi(odsAttr(element, odsTableNS, attr)); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Значение ячейки без форматирования по её типу. Дата с временем приводится
+// к виду "2006-01-02 15:04:05", который разбирается форматами дат по умолчанию.
+func odsCellValue(element xml.StartElement) string {
+	switch odsAttr(element, odsOfficeNS, "value-type") {
+	case "float", "percentage", "currency":
+		return odsAttr(element, odsOfficeNS, "value")
+	case "date":
+		return strings.Replace(odsAttr(element, odsOfficeNS, "date-value"), "T", " ", 1)
+	case "boolean":
+		return strings.ToUpper(odsAttr(element, odsOfficeNS, "boolean-value"))
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Правила выбора названия при повторной встрече товара
+const (
+	NamePolicyLongest = "longest" // Более длинное название заменяет сохранённое (по умолчанию)
+	NamePolicyFirst   = "first"   // Сохраняется первое встреченное название
+	NamePolicyLast    = "last"    // Каждое новое непустое название заменяет сохранённое
+)
+
+// Выбор между названиями равной длины для правила longest
+const (
+	NameTieBreakKeep         = "keep"         // Остаётся сохранённое (при параллельной обработке — случайное) (по умолчанию)
+	NameTieBreakAlphabetical = "alphabetical" // Остаётся меньшее в порядке сортировки строк
+	NameTieBreakPriority     = "priority"     // Остаётся название из файла с большим приоритетом, при равенстве — меньшее по алфавиту
+)
+
+// Правила выбора цены при повторной встрече товара
+const (
+	PricePolicyMin   = "min"   // Сохраняется минимальная цена (по умолчанию)
+	PricePolicyMax   = "max"   // Сохраняется максимальная цена
+	PricePolicyFirst = "first" // Сохраняется первая известная цена
+	PricePolicyLast  = "last"  // Каждая новая известная цена заменяет сохранённую
+)
+
+// Обратный проход (-reverse): файлы в обратном порядке конфигурации, листы,
+// строки и группы колонок — с конца. Последнее (в прямом порядке) непустое
+// значение встречается в обратном проходе первым, поэтому правило last равносильно
+// правилу first при обратном проходе: результат тот же, но каждое поле записывается
+// один раз вместо перезаписи при каждой новой встрече товара. Правило first
+// по той же причине выполняется как last.
+func effectivePolicy(policy, last, first string) string {
+	if !*flagReverse {
+		return policy
+	}
+	switch policy {
+	case last:
+		return first
+	case first:
+		return last
+	}
+	return policy
+}
+
+// Следует ли заменить сохранённое название новым согласно config.NamePolicy.
+// Приоритеты источников используются только для разрешения равенства длин.
+func preferName(stored, candidate string, storedPriority, candidatePriority int) bool {
+	switch effectivePolicy(config.NamePolicy, NamePolicyLast, NamePolicyFirst) {
+	case NamePolicyFirst:
+		return stored == "" && candidate != ""
+	case NamePolicyLast:
+		return candidate != "" && candidate != stored
+	}
+
+	if len(candidate) != len(stored) || candidate == stored {
+		return len(candidate) > len(stored)
+	}
+
+	// Равная длина, разные названия: детерминированный выбор независимо от порядка файлов
+	switch config.NameTieBreak {
+	case NameTieBreakAlphabetical:
+		return candidate < stored
+	case NameTieBreakPriority:
+		if candidatePriority != storedPriority {
+			return candidatePriority > storedPriority
+		}
+		return candidate < stored
+	default:
+		// keep: остаётся встреченное первым в прямом порядке, при обратном
+		// проходе это название встречается последним
+		return *flagReverse
+	}
+}
+
+// Следует ли заменить сохранённую цену новой согласно config.PricePolicy.
+// Неизвестная цена (nil) никогда не заменяет известную.
+func preferPrice(stored, candidate *float64) bool {
+	if candidate == nil {
+		return false
+	}
+	if stored == nil {
+		return true
+	}
+	switch effectivePolicy(config.PricePolicy, PricePolicyLast, PricePolicyFirst) {
+	case PricePolicyMax:
+		return *candidate > *stored
+	case PricePolicyFirst:
+		return false
+	case PricePolicyLast:
+		return *candidate != *stored
+	default:
+		return *candidate < *stored
+	}
+}
+
+// Разбор цены из ячейки: допускаются пробелы-разделители разрядов и десятичная запятая.
+// Возвращает nil, если цена не указана или не является числом.
+func parsePrice(raw string) *float64 {
+	cleaned := strings.Map(func(char rune) rune {
+		switch char {
+		case ' ', '\u00a0', '\u202f': // Пробел, неразрывный и узкий неразрывный пробелы
+			return -1
+		case ',':
+			return '.'
+		}
+		return char
+	}, strings.TrimSpace(raw))
+	if cleaned == "" {
+		return nil
+	}
+	price, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || price < 0 {
+		return nil
+	}
+	return &price
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Название и цена одного товара из двух файлов выбираются каждое по своей политике
+func TestNameAndPricePolicies(t *testing.T) {
+	first := [][]string{{"AB-100", "Bosch", "Фильтр масляный", "500"}}
+	second := [][]string{{"AB-100", "Bosch", "Фильтр", "450,00"}}
+	tests := []struct {
+		name        string
+		namePolicy  string
+		pricePolicy string
+		wantName    string
+		wantPrice   float64
+	}{
+		{"длиннее название, меньше цена", NamePolicyLongest, PricePolicyMin, "Фильтр масляный", 450},
+		{"по умолчанию", "", "", "Фильтр масляный", 450},
+		{"длиннее название, больше цена", NamePolicyLongest, PricePolicyMax, "Фильтр масляный", 500},
+		{"последнее название, первая цена", NamePolicyLast, PricePolicyFirst, "Фильтр", 500},
+		{"первое название, последняя цена", NamePolicyFirst, PricePolicyLast, "Фильтр масляный", 450},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{NamePolicy: tt.namePolicy, PricePolicy: tt.pricePolicy})
+			db := newTestDB(t)
+			columns := ColumnSettings{Article: 1, Brand: 2, Name: 3, Price: 4}
+			importSheets(t, db, FileConfig{Filename: "first.xlsx", Columns: columns}, memoryWorkbook{{Name: "Лист1", Rows: first}})
+			importSheets(t, db, FileConfig{Filename: "second.xlsx", Columns: columns}, memoryWorkbook{{Name: "Лист1", Rows: second}})
+
+			products := storedProducts(t, db)
+			if len(products) != 1 {
+				t.Fatalf("записей %d, ожидалась одна", len(products))
+			}
+			p := products[0]
+			if p.Name != tt.wantName || p.Price == nil || *p.Price != tt.wantPrice {
+				t.Errorf("название %q, цена %v; ожидалось %q, %v", p.Name, p.Price, tt.wantName, tt.wantPrice)
+			}
+		})
+	}
+}
+
+// Неизвестная цена не заменяет известную ни при какой политике
+func TestPreferPriceUnknown(t *testing.T) {
+	price := 100.0
+	for _, policy := range []string{PricePolicyMin, PricePolicyMax, PricePolicyFirst, PricePolicyLast} {
+		setTestConfig(t, Config{PricePolicy: policy})
+		if preferPrice(&price, nil) {
+			t.Errorf("%s: неизвестная цена заменила известную", policy)
+		}
+		if !preferPrice(nil, &price) {
+			t.Errorf("%s: известная цена не заменила неизвестную", policy)
+		}
+	}
+}
+
+// Названия равной длины из разных файлов: выбор по name_tie_break не зависит
+// от порядка файлов (кроме keep, где остаётся записанное первым)
+func TestNameTieBreak(t *testing.T) {
+	a := FileConfig{Filename: "a.xlsx", Priority: 1}
+	b := FileConfig{Filename: "b.xlsx", Priority: 5}
+	names := map[string]string{"a.xlsx": "Фильтр Б", "b.xlsx": "Фильтр А"}
+	tests := []struct {
+		tieBreak string
+		order    []FileConfig
+		want     string
+	}{
+		{NameTieBreakKeep, []FileConfig{a, b}, "Фильтр Б"},
+		{NameTieBreakKeep, []FileConfig{b, a}, "Фильтр А"},
+		{NameTieBreakAlphabetical, []FileConfig{a, b}, "Фильтр А"},
+		{NameTieBreakAlphabetical, []FileConfig{b, a}, "Фильтр А"},
+		{NameTieBreakPriority, []FileConfig{a, b}, "Фильтр А"},
+		{NameTieBreakPriority, []FileConfig{b, a}, "Фильтр А"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tieBreak+"/"+tt.order[0].Filename, func(t *testing.T) {
+			setTestConfig(t, Config{NameTieBreak: tt.tieBreak})
+			db := newTestDB(t)
+			for _, fc := range tt.order {
+				fc.Columns = ColumnSettings{Article: 1, Brand: 2, Name: 3}
+				importSheets(t, db, fc, memoryWorkbook{{Name: "Лист1", Rows: [][]string{{"AB-100", "Bosch", names[fc.Filename]}}}})
+			}
+			products := storedProducts(t, db)
+			if len(products) != 1 || products[0].Name != tt.want {
+				t.Errorf("записи %v, ожидалось название %q", productTriples(products), tt.want)
+			}
+		})
+	}
+
+	// Приоритет важнее алфавита: файл с большим приоритетом побеждает
+	setTestConfig(t, Config{NameTieBreak: NameTieBreakPriority})
+	if !preferName("Фильтр А", "Фильтр Б", 1, 5) || preferName("Фильтр Б", "Фильтр А", 5, 1) {
+		t.Error("при равной длине не выбрано название файла с большим приоритетом")
+	}
+}
+
+// Файлы для сравнения прямого и обратного прохода: товары встречаются
+// на разных листах и в разных файлах, в том числе с названиями равной длины
+var reverseTestFiles = []struct {
+	name string
+	book memoryWorkbook
+}{
+	{"first.xlsx", memoryWorkbook{
+		{Name: "Фильтры", Rows: [][]string{{"AB-100", "Bosch", "Фильтр", "500"}, {"AB-200", "Bosch", "Свеча", "90"}, {"AB-300", "Bosch", "Датчик Б", "70"}}},
+		{Name: "Новинки", Rows: [][]string{{"AB-100", "Bosch", "Фильтр масляный", ""}, {"AB-100", "Bosch", "", "480"}}},
+	}},
+	{"second.xlsx", memoryWorkbook{
+		{Name: "Лист1", Rows: [][]string{{"AB-200", "Bosch", "Свеча зажигания", ""}, {"GDB-1550", "TRW", "Колодки", "1500"}, {"AB-200", "Bosch", "", "95"}, {"AB-300", "Bosch", "Датчик А", "75"}}},
+	}},
+}
+
+// Импорт reverseTestFiles в прямом или обратном порядке: товары в виде строк
+// и число запросов UPDATE
+func importReverseTestFiles(t *testing.T, cfg Config, reverse bool) ([]string, int) {
+	t.Helper()
+	setFlag(t, flagReverse, reverse)
+	setTestConfig(t, cfg)
+	db := newTestDB(t)
+	updates := 0
+	if err := db.Callback().Update().Before("gorm:update").Register("test:count_updates", func(*gorm.DB) { updates++ }); err != nil {
+		t.Fatal(err)
+	}
+	// Порядок файлов задаёт main: при -reverse — с конца конфигурации
+	order := []int{0, 1}
+	if reverse {
+		order = []int{1, 0}
+	}
+	columns := ColumnSettings{Article: 1, Brand: 2, Name: 3, Price: 4}
+	for _, i := range order {
+		importSheets(t, db, FileConfig{Filename: reverseTestFiles[i].name, Columns: columns}, reverseTestFiles[i].book)
+	}
+
+	var products []string
+	for _, p := range storedProducts(t, db) {
+		products = append(products, fmt.Sprintf("%s/%s %q %v", p.Article, p.Brand, p.Name, *p.Price))
+	}
+	slices.Sort(products) // id зависят от порядка создания
+	return products, updates
+}
+
+// Обратный проход (-reverse) с правилами last даёт тот же результат, что и прямой,
+// но пишет каждое поле один раз: последнее значение встречается первым
+func TestReverseLastWins(t *testing.T) {
+	cfg := Config{NamePolicy: NamePolicyLast, PricePolicy: PricePolicyLast}
+	forward, forwardUpdates := importReverseTestFiles(t, cfg, false)
+	reverse, reverseUpdates := importReverseTestFiles(t, cfg, true)
+	want := []string{`ab100/bosch "Фильтр масляный" 480`, `ab200/bosch "Свеча зажигания" 95`, `ab300/bosch "Датчик А" 75`, `gdb1550/trw "Колодки" 1500`}
+	if !slices.Equal(forward, want) {
+		t.Errorf("прямой проход: %v, ожидалось %v", forward, want)
+	}
+	if !slices.Equal(reverse, forward) {
+		t.Errorf("обратный проход: %v, прямой: %v", reverse, forward)
+	}
+	if reverseUpdates >= forwardUpdates {
+		t.Errorf("обновлений при обратном проходе %d, при прямом %d", reverseUpdates, forwardUpdates)
+	}
+}
+
+// Остальные политики при обратном проходе тоже дают результат прямого:
+// first выполняется как last, а keep при равной длине оставляет встреченное
+// первым в прямом порядке
+func TestReverseMatchesForward(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want []string
+	}{
+		{Config{NamePolicy: NamePolicyFirst, PricePolicy: PricePolicyFirst},
+			[]string{`ab100/bosch "Фильтр" 500`, `ab200/bosch "Свеча" 90`, `ab300/bosch "Датчик Б" 70`, `gdb1550/trw "Колодки" 1500`}},
+		{Config{NamePolicy: NamePolicyFirst, PricePolicy: PricePolicyLast},
+			[]string{`ab100/bosch "Фильтр" 480`, `ab200/bosch "Свеча" 95`, `ab300/bosch "Датчик Б" 75`, `gdb1550/trw "Колодки" 1500`}},
+		{Config{NamePolicy: NamePolicyLast, PricePolicy: PricePolicyFirst},
+			[]string{`ab100/bosch "Фильтр масляный" 500`, `ab200/bosch "Свеча зажигания" 90`, `ab300/bosch "Датчик А" 70`, `gdb1550/trw "Колодки" 1500`}},
+		{Config{NamePolicy: NamePolicyLongest, NameTieBreak: NameTieBreakKeep, PricePolicy: PricePolicyMin},
+			[]string{`ab100/bosch "Фильтр масляный" 480`, `ab200/bosch "Свеча зажигания" 90`, `ab300/bosch "Датчик Б" 70`, `gdb1550/trw "Колодки" 1500`}},
+		{Config{NamePolicy: NamePolicyLongest, NameTieBreak: NameTieBreakAlphabetical, PricePolicy: PricePolicyMax},
+			[]string{`ab100/bosch "Фильтр масляный" 500`, `ab200/bosch "Свеча зажигания" 95`, `ab300/bosch "Датчик А" 75`, `gdb1550/trw "Колодки" 1500`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cfg.NamePolicy+"/"+tt.cfg.NameTieBreak+"/"+tt.cfg.PricePolicy, func(t *testing.T) {
+			forward, _ := importReverseTestFiles(t, tt.cfg, false)
+			reverse, _ := importReverseTestFiles(t, tt.cfg, true)
+			if !slices.Equal(forward, tt.want) {
+				t.Errorf("прямой проход: %v, ожидалось %v", forward, tt.want)
+			}
+			if !slices.Equal(reverse, forward) {
+				t.Errorf("обратный проход: %v, прямой: %v", reverse, forward)
+			}
+		})
+	}
+}
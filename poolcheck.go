@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Размеры пула соединений по умолчанию
+const (
+	defaultMaxOpenConns = 50
+	defaultMaxIdleConns = 20
+)
+
+// Режимы предварительной проверки пула против возможностей сервера
+const (
+	PoolCheckOff   = "off"   // Не проверять
+	PoolCheckWarn  = "warn"  // Предупредить, если пул больше свободных соединений (по умолчанию)
+	PoolCheckError = "error" // Завершить работу
+	PoolCheckClamp = "clamp" // Уменьшить пул до числа свободных соединений
+)
+
+// Соединения сервера, которые проверка оставляет другим клиентам и администратору
+const poolCheckReserve = 5
+
+// Свободные соединения сервера: max_connections минус уже открытые и резерв
+func serverFreeConnections(db *gorm.DB) (int, error) {
+	var variable struct {
+		VariableName string
+		Value        string
+	}
+	if err := db.Raw("SHOW VARIABLES LIKE 'max_connections'").Scan(&variable).Error; err != nil {
+		return 0, err
+	}
+	maxConns, err := strconv.Atoi(variable.Value)
+	if err != nil {
+		return 0, fmt.Errorf("неожиданное значение max_connections %q", variable.Value)
+	}
+
+	var status struct {
+		VariableName string
+		Value        string
+	}
+	if err := db.Raw("SHOW STATUS LIKE 'Threads_connected'").Scan(&status).Error; err != nil {
+		return 0, err
+	}
+	connected, err := strconv.Atoi(status.Value)
+	if err != nil {
+		return 0, fmt.Errorf("неожиданное значение Threads_connected %q", status.Value)
+	}
+
+	return max(maxConns-connected-poolCheckReserve, 0), nil
+}
+
+// Проверка размера пула до запуска обработки файлов. Возвращает размер пула,
+// который следует использовать (меньше запрошенного только в режиме clamp).
+func checkPoolSize(db *gorm.DB, requested int, mode string) (int, error) {
+	if mode == PoolCheckOff {
+		return requested, nil
+	}
+
+	free, err := serverFreeConnections(db)
+	if err != nil {
+		log.Printf("Не удалось проверить число соединений сервера: %v\n", err)
+		return requested, nil
+	}
+	if requested <= free {
+		return requested, nil
+	}
+
+	switch mode {
+	case PoolCheckError:
+		return 0, fmt.Errorf("пул соединений (%d) больше числа свободных соединений сервера (%d)", requested, free)
+	case PoolCheckClamp:
+		clamped := max(free, 1)
+		log.Printf("Пул соединений уменьшен с %d до %d: столько соединений свободно на сервере\n", requested, clamped)
+		return clamped, nil
+	default:
+		log.Printf("Внимание: пул соединений (%d) больше числа свободных соединений сервера (%d), возможны ошибки подключения\n", requested, free)
+		return requested, nil
+	}
+}
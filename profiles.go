@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Переменная окружения с именем профиля, если не задан -profile
+const envProfile = "XLSXTOSQL_PROFILE"
+
+// Профиль окружения (dev, staging, prod): настройки, которыми окружения
+// отличаются при общей конфигурации. Незаданные поля не меняют конфигурацию,
+// -input и -output, заданные в командной строке, важнее профиля.
+type ProfileSettings struct {
+	DSN    string `json:"dsn,omitempty"`        // Строка подключения к базе
+	Input  string `json:"input,omitempty"`      // Директория с xlsx файлами
+	Output string `json:"output,omitempty"`     // Файл SQL выгрузки
+	Table  string `json:"table_name,omitempty"` // Имя таблицы товаров
+}
+
+// Применение профиля: -profile, иначе XLSXTOSQL_PROFILE; без имени ничего не меняется
+func applyProfile() error {
+	name := *flagProfile
+	if name == "" {
+		name = os.Getenv(envProfile)
+	}
+	if name == "" {
+		return nil
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("профиль %q не описан в конфигурации (есть: %s)", name, strings.Join(sortedKeys(config.Profiles), ", "))
+	}
+
+	if err := expandEnvValue(reflect.ValueOf(&profile).Elem(), "profiles."+name); err != nil {
+		return err
+	}
+
+	if profile.DSN != "" {
+		config.DSN = profile.DSN
+	}
+	if profile.Table != "" {
+		config.TableName = profile.Table
+	}
+	if profile.Input != "" && !flagPassed("input") {
+		*flagInput = profile.Input
+	}
+	if profile.Output != "" && !flagPassed("output") {
+		*flagOutput = profile.Output
+	}
+	return nil
+}
+
+// Флаг задан в командной строке явно
+func flagPassed(name string) bool {
+	var passed []string
+	flag.Visit(func(f *flag.Flag) { passed = append(passed, f.Name) })
+	return slices.Contains(passed, name)
+}
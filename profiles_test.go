@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Профиль выбирается -profile или XLSXTOSQL_PROFILE и заменяет только заданные в нём настройки
+func TestApplyProfile(t *testing.T) {
+	const configJSON = `{
+		"dsn": "root@tcp(localhost:3306)/testdb",
+		"table_name": "products",
+		"default_columns": {"brand": 1, "article": 2, "name": 3},
+		"profiles": {
+			"dev": {"dsn": "root@tcp(localhost:3306)/dev", "input": "./dev-prices"},
+			"prod": {
+				"dsn": "${TEST_DB_USER}@tcp(db.internal:3306)/shop",
+				"input": "/srv/prices",
+				"output": "/srv/export/products.sql",
+				"table_name": "catalog_products"
+			}
+		}
+	}`
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(configJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		flag       string // -profile
+		env        string // XLSXTOSQL_PROFILE
+		wantDSN    string
+		wantTable  string
+		wantInput  string
+		wantOutput string
+		wantErr    bool
+	}{
+		{"без профиля", "", "", "root@tcp(localhost:3306)/testdb", "products", "./prices", "output.sql", false},
+		{"dev: только заданные поля", "dev", "", "root@tcp(localhost:3306)/dev", "products", "./dev-prices", "output.sql", false},
+		{"prod с подстановкой окружения", "prod", "", "importer@tcp(db.internal:3306)/shop", "catalog_products", "/srv/prices", "/srv/export/products.sql", false},
+		{"из окружения", "", "dev", "root@tcp(localhost:3306)/dev", "products", "./dev-prices", "output.sql", false},
+		{"флаг важнее окружения", "prod", "dev", "importer@tcp(db.internal:3306)/shop", "catalog_products", "/srv/prices", "/srv/export/products.sql", false},
+		{"неизвестный профиль", "staging", "", "", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, Config{})
+			prevPath := loadedConfigPath
+			t.Cleanup(func() { loadedConfigPath = prevPath })
+			setFlag(t, flagConfig, path)
+			setFlag(t, flagProfile, tt.flag)
+			setFlag(t, flagInput, "./prices")
+			setFlag(t, flagOutput, "output.sql")
+			t.Setenv(envProfile, tt.env)
+			t.Setenv("TEST_DB_USER", "importer")
+
+			err := loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config.DSN != tt.wantDSN || config.TableName != tt.wantTable {
+				t.Errorf("dsn %q, таблица %q; ожидалось %q, %q", config.DSN, config.TableName, tt.wantDSN, tt.wantTable)
+			}
+			if *flagInput != tt.wantInput || *flagOutput != tt.wantOutput {
+				t.Errorf("input %q, output %q; ожидалось %q, %q", *flagInput, *flagOutput, tt.wantInput, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRawKeyOf(t *testing.T) {
+	long := strings.Repeat("я", rawKeyMaxLength)
+	tests := []struct {
+		name           string
+		article, brand string
+		want           string // "" — ожидается SHA-256
+	}{
+		{"обрезка пробелов", " A-1 ", " Bosch ", "A-1\x1fBosch"},
+		{"регистр сохраняется", "a-1", "BOSCH", "a-1\x1fBOSCH"},
+		{"ровно по длине колонки", long[:len(long)-2*len("я")], "я", long[:len(long)-2*len("я")] + "\x1fя"},
+		{"длиннее колонки", long, "Bosch", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rawKeyOf(tt.article, tt.brand)
+			if utf8.RuneCountInString(got) > rawKeyMaxLength {
+				t.Fatalf("длина ключа %d больше %d", utf8.RuneCountInString(got), rawKeyMaxLength)
+			}
+			if tt.want == "" {
+				if !strings.HasPrefix(got, "sha256:") {
+					t.Fatalf("ожидался SHA-256, получено %q", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("rawKeyOf(%q, %q) = %q, ожидалось %q", tt.article, tt.brand, got, tt.want)
+			}
+		})
+	}
+
+	// Длинные ключи разных товаров различаются
+	if rawKeyOf(long, "Bosch") == rawKeyOf(long, "Makita") {
+		t.Error("SHA-256 разных длинных ключей совпал")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Отклонённая строка исходного файла
+type RejectedRow struct {
+	File   string   `json:"file"`   // Путь к файлу
+	Sheet  string   `json:"sheet"`  // Имя листа
+	Row    int      `json:"row"`    // Номер строки на листе (с единицы)
+	Reason string   `json:"reason"` // Причина отклонения
+	Values []string `json:"values"` // Исходные значения ячеек
+}
+
+// Пороги отклонённых строк, после которых обработка прекращается
+type RejectLimits struct {
+	MaxRows  int     `json:"max_rows"`  // Максимум отклонённых строк в файле (0 — без ограничения)
+	MaxRatio float64 `json:"max_ratio"` // Максимальная доля отклонённых строк (0 — без ограничения)
+	MinRows  int     `json:"min_rows"`  // Минимум просмотренных строк до проверки доли (по умолчанию 100)
+	AbortRun bool    `json:"abort_run"` // Останавливать весь запуск, а не только файл
+}
+
+// Минимум строк до проверки доли отклонённых по умолчанию
+const defaultRejectMinRows = 100
+
+var rejectsMu sync.Mutex
+var rejects []RejectedRow
+
+// Счётчики обработки одного файла
+type fileStats struct {
+	mu       sync.Mutex
+	File     string // Путь к файлу
+	Rows     int    // Просмотрено строк данных
+	Rejected int    // Отклонено строк
+}
+
+// Учёт просмотренной строки данных
+func (s *fileStats) countRow() {
+	s.mu.Lock()
+	s.Rows++
+	s.mu.Unlock()
+}
+
+// Регистрация отклонённой строки. Возвращает ошибку, если превышен порог
+// отклонённых строк и обработку файла следует прекратить.
+func (s *fileStats) reject(sheet string, row int, reason string, values []string, limits RejectLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Rejected++
+	counters.Rejected.Add(1)
+
+	rejectsMu.Lock()
+	rejects = append(rejects, RejectedRow{
+		File:   s.File,
+		Sheet:  sheet,
+		Row:    row,
+		Reason: reason,
+		Values: append([]string(nil), values...),
+	})
+	rejectsMu.Unlock()
+
+	return s.checkRejectLimits(limits)
+}
+
+// Проверка порогов отклонённых строк
+func (s *fileStats) checkRejectLimits(limits RejectLimits) error {
+	if limits.MaxRows > 0 && s.Rejected > limits.MaxRows {
+		return fmt.Errorf("отклонено %d строк (порог %d), вероятно, неверно настроены колонки", s.Rejected, limits.MaxRows)
+	}
+
+	minRows := limits.MinRows
+	if minRows <= 0 {
+		minRows = defaultRejectMinRows
+	}
+	if limits.MaxRatio > 0 && s.Rows >= minRows {
+		ratio := float64(s.Rejected) / float64(s.Rows)
+		if ratio > limits.MaxRatio {
+			return fmt.Errorf("отклонено %.0f%% строк (порог %.0f%%), вероятно, неверно настроены колонки",
+				ratio*100, limits.MaxRatio*100)
+		}
+	}
+	return nil
+}
+
+// Запись отклонённых строк в файл в формате JSON Lines
+func writeRejects(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	rejectsMu.Lock()
+	defer rejectsMu.Unlock()
+	for _, r := range rejects {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
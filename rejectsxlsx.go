@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Максимальная длина имени листа в Excel
+const maxSheetNameLength = 31
+
+// Выгрузка отклонённых строк в xlsx для поставщиков: по листу на исходный файл.
+// Каждая строка содержит координаты в исходном файле, причину и исходные
+// (ненормализованные) значения ячеек.
+func writeRejectsXLSX(path string) error {
+	rejectsMu.Lock()
+	byFile := make(map[string][]RejectedRow)
+	for _, r := range rejects {
+		byFile[r.File] = append(byFile[r.File], r)
+	}
+	rejectsMu.Unlock()
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	used := make(map[string]bool)
+	for i, file := range files {
+		sheet := uniqueSheetName(file, used)
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+		if err := writeRejectsSheet(f, sheet, byFile[file]); err != nil {
+			return fmt.Errorf("лист %s: %w", sheet, err)
+		}
+	}
+
+	return f.SaveAs(path)
+}
+
+// Лист с отклонёнными строками одного файла
+func writeRejectsSheet(f *excelize.File, sheet string, rows []RejectedRow) error {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Sheet != rows[j].Sheet {
+			return rows[i].Sheet < rows[j].Sheet
+		}
+		return rows[i].Row < rows[j].Row
+	})
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r.Values))
+	}
+	header := []any{"Лист", "Строка", "Причина"}
+	for i := 1; i <= width; i++ {
+		name, _ := excelize.ColumnNumberToName(i)
+		header = append(header, "Колонка "+name)
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	for i, r := range rows {
+		values := []any{escapeXLSXFormula(r.Sheet), r.Row, escapeXLSXFormula(r.Reason)}
+		for _, v := range r.Values {
+			values = append(values, escapeXLSXFormula(v))
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := sw.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// Имя листа по имени файла: без расширения и недопустимых символов,
+// не длиннее 31 символа и не совпадающее с уже использованными
+func uniqueSheetName(file string, used map[string]bool) string {
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	base = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, base)
+	if base == "" {
+		base = "file"
+	}
+
+	name := truncateRunes(base, maxSheetNameLength)
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = truncateRunes(base, maxSheetNameLength-len(suffix)) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return value
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gorm.io/gorm"
+)
+
+// Таймаут подключения к серверу SFTP/FTP
+const remoteDialTimeout = 30 * time.Second
+
+// Подключение к серверу SFTP/FTP для -input sftp://user@host/dir или
+// ftp://user@host/dir. Пароль можно задать и в адресе, но лучше здесь через ${VAR}.
+type RemoteSettings struct {
+	Password   string `json:"password,omitempty"`    // Пароль (SFTP — если не задан key_file)
+	KeyFile    string `json:"key_file,omitempty"`    // Закрытый ключ SSH для SFTP
+	KnownHosts string `json:"known_hosts,omitempty"` // Файл known_hosts для проверки сервера SFTP (по умолчанию ~/.ssh/known_hosts)
+	ArchiveDir string `json:"archive_dir,omitempty"` // Куда на сервере переносить успешно обработанные файлы (пусто — не переносить)
+}
+
+// Директория на сервере SFTP или FTP
+type remoteDir interface {
+	List() ([]remoteFile, error)          // Файлы директории (без поддиректорий)
+	Fetch(name string, w io.Writer) error // Содержимое файла
+	Move(name, dir string) error          // Перенос файла в другую директорию сервера
+	Close() error
+}
+
+// Файл на сервере
+type remoteFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Входная директория является адресом SFTP или FTP сервера
+func isRemoteInput(input string) bool {
+	return strings.HasPrefix(input, "sftp://") || strings.HasPrefix(input, "ftp://")
+}
+
+// Подключение к директории сервера по адресу -input
+func openRemoteDir(input string, settings RemoteSettings) (remoteDir, *url.URL, error) {
+	address, err := url.Parse(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	password := settings.Password
+	if p, ok := address.User.Password(); ok && password == "" {
+		password = p
+	}
+	settings.Password = password
+
+	var dir remoteDir
+	switch address.Scheme {
+	case "sftp":
+		dir, err = openSFTPDir(address, settings)
+	case "ftp":
+		dir, err = openFTPDir(address, settings)
+	default:
+		err = fmt.Errorf("неизвестная схема %q", address.Scheme)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось подключиться к %s: %w", address.Redacted(), err)
+	}
+	return dir, address, nil
+}
+
+// Обработка прайс-листов из директории сервера. Файлы поддерживаемых форматов
+// скачиваются в -download-dir; файл, размер и время изменения которого совпадают
+// с уже скачанной копией, не скачивается заново. Возвращает функцию, которую
+// нужно вызвать после завершения обработки: она переносит успешно обработанные
+// файлы в archive_dir (если он задан).
+func dispatchRemote(db *gorm.DB, input string) (func(), error) {
+	settings := config.Remote
+	dir, address, err := openRemoteDir(input, settings)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	files, err := dir.List()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список файлов %s: %w", address.Redacted(), err)
+	}
+	files = slices.DeleteFunc(files, func(f remoteFile) bool { return !isInputFile(f.Name) })
+
+	// Порядок файлов как у локальной директории (см. обработку -input)
+	if *flagSequential || *flagReverse {
+		sort.SliceStable(files, func(i, j int) bool {
+			return configOrder(files[i].Name) < configOrder(files[j].Name)
+		})
+	}
+	if *flagReverse {
+		slices.Reverse(files)
+	}
+
+	type fetched struct {
+		name, source, localPath string
+	}
+	var dispatched []fetched
+	base := strings.TrimSuffix(address.Redacted(), "/")
+	for _, file := range files {
+		source := base + "/" + file.Name
+		localPath, err := fetchRemoteFile(dir, file, source)
+		if err != nil {
+			log.Printf("Не удалось скачать %s: %v\n", source, err)
+			counters.FilesFailed.Add(1)
+			continue
+		}
+		dispatchFileFrom(db, source, localPath)
+		dispatched = append(dispatched, fetched{file.Name, source, localPath})
+	}
+
+	if settings.ArchiveDir == "" || *flagVerify || comparison != nil {
+		return func() {}, nil
+	}
+	return func() {
+		// Соединение могло закрыться за время обработки — подключаемся заново
+		dir, _, err := openRemoteDir(input, settings)
+		if err != nil {
+			log.Printf("Обработанные файлы не перенесены в %s: %v\n", settings.ArchiveDir, err)
+			return
+		}
+		defer dir.Close()
+		for _, f := range dispatched {
+			checksum, err := fileChecksum(f.localPath)
+			if err != nil || !manifest.isCompleted(f.source, checksum) {
+				continue // Файл не обработан полностью — остаётся на месте
+			}
+			if err := dir.Move(f.name, settings.ArchiveDir); err != nil {
+				log.Printf("Не удалось перенести %s в %s: %v\n", f.source, settings.ArchiveDir, err)
+				continue
+			}
+			fmt.Printf("Файл %s перенесён в %s\n", f.source, settings.ArchiveDir)
+		}
+	}, nil
+}
+
+// Скачивание файла сервера в -download-dir, если локальной копии с тем же
+// размером и временем изменения ещё нет
+func fetchRemoteFile(dir remoteDir, file remoteFile, source string) (string, error) {
+	if err := os.MkdirAll(*flagDownloadDir, 0755); err != nil {
+		return "", err
+	}
+	localPath := downloadPath(source, path.Ext(file.Name))
+	if info, err := os.Stat(localPath); err == nil && info.Size() == file.Size && info.ModTime().Equal(file.ModTime) {
+		fmt.Println("Прайс-лист не изменился, используется скачанный ранее ", source)
+		return localPath, nil
+	}
+
+	tmp := localPath + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err := dir.Fetch(file.Name, out); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	// Время изменения копии — как на сервере, для сравнения при следующем запуске
+	if err := os.Chtimes(tmp, file.ModTime, file.ModTime); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, localPath); err != nil {
+		return "", err
+	}
+	fmt.Println("Скачан прайс-лист ", source)
+	return localPath, nil
+}
+
+// Директория сервера SFTP
+type sftpDir struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	path   string
+}
+
+func openSFTPDir(address *url.URL, settings RemoteSettings) (remoteDir, error) {
+	var auth []ssh.AuthMethod
+	if settings.KeyFile != "" {
+		key, err := os.ReadFile(settings.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %s: %w", settings.KeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if settings.Password != "" {
+		auth = append(auth, ssh.Password(settings.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("не заданы password или key_file")
+	}
+
+	knownHostsPath := settings.KnownHosts
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("known_hosts: %w", err)
+	}
+
+	host := address.Host
+	if address.Port() == "" {
+		host = net.JoinHostPort(address.Hostname(), "22")
+	}
+	conn, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            address.User.Username(),
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         remoteDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sftpDir{conn: conn, client: client, path: address.Path}, nil
+}
+
+func (d *sftpDir) List() ([]remoteFile, error) {
+	entries, err := d.client.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var files []remoteFile
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			files = append(files, remoteFile{Name: entry.Name(), Size: entry.Size(), ModTime: entry.ModTime()})
+		}
+	}
+	return files, nil
+}
+
+func (d *sftpDir) Fetch(name string, w io.Writer) error {
+	file, err := d.client.Open(path.Join(d.path, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteTo(w)
+	return err
+}
+
+func (d *sftpDir) Move(name, dir string) error {
+	if !path.IsAbs(dir) {
+		dir = path.Join(d.path, dir)
+	}
+	if err := d.client.MkdirAll(dir); err != nil {
+		return err
+	}
+	return d.client.PosixRename(path.Join(d.path, name), path.Join(dir, name))
+}
+
+func (d *sftpDir) Close() error {
+	d.client.Close()
+	return d.conn.Close()
+}
+
+// Директория сервера FTP
+type ftpDir struct {
+	conn *ftp.ServerConn
+	path string
+}
+
+func openFTPDir(address *url.URL, settings RemoteSettings) (remoteDir, error) {
+	host := address.Host
+	if address.Port() == "" {
+		host = net.JoinHostPort(address.Hostname(), "21")
+	}
+	conn, err := ftp.Dial(host, ftp.DialWithTimeout(remoteDialTimeout))
+	if err != nil {
+		return nil, err
+	}
+	user := address.User.Username()
+	if user == "" {
+		user = "anonymous"
+	}
+	if err := conn.Login(user, settings.Password); err != nil {
+		conn.Quit()
+		return nil, err
+	}
+	dirPath := address.Path
+	if dirPath == "" {
+		dirPath = "/"
+	}
+	return &ftpDir{conn: conn, path: dirPath}, nil
+}
+
+func (d *ftpDir) List() ([]remoteFile, error) {
+	entries, err := d.conn.List(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var files []remoteFile
+	for _, entry := range entries {
+		if entry.Type == ftp.EntryTypeFile {
+			files = append(files, remoteFile{Name: entry.Name, Size: int64(entry.Size), ModTime: entry.Time})
+		}
+	}
+	return files, nil
+}
+
+func (d *ftpDir) Fetch(name string, w io.Writer) error {
+	resp, err := d.conn.Retr(path.Join(d.path, name))
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	_, err = io.Copy(w, resp)
+	return err
+}
+
+func (d *ftpDir) Move(name, dir string) error {
+	if !path.IsAbs(dir) {
+		dir = path.Join(d.path, dir)
+	}
+	d.conn.MakeDir(dir) // Директория могла уже существовать
+	return d.conn.Rename(path.Join(d.path, name), path.Join(dir, name))
+}
+
+func (d *ftpDir) Close() error {
+	return d.conn.Quit()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Сводка запуска для -stats
+type RunStats struct {
+	FilesProcessed  int64   `json:"files_processed"`
+	FilesFailed     int64   `json:"files_failed"`
+	FilesDeadline   int64   `json:"files_deadline"`
+	Rows            int64   `json:"rows"`
+	Rejected        int64   `json:"rejected"`
+	Inserted        int64   `json:"inserted"`
+	Updated         int64   `json:"updated"`
+	Duplicates      int64   `json:"duplicates"`
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	DeadlineExceeded bool `json:"deadline_exceeded,omitempty"` // Срок -max-runtime истёк; при files_deadline = 0 — уже после обработки файлов
+
+	DefaultLayoutFiles []string `json:"default_layout_files,omitempty"` // Файлы, обработанные со стандартной раскладкой
+}
+
+// Файлы без настроек, обработанные со стандартной раскладкой из defaults
+var (
+	defaultLayoutMu    sync.Mutex
+	defaultLayoutFiles []string
+)
+
+// Учёт файла, обработанного со стандартной раскладкой
+func noteDefaultLayoutFile(name string) {
+	defaultLayoutMu.Lock()
+	defaultLayoutFiles = append(defaultLayoutFiles, name)
+	defaultLayoutMu.Unlock()
+}
+
+// Список файлов, обработанных со стандартной раскладкой, по алфавиту без повторов
+func defaultLayoutFileNames() []string {
+	defaultLayoutMu.Lock()
+	defer defaultLayoutMu.Unlock()
+	names := slices.Clone(defaultLayoutFiles)
+	slices.Sort(names)
+	return slices.Compact(names) // В режиме -watch файл мог обрабатываться несколько раз
+}
+
+// Отчёты о запуске: отклонённые строки, сводка и метрики. Не зависят от режима
+// (импорт или -verify) и от того, выполнялась ли выгрузка, поэтому проверочный
+// запуск без выгрузки тоже даёт полный набор отчётов.
+func writeRunReports(elapsed time.Duration) {
+	if names := defaultLayoutFileNames(); len(names) > 0 {
+		log.Printf("Внимание: %d файл(ов) нет в конфигурации, они обработаны со стандартной раскладкой: %s\n",
+			len(names), strings.Join(names, ", "))
+	}
+	if *flagRejects != "" {
+		if err := writeRejects(*flagRejects); err != nil {
+			log.Printf("Не удалось записать отклонённые строки: %v\n", err)
+		}
+	}
+	if *flagRejectsXLSX != "" {
+		if err := writeRejectsXLSX(*flagRejectsXLSX); err != nil {
+			log.Printf("Не удалось записать отклонённые строки в xlsx: %v\n", err)
+		}
+	}
+	if *flagStats != "" {
+		if err := writeRunStats(*flagStats, elapsed); err != nil {
+			log.Printf("Не удалось записать сводку запуска: %v\n", err)
+		}
+	}
+	if *flagMetrics != "" {
+		if err := writePrometheusMetrics(*flagMetrics, elapsed); err != nil {
+			log.Printf("Не удалось записать метрики: %v\n", err)
+		}
+	}
+}
+
+// Запись сводки запуска в JSON
+func writeRunStats(path string, elapsed time.Duration) error {
+	stats := RunStats{
+		FilesProcessed:  counters.FilesProcessed.Load(),
+		FilesFailed:     counters.FilesFailed.Load(),
+		FilesDeadline:   counters.FilesDeadline.Load(),
+		Rows:            counters.Rows.Load(),
+		Rejected:        counters.Rejected.Load(),
+		Inserted:        counters.Inserted.Load(),
+		Updated:         counters.Updated.Load(),
+		Duplicates:      counters.Duplicates.Load(),
+		DurationSeconds: elapsed.Seconds(),
+
+		DeadlineExceeded:   deadlineExceeded(),
+		DefaultLayoutFiles: defaultLayoutFileNames(),
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
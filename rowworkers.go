@@ -0,0 +1,99 @@
+package main
+
+import (
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Число горутин для строк одного листа: row_workers файла, не меньше одной.
+// В последовательном и обратном режимах порядок строк значим, поэтому строки
+// всегда обрабатываются по одной.
+func rowWorkers(fc FileConfig) int {
+	if *flagSequential || *flagReverse || fc.RowWorkers < 1 {
+		return 1
+	}
+	return fc.RowWorkers
+}
+
+// Вызов handle для индексов 0..n-1. При workers = 1 — по порядку (в обратном, если
+// reverse), иначе диапазон делится на workers непрерывных отрезков, обрабатываемых
+// параллельно. Первая ошибка останавливает остальные отрезки и возвращается;
+// по истечении -max-runtime возвращается errDeadlineExceeded.
+func forEachRow(n, workers int, reverse bool, handle func(i int) error) error {
+	if workers <= 1 || n < 2 {
+		for k := 0; k < n; k++ {
+			if deadlineExceeded() {
+				return errDeadlineExceeded
+			}
+			i := k
+			if reverse {
+				i = n - 1 - k
+			}
+			if err := handle(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wgRows   sync.WaitGroup
+		stopped  atomic.Bool
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		stopped.Store(true)
+	}
+
+	span := (n + workers - 1) / workers
+	for start := 0; start < n; start += span {
+		end := min(start+span, n)
+		wgRows.Add(1)
+		go func(start, end int) {
+			defer wgRows.Done()
+			for i := start; i < end && !stopped.Load(); i++ {
+				if deadlineExceeded() {
+					fail(errDeadlineExceeded)
+					return
+				}
+				if err := handle(i); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}(start, end)
+	}
+	wgRows.Wait()
+	return firstErr
+}
+
+// Блокировки по хэшу: поиск и запись одного товара не должны перемежаться,
+// иначе две строки с одним article+brand (из параллельных файлов или отрезков
+// одного листа) обе не найдут запись и обе попытаются её создать.
+var hashLocks [256]sync.Mutex
+
+// Блокировка товара по одному или нескольким ключам (хэш, штрихкод); возвращает
+// функцию разблокировки. Блокировки берутся по возрастанию номера, и ключи,
+// попавшие в одну блокировку, не блокируют её дважды — без взаимных блокировок.
+func lockHash(keys ...string) func() {
+	var indexes []int
+	for _, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		indexes = append(indexes, int(h.Sum32()%uint32(len(hashLocks))))
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+	for _, i := range indexes {
+		hashLocks[i].Lock()
+	}
+	return func() {
+		for _, i := range slices.Backward(indexes) {
+			hashLocks[i].Unlock()
+		}
+	}
+}
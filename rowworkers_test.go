@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Блокировка по нескольким ключам, в том числе попавшим в одну блокировку,
+// не блокирует сама себя и не даёт взаимной блокировки при разном порядке ключей
+func TestLockHashKeys(t *testing.T) {
+	// Ключи, попадающие в одну блокировку
+	var same []string
+	for i := 0; len(same) < 2; i++ {
+		key := fmt.Sprintf("ean:%d", i)
+		if len(same) == 0 || lockIndex(key) == lockIndex(same[0]) {
+			same = append(same, key)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lockHash(same...)()
+		var wg sync.WaitGroup
+		for i := range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a, b := fmt.Sprintf("hash:%d", i%7), fmt.Sprintf("ean:%d", i%5)
+				if i%2 == 0 {
+					a, b = b, a
+				}
+				lockHash(a, b)()
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("взаимная блокировка")
+	}
+}
+
+// Номер блокировки ключа, как в lockHash
+func lockIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % uint32(len(hashLocks))
+}
+
+// Размер листа BenchmarkRowWorkers и база для него. По умолчанию лист
+// небольшой, а база — сервер в памяти, отвечающий на каждый запрос
+// миллисекундами: миллион строк на нём шёл бы часами. Замер на миллионе
+// строк — с настоящим MySQL:
+//
+//	XLSXTOSQL_BENCH_ROWS=1000000 XLSXTOSQL_BENCH_DSN='user:pass@tcp(127.0.0.1:3306)/bench' \
+//	go test -run '^$' -bench BenchmarkRowWorkers -benchtime 1x
+const (
+	envBenchRows     = "XLSXTOSQL_BENCH_ROWS"
+	envBenchDSN      = "XLSXTOSQL_BENCH_DSN"
+	defaultBenchRows = 1000
+)
+
+// Число строк листа для замера: XLSXTOSQL_BENCH_ROWS или значение по умолчанию
+func benchRows(b *testing.B) int {
+	value := os.Getenv(envBenchRows)
+	if value == "" {
+		return defaultBenchRows
+	}
+	rows, err := strconv.Atoi(value)
+	if err != nil || rows < 2 {
+		b.Fatalf("%s: ожидается число строк не меньше 2, получено %q", envBenchRows, value)
+	}
+	return rows
+}
+
+// Пустая таблица товаров для замера: в базе XLSXTOSQL_BENCH_DSN (таблица
+// пересоздаётся) или на тестовом сервере в памяти
+func newBenchDB(b *testing.B) *gorm.DB {
+	dsn := os.Getenv(envBenchDSN)
+	if dsn == "" {
+		return newTestDB(b)
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(&Product{}); err != nil {
+		b.Fatal(err)
+	}
+	if err := db.AutoMigrate(&Product{}); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Обработка одного большого листа несколькими горутинами (row_workers).
+// Каждый артикул встречается в листе дважды: дедупликация между горутинами
+// должна оставить по одной записи на товар. Размер листа и база — см. benchRows
+// и newBenchDB; с -short замер пропускается.
+func BenchmarkRowWorkers(b *testing.B) {
+	if testing.Short() {
+		b.Skip("замер обработки листа пропущен в режиме -short")
+	}
+	products := benchRows(b) / 2
+	rows := make([][]string, 0, 2*products)
+	for i := range 2 * products {
+		rows = append(rows, []string{fmt.Sprintf("AB-%d", i%products), "Bosch", fmt.Sprintf("Фильтр %d", i), fmt.Sprint(100 + i%7)})
+	}
+	book := memoryWorkbook{{Name: "Лист1", Rows: rows}}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fc := FileConfig{Filename: "prices.xlsx", Columns: ColumnSettings{Article: 1, Brand: 2, Name: 3, Price: 4}, RowWorkers: workers}
+			for range b.N {
+				b.StopTimer()
+				setTestConfig(b, Config{})
+				db := newBenchDB(b)
+				b.StartTimer()
+
+				importSheets(b, db, fc, book)
+
+				b.StopTimer()
+				var count int64
+				if err := db.Model(&Product{}).Count(&count).Error; err != nil {
+					b.Fatal(err)
+				}
+				if count != int64(products) {
+					b.Fatalf("записей %d, ожидалось %d", count, products)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
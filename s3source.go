@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Префикс -input для чтения прайс-листов из S3 совместимого хранилища
+const s3Scheme = "s3://"
+
+// Регион S3 по умолчанию
+const defaultS3Region = "us-east-1"
+
+// Хэш пустого тела запроса для подписи GET запросов
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Подключение к S3 или MinIO. Незаданные значения берутся из стандартных
+// переменных окружения AWS: AWS_ENDPOINT_URL, AWS_REGION, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN.
+type S3Settings struct {
+	Endpoint     string `json:"endpoint,omitempty"`      // Адрес хранилища, например "http://minio:9000" (по умолчанию AWS)
+	Region       string `json:"region,omitempty"`        // Регион (по умолчанию us-east-1)
+	AccessKey    string `json:"access_key,omitempty"`    // Ключ доступа
+	SecretKey    string `json:"secret_key,omitempty"`    // Секретный ключ, например "${S3_SECRET_KEY}"
+	SessionToken string `json:"session_token,omitempty"` // Токен временных учётных данных
+}
+
+// Настройки с подставленными переменными окружения и значениями по умолчанию
+func (s S3Settings) resolved() S3Settings {
+	fallback := func(value *string, envs ...string) {
+		for _, env := range envs {
+			if *value == "" {
+				*value = os.Getenv(env)
+			}
+		}
+	}
+	fallback(&s.Endpoint, "AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	fallback(&s.Region, "AWS_REGION", "AWS_DEFAULT_REGION")
+	fallback(&s.AccessKey, "AWS_ACCESS_KEY_ID")
+	fallback(&s.SecretKey, "AWS_SECRET_ACCESS_KEY")
+	fallback(&s.SessionToken, "AWS_SESSION_TOKEN")
+	if s.Region == "" {
+		s.Region = defaultS3Region
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	s.Endpoint = strings.TrimSuffix(s.Endpoint, "/")
+	return s
+}
+
+// Разбор -input вида s3://bucket/prefix
+func parseS3Input(input string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(input, s3Scheme)
+	if !ok {
+		return "", "", fmt.Errorf("ожидается адрес %sbucket/prefix, получено %q", s3Scheme, input)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("в адресе %q не задан bucket", input)
+	}
+	return bucket, prefix, nil
+}
+
+// Объект хранилища из ответа ListObjectsV2
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+// Обработка прайс-листов из хранилища: объекты под префиксом с расширением
+// поддерживаемого формата скачиваются в -download-dir (повторно — только
+// изменённые) и обрабатываются как файлы директории. Настройки ищутся по
+// имени объекта без префикса каталогов.
+func dispatchS3(db *gorm.DB, input string) error {
+	bucket, prefix, err := parseS3Input(input)
+	if err != nil {
+		return err
+	}
+	settings := config.S3.resolved()
+
+	objects, err := listS3Objects(settings, bucket, prefix)
+	if err != nil {
+		return fmt.Errorf("не удалось получить список объектов %s: %w", input, err)
+	}
+	objects = slices.DeleteFunc(objects, func(o s3Object) bool { return !isInputFile(o.Key) })
+
+	// Порядок объектов как у файлов директории (см. обработку -input)
+	if *flagSequential || *flagReverse {
+		sort.SliceStable(objects, func(i, j int) bool {
+			return configOrder(path.Base(objects[i].Key)) < configOrder(path.Base(objects[j].Key))
+		})
+	}
+	if *flagReverse {
+		slices.Reverse(objects)
+	}
+
+	for _, object := range objects {
+		source := s3Scheme + bucket + "/" + object.Key
+		localPath, err := downloadCached(source, path.Ext(object.Key), func() (*http.Request, error) {
+			return settings.newRequest(bucket, object.Key, nil)
+		})
+		if err != nil {
+			log.Printf("Не удалось скачать %s: %v\n", source, err)
+			counters.FilesFailed.Add(1)
+			continue
+		}
+		dispatchFileFrom(db, source, localPath)
+	}
+	return nil
+}
+
+// Список объектов под префиксом (все страницы ListObjectsV2)
+func listS3Objects(settings S3Settings, bucket, prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := settings.newRequest(bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := downloadClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("хранилище ответило %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		if result.NextContinuationToken == "" {
+			return nil, errors.New("список объектов обрезан без continuation token")
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// GET запрос к объекту key (пустой key — к самому bucket) с подписью AWS
+// Signature V4. Адресация path-style: её поддерживают и AWS, и MinIO.
+func (s S3Settings) newRequest(bucket, key string, query url.Values) (*http.Request, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес хранилища %q: %w", s.Endpoint, err)
+	}
+	canonicalPath := "/" + s3Escape(bucket, false)
+	if key != "" {
+		canonicalPath += "/" + s3Escape(key, true)
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+
+	address := endpoint.Scheme + "://" + endpoint.Host + canonicalPath
+	if canonicalQuery != "" {
+		address += "?" + canonicalQuery
+	}
+	req, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.AccessKey == "" {
+		return req, nil // Публичный bucket: запрос без подписи
+	}
+
+	s.sign(req, endpoint.Host, canonicalPath, canonicalQuery, time.Now())
+	return req, nil
+}
+
+// Подпись GET запроса без тела (AWS Signature V4): заголовки X-Amz-* и Authorization.
+// Возвращает канонический запрос и строку для подписи — промежуточные значения
+// алгоритма, по которым подпись сверяется с примерами из документации AWS.
+func (s S3Settings) sign(req *http.Request, host, canonicalPath, canonicalQuery string, now time.Time) (canonicalRequest, stringToSign string) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\nx-amz-content-sha256:" + emptyPayloadHash + "\nx-amz-date:" + amzDate + "\n"
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + s.SessionToken + "\n"
+	}
+
+	canonicalRequest = strings.Join([]string{
+		http.MethodGet, canonicalPath, canonicalQuery, canonicalHeaders, signedHeaders, emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign = "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+	return canonicalRequest, stringToSign
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Кодирование по правилам подписи V4: не кодируются только A-Z, a-z, 0-9 и "-._~",
+// а в пути объекта ещё и "/"
+func s3Escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Параметры запроса, отсортированные и закодированные для подписи
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Значение -schema, при котором имя схемы строится по времени запуска
+const schemaAuto = "auto"
+
+var schemaNameRe = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+
+// Имя схемы запуска: заданное явно или import_<время запуска> для "auto"
+func resolveSchemaName(value string, now time.Time) (string, error) {
+	if value == schemaAuto {
+		return "import_" + now.Format("20060102_150405"), nil
+	}
+	if !schemaNameRe.MatchString(value) {
+		return "", fmt.Errorf("недопустимое имя схемы %q: ожидаются латинские буквы, цифры и _ (до 64 символов)", value)
+	}
+	return value, nil
+}
+
+// Создание схемы (если её нет) и подключение к ней. Все дальнейшие операции,
+// включая AutoMigrate и очистку таблицы, выполняются в этой схеме, поэтому
+// параллельные запуски с разными схемами не мешают друг другу.
+func openSchema(db *gorm.DB, dsn, schema string) (*gorm.DB, error) {
+	stmt := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci",
+		quoteIdent(schema, IdentQuoteBacktick))
+	if err := db.Exec(stmt).Error; err != nil {
+		return nil, fmt.Errorf("не удалось создать схему %s: %w", schema, err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DBName = schema
+	return gorm.Open(mysql.Open(cfg.FormatDSN()), &gorm.Config{})
+}
@@ -0,0 +1,64 @@
+package main
+
+// Выбор листов файла и колонки отдельных листов. Имена листов задаются
+// точно, glob шаблоном ("Группа *") или регулярным выражением с префиксом "re:".
+type SheetSettings struct {
+	Include []string        `json:"include,omitempty"` // Обрабатывать только эти листы (пусто — все)
+	Exclude []string        `json:"exclude,omitempty"` // Пропускать листы (например "Контакты", "Условия")
+	Columns []SheetOverride `json:"columns,omitempty"` // Колонки листов с отдельной раскладкой
+}
+
+// Колонки листа, отличающиеся от колонок файла
+type SheetOverride struct {
+	Sheet      string         `json:"sheet"`                 // Имя или шаблон листа
+	Columns    ColumnSettings `json:"columns"`               // Колонки листа (не заданы — как у файла)
+	HeaderRows *int           `json:"header_rows,omitempty"` // Строк шапки листа (по умолчанию как у файла)
+	Brand      string         `json:"brand,omitempty"`       // Бренд всех товаров листа (колонка бренда не используется)
+}
+
+// Лист обрабатывается: попадает в include (если он задан) и не попадает в exclude
+func (s *SheetSettings) included(sheet string) bool {
+	if s == nil {
+		return true
+	}
+	for _, pattern := range s.Exclude {
+		if matchName(pattern, sheet) {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, pattern := range s.Include {
+		if matchName(pattern, sheet) {
+			return true
+		}
+	}
+	return false
+}
+
+// Настройки файла для листа: колонки, шапка и бренд из первого подходящего переопределения.
+// Названия заголовков в колонках листа важнее блока headers файла.
+func (fc FileConfig) forSheet(sheet string) FileConfig {
+	if fc.Sheets == nil {
+		return fc
+	}
+	for _, override := range fc.Sheets.Columns {
+		if matchName(override.Sheet, sheet) {
+			if override.Columns != (ColumnSettings{}) { // Можно задать только шапку листа
+				fc.Columns = override.Columns
+				if !override.Columns.HeaderNames.empty() {
+					fc.Headers = nil
+				}
+			}
+			if override.HeaderRows != nil {
+				fc.HeaderRows = *override.HeaderRows
+			}
+			if override.Brand != "" {
+				fc.Brand = override.Brand
+			}
+			return fc
+		}
+	}
+	return fc
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Обработка записей конфигурации с внешним источником (таблица Google, URL).
+// Источник читается до запуска обработки: по его содержимому считается
+// контрольная сумма для -resume.
+func dispatchExternalSources(db *gorm.DB) {
+	configMu.RLock()
+	var sources []FileConfig
+	for _, fc := range config.Files {
+		if fc.external() {
+			sources = append(sources, fc)
+		}
+	}
+	configMu.RUnlock()
+
+	for _, fc := range sources {
+		switch {
+		case fc.GoogleSheet != nil:
+			sourcePath := fc.GoogleSheet.sourcePath()
+			book, checksum, err := fetchGoogleSheet(fc.GoogleSheet)
+			if err != nil {
+				log.Printf("Не удалось прочитать таблицу Google %s (%s): %v\n", fc.Filename, sourcePath, err)
+				counters.FilesFailed.Add(1)
+				continue
+			}
+			startProcessing(db, sourcePath, checksum, fc, func() (workbook, error) {
+				return book, nil
+			})
+
+		case fc.URL != "":
+			localPath, err := downloadSource(fc.URL)
+			if err != nil {
+				log.Printf("Не удалось скачать %s: %v\n", fc.URL, err)
+				counters.FilesFailed.Add(1)
+				continue
+			}
+			if isArchiveFile(localPath) {
+				// Настройки прайс-листов архива ищутся по именам файлов в нём
+				dispatchArchive(db, fc.URL, localPath)
+				continue
+			}
+			checksum, err := fileChecksum(localPath)
+			if err != nil {
+				log.Printf("Не удалось вычислить контрольную сумму файла '%s': %v\n", localPath, err)
+				continue
+			}
+			startProcessing(db, fc.URL, checksum, fc, func() (workbook, error) {
+				return openWorkbook(localPath, fc)
+			})
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Имя прайс-листа из стандартного ввода в журналах и отчётах; настройки
+// ищутся по нему с расширением формата, например запись "stdin.csv"
+const stdinSourceName = "stdin"
+
+// Сохранение стандартного ввода во временный файл с расширением формата
+// (-format), чтобы он читался так же, как файл директории. Файл удаляется
+// вызывающим после обработки.
+func readStdinInput(format string) (string, error) {
+	ext := "." + strings.ToLower(strings.TrimPrefix(format, "."))
+	if !isInputFile("file" + ext) {
+		formats := make([]string, len(inputExtensions))
+		for i, e := range inputExtensions {
+			formats[i] = strings.TrimPrefix(e, ".")
+		}
+		return "", fmt.Errorf("неизвестный формат %q (ожидается один из %s)", format, strings.Join(formats, ", "))
+	}
+
+	file, err := os.CreateTemp("", "xlsxtosql-stdin-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, os.Stdin); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
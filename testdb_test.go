@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Тестовая база: сервер MySQL в памяти процесса (go-mysql-server), пустая
+// схема без таблиц. Сервер останавливается по окончании теста.
+func newEmptyTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	logrus.SetOutput(io.Discard) // Журнал сервера не нужен в выводе тестов
+
+	provider := memory.NewDBProvider(memory.NewDatabase("test"))
+	engine := sqle.NewDefault(provider)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	srv, err := server.NewDefaultServer(server.Config{Protocol: "tcp", Address: address}, engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Close() })
+
+	dsn := fmt.Sprintf("root@tcp(%s)/test?charset=utf8mb4&parseTime=True&loc=Local", address)
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Тестовая база с таблицей товаров
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db := newEmptyTestDB(t)
+	if err := db.AutoMigrate(&Product{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Глобальная конфигурация, кэш хэшей и отклонённые строки на время теста;
+// прежние значения восстанавливаются по его окончании
+func setTestConfig(t testing.TB, c Config) {
+	t.Helper()
+	prevConfig, prevCache, prevRejects := config, hashCache, rejects
+	config, hashCache, rejects = c, &HashCache{}, nil
+	t.Cleanup(func() { config, hashCache, rejects = prevConfig, prevCache, prevRejects })
+}
+
+// Причины отклонения строк за время теста
+func rejectedReasons() []string {
+	rejectsMu.Lock()
+	defer rejectsMu.Unlock()
+	reasons := make([]string, len(rejects))
+	for i, r := range rejects {
+		reasons[i] = r.Reason
+	}
+	return reasons
+}
+
+// Обработка прайс-листа из листов в памяти, как файла с настройками fc
+func importSheets(t testing.TB, db *gorm.DB, fc FileConfig, book memoryWorkbook) {
+	t.Helper()
+	err := processXLSXFileWithConfig(db, fc.Filename, fc, func() (workbook, error) {
+		return book, nil
+	})
+	if err != nil {
+		t.Fatalf("обработка %s: %v", fc.Filename, err)
+	}
+}
+
+// Записи таблицы товаров по возрастанию id
+func storedProducts(t testing.TB, db *gorm.DB) []Product {
+	t.Helper()
+	var products []Product
+	if err := db.Order("id").Find(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	return products
+}
+
+// Артикул, бренд и название записей — для сравнения с ожидаемыми
+func productTriples(products []Product) [][3]string {
+	triples := make([][3]string, len(products))
+	for i, p := range products {
+		triples[i] = [3]string{p.Article, p.Brand, p.Name}
+	}
+	return triples
+}
+
+// Значение флага на время теста
+func setFlag[T any](t testing.TB, flag *T, value T) {
+	t.Helper()
+	prev := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = prev })
+}
+
+// Выгрузка таблицы в SQL файл во временной директории; возвращает его содержимое
+func exportSQL(t testing.TB, db *gorm.DB, opts ExportOptions) string {
+	t.Helper()
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+	output := filepath.Join(t.TempDir(), "output.sql")
+	if err := exportToSQLFile(db, output, opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Предлагаемые правила нормализации для сравнения с текущими (-compare-rules).
+// Незаданные поля берутся из текущих правил файла.
+type ProposedRules struct {
+	ArticleStripChars    *[]string `json:"article_strip_chars"`
+	CaseSensitiveArticle *bool     `json:"case_sensitive_article"`
+	LegacyHash           *bool     `json:"legacy_hash"`
+}
+
+// Предлагаемые правила на основе текущих правил файла
+func (p ProposedRules) apply(current NormalizeRules) NormalizeRules {
+	proposed := current
+	if p.ArticleStripChars != nil {
+		proposed.ArticleStripChars = *p.ArticleStripChars
+	}
+	if p.CaseSensitiveArticle != nil {
+		proposed.CaseSensitiveArticle = *p.CaseSensitiveArticle
+	}
+	if p.LegacyHash != nil {
+		proposed.LegacyHash = *p.LegacyHash
+	}
+	return proposed
+}
+
+// Ключ товара в сравнении правил: пример исходных значений
+type tuningSample struct {
+	Article string `json:"article"`
+	Brand   string `json:"brand"`
+}
+
+// Сопоставление хэшей по текущим и предлагаемым правилам
+type rulesComparison struct {
+	mu       sync.Mutex
+	rows     int
+	current  map[string]map[string]bool // Текущий хэш → предлагаемые хэши
+	proposed map[string]map[string]bool // Предлагаемый хэш → текущие хэши
+	samples  map[string]tuningSample    // Текущий хэш → пример строки
+}
+
+// Сравнение правил текущего запуска; nil вне режима -compare-rules
+var comparison *rulesComparison
+
+func newRulesComparison() *rulesComparison {
+	return &rulesComparison{
+		current:  make(map[string]map[string]bool),
+		proposed: make(map[string]map[string]bool),
+		samples:  make(map[string]tuningSample),
+	}
+}
+
+// Учёт строки: хэш по текущим правилам и хэш по предлагаемым
+func (c *rulesComparison) record(rawArticle, rawBrand, article, brand string, rules NormalizeRules) {
+	currentHash := generateHash(article, brand, rules)
+	proposedRules := config.ProposedRules.apply(rules)
+	proposedArticle := normalizeArticle(rawArticle, proposedRules)
+	if proposedArticle == "" {
+		proposedArticle = article // Значение по умолчанию из column_defaults
+	}
+	proposedHash := generateHash(proposedArticle, brand, proposedRules)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rows++
+	if c.current[currentHash] == nil {
+		c.current[currentHash] = make(map[string]bool)
+		c.samples[currentHash] = tuningSample{Article: rawArticle, Brand: rawBrand}
+	}
+	c.current[currentHash][proposedHash] = true
+	if c.proposed[proposedHash] == nil {
+		c.proposed[proposedHash] = make(map[string]bool)
+	}
+	c.proposed[proposedHash][currentHash] = true
+}
+
+// Итог сравнения правил
+type RulesComparisonReport struct {
+	Rows           int              `json:"rows"`            // Учтено строк
+	CurrentUnique  int              `json:"current_unique"`  // Уникальных товаров по текущим правилам
+	ProposedUnique int              `json:"proposed_unique"` // Уникальных товаров по предлагаемым правилам
+	Merged         int              `json:"merged"`          // Товаров предлагаемых правил, объединивших несколько текущих
+	Split          int              `json:"split"`           // Текущих товаров, разделённых предлагаемыми правилами
+	MergedExamples [][]tuningSample `json:"merged_examples"` // Примеры объединений (до 20)
+	SplitExamples  []tuningSample   `json:"split_examples"`  // Примеры разделений (до 20)
+}
+
+// Число примеров каждого вида в отчёте
+const comparisonExamples = 20
+
+// Запись отчёта о сравнении правил
+func (c *rulesComparison) writeReport(path string) (RulesComparisonReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := RulesComparisonReport{
+		Rows:           c.rows,
+		CurrentUnique:  len(c.current),
+		ProposedUnique: len(c.proposed),
+		MergedExamples: [][]tuningSample{},
+		SplitExamples:  []tuningSample{},
+	}
+
+	for _, currentHashes := range sortedGroups(c.proposed) {
+		if len(currentHashes) < 2 {
+			continue
+		}
+		report.Merged++
+		if len(report.MergedExamples) < comparisonExamples {
+			var group []tuningSample
+			for _, hash := range currentHashes {
+				group = append(group, c.samples[hash])
+			}
+			report.MergedExamples = append(report.MergedExamples, group)
+		}
+	}
+	for _, hash := range sortedKeys(c.current) {
+		if len(c.current[hash]) < 2 {
+			continue
+		}
+		report.Split++
+		if len(report.SplitExamples) < comparisonExamples {
+			report.SplitExamples = append(report.SplitExamples, c.samples[hash])
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	return report, os.WriteFile(path, data, 0644)
+}
+
+// Группы значений в порядке ключей (для воспроизводимых примеров)
+func sortedGroups(m map[string]map[string]bool) [][]string {
+	var groups [][]string
+	for _, key := range sortedKeys(m) {
+		groups = append(groups, sortedKeys(m[key]))
+	}
+	return groups
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Режим -compare-rules: файлы читаются без записи в базу, для каждой строки
+// считаются хэши по текущим и по предлагаемым (proposed_rules) правилам,
+// отчёт показывает, как изменится число уникальных товаров.
+func runRulesComparison(dirPath, reportPath string) error {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+
+	comparison = newRulesComparison()
+	for _, file := range files {
+		if isInputFile(file.Name()) {
+			dispatchFile(nil, filepath.Join(dirPath, file.Name())) // База в этом режиме не используется
+		}
+	}
+	wg.Wait()
+
+	report, err := comparison.writeReport(reportPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Уникальных товаров: %d по текущим правилам, %d по предлагаемым (объединений: %d, разделений: %d)\n",
+		report.CurrentUnique, report.ProposedUnique, report.Merged, report.Split)
+	log.Printf("Отчёт о сравнении правил записан в %s\n", reportPath)
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Повторы скачивания при сетевой ошибке или ответе 5xx
+const (
+	downloadAttempts  = 3
+	downloadBaseDelay = 2 * time.Second
+)
+
+// Таймаут скачивания одного прайс-листа
+const downloadTimeout = 5 * time.Minute
+
+var downloadClient = &http.Client{Timeout: downloadTimeout}
+
+// Сведения о скачанном файле для условного запроса при следующем запуске
+type downloadMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// Ответ сервера, после которого имеет смысл повторить запрос
+var errDownloadRetry = errors.New("временная ошибка сервера")
+
+// Скачивание прайс-листа по адресу в -download-dir. Рядом с файлом хранятся его
+// ETag и Last-Modified: если сервер ответил 304, используется уже скачанный файл.
+// Возвращает путь к локальной копии.
+func downloadSource(address string) (string, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("ожидается адрес http или https, получено %q", address)
+	}
+	return downloadCached(address, path.Ext(parsed.Path), func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, address, nil)
+	})
+}
+
+// Скачивание в -download-dir с условным запросом и повторами. source — имя
+// источника (адрес), ext — расширение, по которому определится формат файла,
+// newRequest строит запрос заново для каждой попытки.
+func downloadCached(source, ext string, newRequest func() (*http.Request, error)) (string, error) {
+	if err := os.MkdirAll(*flagDownloadDir, 0755); err != nil {
+		return "", err
+	}
+
+	localPath := downloadPath(source, ext)
+	metaPath := localPath + ".json"
+
+	var meta downloadMeta
+	if _, err := os.Stat(localPath); err == nil {
+		if data, err := os.ReadFile(metaPath); err == nil {
+			json.Unmarshal(data, &meta)
+		}
+	}
+
+	var err error
+	delay := downloadBaseDelay
+	for attempt := 1; ; attempt++ {
+		err = fetchSource(source, newRequest, localPath, &meta)
+		if !errors.Is(err, errDownloadRetry) || attempt >= downloadAttempts {
+			break
+		}
+		log.Printf("Скачивание %s: %v, повтор через %v (попытка %d из %d)\n", source, err, delay, attempt+1, downloadAttempts)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if err != nil {
+		return "", err
+	}
+
+	meta.URL = source
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// Путь локальной копии в -download-dir: имя — по источнику, расширение
+// определяет формат файла (по умолчанию xlsx)
+func downloadPath(source, ext string) string {
+	sum := sha256.Sum256([]byte(source))
+	ext = strings.ToLower(ext)
+	if !isInputFile("file" + ext) {
+		ext = ".xlsx"
+	}
+	return filepath.Join(*flagDownloadDir, hex.EncodeToString(sum[:8])+ext)
+}
+
+// Один запрос к серверу. Новое содержимое записывается через временный файл,
+// чтобы оборванная загрузка не заменила прежнюю копию.
+func fetchSource(source string, newRequest func() (*http.Request, error), localPath string, meta *downloadMeta) error {
+	req, err := newRequest()
+	if err != nil {
+		return err
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errDownloadRetry, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		fmt.Println("Прайс-лист не изменился, используется скачанный ранее ", source)
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", errDownloadRetry, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("сервер ответил %s", resp.Status)
+	}
+
+	tmp := localPath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("%w: %v", errDownloadRetry, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, localPath); err != nil {
+		return err
+	}
+
+	meta.ETag = resp.Header.Get("ETag")
+	meta.LastModified = resp.Header.Get("Last-Modified")
+	fmt.Println("Скачан прайс-лист ", source)
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Подкоманда проверки конфигурации
+const commandValidateConfig = "validate-config"
+
+// Результат проверки конфигурации
+type configCheck struct {
+	errors   []string
+	warnings []string
+}
+
+func (c *configCheck) errorf(format string, args ...any) {
+	c.errors = append(c.errors, fmt.Sprintf(format, args...))
+}
+
+func (c *configCheck) warnf(format string, args ...any) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// Проверка конфигурации без подключения к базе. Ошибки: файл не соответствует
+// схеме (неизвестные ключи, неверные типы), отрицательные или повторяющиеся
+// номера колонок, нет колонок артикула и названия. Предупреждения: записи для
+// файлов, которых нет в -input, и xlsx файлы без записи в конфигурации.
+// Возвращает код завершения: 1 при ошибках, иначе 0.
+func runValidateConfig(dirPath string) int {
+	var check configCheck
+	if err := loadConfig(); err != nil {
+		check.errorf("%v", err)
+	} else {
+		if loadedConfigPath != "" {
+			if err := checkConfigSchema(loadedConfigPath); err != nil {
+				check.errorf("%s: %v", loadedConfigPath, err)
+			}
+		}
+		checkConfigColumns(&check)
+		checkConfigFiles(&check, dirPath)
+	}
+
+	for _, w := range check.warnings {
+		fmt.Println("Предупреждение:", w)
+	}
+	for _, e := range check.errors {
+		fmt.Println("Ошибка:", e)
+	}
+	if len(check.errors) > 0 {
+		fmt.Printf("Конфигурация содержит ошибки: %d, предупреждений: %d\n", len(check.errors), len(check.warnings))
+		return 1
+	}
+	fmt.Printf("Конфигурация корректна, предупреждений: %d\n", len(check.warnings))
+	return 0
+}
+
+// Строгий разбор файла: неизвестные ключи считаются ошибкой (опечатка в имени
+// настройки иначе молча игнорируется)
+func checkConfigSchema(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	docs, err := configDocuments(path, data)
+	if err != nil {
+		return err
+	}
+	for i, encoded := range docs {
+		if err := strictUnmarshal(encoded); err != nil {
+			return fmt.Errorf("документ %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Разбор JSON в Config с запретом неизвестных ключей
+func strictUnmarshal(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var target Config
+	return decoder.Decode(&target)
+}
+
+// Проверка номеров колонок всех наборов колонок конфигурации
+func checkConfigColumns(check *configCheck) {
+	if config.DefaultColumns != nil {
+		checkColumnSettings(check, "default_columns", *config.DefaultColumns, false)
+	}
+	if config.Defaults != nil {
+		checkColumnSettings(check, "defaults.columns", config.Defaults.Columns, false)
+	}
+
+	seen := make(map[string]bool)
+	for _, fc := range config.Files {
+		if seen[fc.Filename] {
+			check.warnf("файл %s описан несколько раз, используется первая запись", fc.Filename)
+		}
+		seen[fc.Filename] = true
+
+		where := "файл " + fc.Filename
+		if fc.HeaderRows < 0 {
+			check.errorf("%s: отрицательное число строк шапки header_rows: %d", where, fc.HeaderRows)
+		}
+		if _, err := fc.CSV.delimiter(); err != nil {
+			check.errorf("%s: %v", where, err)
+		}
+		if _, err := fc.CSV.encoding(); err != nil {
+			check.errorf("%s: %v", where, err)
+		}
+		if fc.GoogleSheet != nil && fc.GoogleSheet.SpreadsheetID == "" {
+			check.errorf("%s: не задан google_sheet.spreadsheet_id", where)
+		}
+		if fc.URL != "" && fc.GoogleSheet != nil {
+			check.errorf("%s: url и google_sheet не задаются вместе", where)
+		}
+		fixedBrand := fc.BrandFromSheet || fc.Brand != ""
+		switch {
+		case len(fc.Groups) > 0:
+			for i, group := range fc.Groups {
+				checkColumnSettings(check, fmt.Sprintf("%s, группа %d", where, i+1), group, fixedBrand)
+			}
+		case fc.Headers != nil:
+			headers := fc.Headers
+			if strings.TrimSpace(headers.Article) == "" && fc.Columns.Article <= 0 {
+				check.errorf("%s: не задан заголовок колонки артикула", where)
+			}
+			if strings.TrimSpace(headers.Name) == "" && fc.Columns.Name <= 0 {
+				check.errorf("%s: не задан заголовок колонки названия", where)
+			}
+			checkColumnIndexes(check, where, fc.Columns)
+		default:
+			checkColumnSettings(check, where, fc.Columns, fixedBrand)
+		}
+		if fc.Sheets != nil {
+			for _, override := range fc.Sheets.Columns {
+				if override.Columns != (ColumnSettings{}) {
+					checkColumnSettings(check, fmt.Sprintf("%s, лист %s", where, override.Sheet), override.Columns, fixedBrand || override.Brand != "")
+				}
+			}
+		}
+	}
+}
+
+// Проверка набора колонок: обязательные колонки заданы номером или заголовком
+func checkColumnSettings(check *configCheck, where string, c ColumnSettings, fixedBrand bool) {
+	if c.Article == 0 && strings.TrimSpace(c.HeaderNames.Article) == "" {
+		check.errorf("%s: не задана колонка артикула", where)
+	}
+	if c.Name == 0 && strings.TrimSpace(c.HeaderNames.Name) == "" {
+		check.errorf("%s: не задана колонка названия", where)
+	}
+	if !fixedBrand && c.Brand == 0 && strings.TrimSpace(c.HeaderNames.Brand) == "" {
+		check.warnf("%s: не задана колонка бренда", where)
+	}
+	checkColumnIndexes(check, where, c)
+}
+
+// Номера колонок неотрицательны и не повторяются
+func checkColumnIndexes(check *configCheck, where string, c ColumnSettings) {
+	columns := []struct {
+		name  string
+		index int
+	}{
+		{"brand", c.Brand}, {"article", c.Article}, {"name", c.Name},
+		{"date", c.Date}, {"ean", c.EAN}, {"price", c.Price},
+	}
+	used := make(map[int]string)
+	for _, col := range columns {
+		switch {
+		case col.index < 0:
+			check.errorf("%s: отрицательный номер колонки %s: %d", where, col.name, col.index)
+		case col.index == 0:
+			// Колонка не используется или задана заголовком
+		case used[col.index] != "":
+			check.errorf("%s: колонки %s и %s ссылаются на одну колонку %d", where, used[col.index], col.name, col.index)
+		default:
+			used[col.index] = col.name
+		}
+	}
+}
+
+// Сверка записей конфигурации с файлами директории
+func checkConfigFiles(check *configCheck, dirPath string) {
+	if strings.HasPrefix(dirPath, s3Scheme) || isRemoteInput(dirPath) {
+		return // Файлы хранилища и сервера проверяются при запуске
+	}
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		check.warnf("не удалось прочитать директорию %s: %v", dirPath, err)
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isInputFile(entry.Name()) && !isArchiveFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	for _, fc := range config.Files {
+		if fc.external() {
+			continue
+		}
+		if !slices.ContainsFunc(names, func(name string) bool { return matchName(fc.Filename, name) }) {
+			check.warnf("файл %s из конфигурации не найден в %s", fc.Filename, dirPath)
+		}
+	}
+
+	var unmatched []string
+	for _, name := range names {
+		if fileConfigIndex(name) < 0 && !isYMLFile(name) { // Фиды YML читаются и без записи
+			unmatched = append(unmatched, name)
+		}
+	}
+	if len(unmatched) > 0 {
+		note := "будут пропущены"
+		if config.DefaultColumns != nil || defaultsEnabled() {
+			note = "будут обработаны с колонками по умолчанию"
+		}
+		check.warnf("файлы без записи в конфигурации (%s): %s", note, strings.Join(unmatched, ", "))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ожидаемая запись таблицы, собранная из исходных файлов в режиме проверки
+type expectedProduct struct {
+	Article  string
+	Brand    string
+	Name     string // Название, выбранное по name_policy, как при импорте
+	Priority int    // Приоритет источника названия
+	File     string
+	Sheet    string
+	Row      int
+}
+
+var expectedMu sync.Mutex
+var expected = make(map[string]expectedProduct)
+
+// Запоминание ожидаемой записи; при повторах название выбирается по name_policy,
+// так же как это делает импорт при обновлении записи
+func recordExpected(hash string, p expectedProduct) {
+	expectedMu.Lock()
+	defer expectedMu.Unlock()
+	if prev, ok := expected[hash]; ok && !preferName(prev.Name, p.Name, prev.Priority, p.Priority) {
+		return
+	}
+	expected[hash] = p
+}
+
+// Запоминание товара из строки источника как ожидаемой записи
+func recordVerify(p rowProduct) {
+	recordExpected(p.Hash, expectedProduct{
+		Article: p.Article, Brand: p.Brand, Name: p.Name, Priority: p.Priority,
+		File: p.File, Sheet: p.Sheet, Row: p.Row,
+	})
+}
+
+// Товар из источника, отсутствующий в таблице
+type VerifyMissing struct {
+	Hash    string `json:"hash"`
+	Article string `json:"article"`
+	Brand   string `json:"brand"`
+	Name    string `json:"name"`
+	File    string `json:"file"`
+	Sheet   string `json:"sheet"`
+	Row     int    `json:"row"`
+}
+
+// Товар, чьё название в таблице отличается от ожидаемого
+type VerifyMismatch struct {
+	Hash         string `json:"hash"`
+	Article      string `json:"article"`
+	Brand        string `json:"brand"`
+	ExpectedName string `json:"expected_name"`
+	StoredName   string `json:"stored_name"`
+	File         string `json:"file"`
+	Sheet        string `json:"sheet"`
+	Row          int    `json:"row"`
+}
+
+// Отчёт о расхождениях между исходными файлами и таблицей
+type VerifyReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Checked     int              `json:"checked"`
+	Missing     []VerifyMissing  `json:"missing"`
+	Mismatched  []VerifyMismatch `json:"mismatched"`
+}
+
+// Сравнение ожидаемых записей с таблицей. Таблица только читается.
+func verifyAgainstTable(db *gorm.DB, batchSize int) (VerifyReport, error) {
+	report := VerifyReport{
+		GeneratedAt: time.Now(),
+		Missing:     []VerifyMissing{},
+		Mismatched:  []VerifyMismatch{},
+	}
+
+	// Записи копируются под той же блокировкой, под которой добавляются
+	expectedMu.Lock()
+	entries := maps.Clone(expected)
+	expectedMu.Unlock()
+	hashes := slices.Collect(maps.Keys(entries))
+	sort.Strings(hashes)
+	report.Checked = len(hashes)
+
+	for start := 0; start < len(hashes); start += batchSize {
+		end := min(start+batchSize, len(hashes))
+		chunk := hashes[start:end]
+
+		var stored []Product
+		if err := db.Where("hash IN ?", chunk).Find(&stored).Error; err != nil {
+			return report, err
+		}
+		byHash := make(map[string]Product, len(stored))
+		for _, p := range stored {
+			byHash[p.Hash] = p
+		}
+
+		for _, hash := range chunk {
+			exp := entries[hash]
+			p, ok := byHash[hash]
+			switch {
+			case !ok:
+				report.Missing = append(report.Missing, VerifyMissing{
+					Hash: hash, Article: exp.Article, Brand: exp.Brand, Name: exp.Name,
+					File: exp.File, Sheet: exp.Sheet, Row: exp.Row,
+				})
+			case p.Name != exp.Name:
+				report.Mismatched = append(report.Mismatched, VerifyMismatch{
+					Hash: hash, Article: exp.Article, Brand: exp.Brand,
+					ExpectedName: exp.Name, StoredName: p.Name,
+					File: exp.File, Sheet: exp.Sheet, Row: exp.Row,
+				})
+			}
+		}
+	}
+	return report, nil
+}
+
+// Проверка таблицы и запись отчёта о расхождениях в JSON
+func writeVerifyReport(db *gorm.DB, reportPath string, batchSize int) (VerifyReport, error) {
+	report, err := verifyAgainstTable(db, batchSize)
+	if err != nil {
+		return report, fmt.Errorf("не удалось сверить таблицу: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return report, err
+	}
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		return report, err
+	}
+
+	fmt.Printf("Проверено товаров: %d, отсутствует: %d, расхождений в названии: %d. Отчёт: %s\n",
+		report.Checked, len(report.Missing), len(report.Mismatched), reportPath)
+	return report, nil
+}
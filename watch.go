@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gorm.io/gorm"
+)
+
+// Наблюдение за директорией с прайсами: каждый новый или перезаписанный xlsx файл
+// обрабатывается после того, как запись в него прекратилась на время settle.
+// Изменения конфигурационного файла и config.d перечитываются так же после паузы
+// settle: новый поставщик подключается без перезапуска (см. reloadConfig).
+// Функция блокируется до сигнала SIGINT/SIGTERM и дожидается уже запущенных обработок.
+func watchDirectory(db *gorm.DB, dirPath string, settle time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("не удалось создать наблюдатель: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dirPath); err != nil {
+		return fmt.Errorf("не удалось начать наблюдение за '%s': %w", dirPath, err)
+	}
+
+	// Наблюдаем за директорией конфигурации, а не за файлом: редакторы
+	// сохраняют файл через переименование, и наблюдение за ним терялось бы
+	if loadedConfigPath != "" {
+		configDir := filepath.Dir(loadedConfigPath)
+		if err := watcher.Add(configDir); err != nil {
+			return fmt.Errorf("не удалось начать наблюдение за '%s': %w", configDir, err)
+		}
+		fragmentsDir := filepath.Join(configDir, configFragmentsDir)
+		if info, err := os.Stat(fragmentsDir); err == nil && info.IsDir() {
+			if err := watcher.Add(fragmentsDir); err != nil {
+				return fmt.Errorf("не удалось начать наблюдение за '%s': %w", fragmentsDir, err)
+			}
+		}
+	}
+	var reloadTimer *time.Timer
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	// Таймеры отложенной обработки: каждая новая запись в файл сдвигает момент запуска,
+	// поэтому частично скопированный файл не попадёт в обработку
+	var timersMu sync.Mutex
+	timers := make(map[string]*time.Timer)
+
+	// Остановка: отложенные файлы не обрабатываются, начатые дорабатываются
+	shutdown := func() {
+		timersMu.Lock()
+		for _, timer := range timers {
+			timer.Stop()
+		}
+		if reloadTimer != nil {
+			reloadTimer.Stop()
+		}
+		timersMu.Unlock()
+		wg.Wait()
+	}
+
+	fmt.Printf("Наблюдение за директорией %s запущено\n", dirPath)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if isConfigFile(event.Name) && event.Op != fsnotify.Chmod {
+				timersMu.Lock()
+				if reloadTimer != nil {
+					reloadTimer.Reset(settle)
+				} else {
+					reloadTimer = time.AfterFunc(settle, func() {
+						if err := reloadConfig(); err != nil {
+							log.Printf("Конфигурация не перезагружена, действует прежняя: %v\n", err)
+						}
+					})
+				}
+				timersMu.Unlock()
+				continue
+			}
+			if !isInputFile(event.Name) || !event.Has(fsnotify.Create|fsnotify.Write) {
+				continue
+			}
+
+			filePath := event.Name
+			timersMu.Lock()
+			if timer, exists := timers[filePath]; exists {
+				timer.Reset(settle)
+			} else {
+				timers[filePath] = time.AfterFunc(settle, func() {
+					timersMu.Lock()
+					delete(timers, filePath)
+					timersMu.Unlock()
+					dispatchFile(db, filePath)
+				})
+			}
+			timersMu.Unlock()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Ошибка наблюдателя: %v\n", err)
+
+		case <-stop:
+			fmt.Println("Получен сигнал остановки, ожидаем завершения обработки файлов")
+			shutdown()
+			return nil
+
+		case <-runCtx.Done():
+			fmt.Println("Срок работы истёк, ожидаем завершения обработки файлов")
+			shutdown()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Расширения файлов прайс-листов, которые умеет читать обработка
+// (zip — архив с ними, см. dispatchArchive)
+var inputExtensions = append([]string{".xlsx", ".xlsb", ".xls", ".ods", ".csv", archiveExt}, slices.Concat(jsonExtensions, ymlExtensions)...)
+
+// Файл прайс-листа поддерживаемого формата или архив с ними (по расширению, без учёта регистра)
+func isInputFile(name string) bool {
+	return slices.Contains(inputExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// Запись конфигурации описывает внешний источник, а не файл директории -input
+func (fc FileConfig) external() bool {
+	return fc.GoogleSheet != nil || fc.URL != ""
+}
+
+// Прочитанный прайс-лист: листы и их строки в виде текста ячеек, как их
+// возвращает excelize.GetRows. Обработка строк не зависит от формата файла.
+type workbook interface {
+	Sheets() []string                                   // Имена листов по порядку
+	Rows(sheet string) ([][]string, error)              // Строки листа
+	StreamRows(sheet string) (rowStream, error)         // Строки листа по одной, без чтения листа целиком
+	RawCell(sheet string, col, row int) (string, error) // Значение ячейки без форматирования (col и row с единицы)
+	Close() error
+}
+
+// Открытие прайс-листа по расширению файла
+func openWorkbook(filePath string, fc FileConfig) (workbook, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		return openCSVWorkbook(filePath, fc.CSV)
+	case ".xls":
+		return openXLSWorkbook(filePath)
+	case ".xlsb":
+		return openXLSBWorkbook(filePath)
+	case ".ods":
+		return openODSWorkbook(filePath)
+	case ".json", ".jsonl", ".ndjson":
+		return openJSONWorkbook(filePath, fc.JSON)
+	case ".yml", ".xml":
+		return openYMLWorkbook(filePath)
+	case archiveExt:
+		return nil, fmt.Errorf("%s — архив, а не прайс-лист", filepath.Base(filePath))
+	}
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return xlsxWorkbook{File: f, evaluateFormulas: fc.EvaluateFormulas, skipHidden: fc.SkipHidden}, nil
+}
+
+// Файл xlsx, читаемый через excelize
+type xlsxWorkbook struct {
+	*excelize.File
+	evaluateFormulas bool // Вычислять формулы без сохранённого значения (см. formulas.go)
+	skipHidden       bool // Пропускать скрытые строки и колонки (см. hidden.go)
+}
+
+func (b xlsxWorkbook) Sheets() []string {
+	return b.GetSheetList()
+}
+
+func (b xlsxWorkbook) Rows(sheet string) ([][]string, error) {
+	if !b.evaluateFormulas && !b.skipHidden {
+		return b.GetRows(sheet)
+	}
+	stream, err := b.StreamRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return (&sheetReader{stream: stream}).read(-1)
+}
+
+func (b xlsxWorkbook) StreamRows(sheet string) (rowStream, error) {
+	rows, err := b.File.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	var stream rowStream = xlsxRowStream{rows}
+	if b.evaluateFormulas {
+		if stream, err = newFormulaRowStream(b.File, sheet, stream); err != nil {
+			return nil, err
+		}
+	}
+	if b.skipHidden {
+		stream = newHiddenRowStream(b.File, sheet, rows, stream)
+	}
+	return stream, nil
+}
+
+func (b xlsxWorkbook) RawCell(sheet string, col, row int) (string, error) {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return "", err
+	}
+	value, err := b.GetCellValue(sheet, cell, excelize.Options{RawCellValue: true})
+	if err != nil || value != "" || !b.evaluateFormulas {
+		return value, err
+	}
+	return evaluateFormula(b.File, sheet, cell, excelize.Options{RawCellValue: true})
+}
+
+// Лист, целиком прочитанный в память (форматы без отдельного хранения значений)
+type memorySheet struct {
+	Name string
+	Rows [][]string
+	Raw  [][]string // Значения без форматирования той же формы (nil — совпадают с Rows)
+}
+
+// Прайс-лист из листов в памяти: текст ячеек и есть их значение
+type memoryWorkbook []memorySheet
+
+func (b memoryWorkbook) Sheets() []string {
+	names := make([]string, len(b))
+	for i, sheet := range b {
+		names[i] = sheet.Name
+	}
+	return names
+}
+
+func (b memoryWorkbook) Rows(sheet string) ([][]string, error) {
+	s, err := b.sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return s.Rows, nil
+}
+
+func (b memoryWorkbook) StreamRows(sheet string) (rowStream, error) {
+	rows, err := b.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryRowStream{rows: rows, next: -1}, nil
+}
+
+func (b memoryWorkbook) RawCell(sheet string, col, row int) (string, error) {
+	s, err := b.sheet(sheet)
+	if err != nil {
+		return "", err
+	}
+	rows := s.Rows
+	if s.Raw != nil {
+		rows = s.Raw
+	}
+	if row < 1 || row > len(rows) || col < 1 || col > len(rows[row-1]) {
+		return "", nil
+	}
+	return rows[row-1][col-1], nil
+}
+
+func (b memoryWorkbook) sheet(name string) (memorySheet, error) {
+	for _, s := range b {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return memorySheet{}, fmt.Errorf("лист %s не найден", name)
+}
+
+func (b memoryWorkbook) Close() error {
+	return nil
+}
+
+// Пустые ячейки в конце строк убираются, как это делает excelize.GetRows,
+// чтобы проверка числа заполненных колонок работала одинаково для всех форматов
+func trimRowTails(rows [][]string) [][]string {
+	for i, row := range rows {
+		end := len(row)
+		for end > 0 && strings.TrimSpace(row[end-1]) == "" {
+			end--
+		}
+		rows[i] = row[:end]
+	}
+	return rows
+}
+
+// Построчное чтение листа. Пустые строки внутри листа возвращаются пустыми,
+// чтобы номера строк совпадали с номерами на листе.
+type rowStream interface {
+	Next() bool                 // Переход к следующей строке; false — строк больше нет
+	Columns() ([]string, error) // Ячейки текущей строки
+	Error() error               // Ошибка чтения, остановившая Next
+	Close() error
+}
+
+// Потоковое чтение листа xlsx средствами excelize
+type xlsxRowStream struct {
+	*excelize.Rows
+}
+
+func (r xlsxRowStream) Columns() ([]string, error) {
+	return r.Rows.Columns()
+}
+
+// Чтение строк листа, уже находящегося в памяти
+type memoryRowStream struct {
+	rows [][]string
+	next int
+}
+
+func (r *memoryRowStream) Next() bool {
+	r.next++
+	return r.next < len(r.rows)
+}
+
+func (r *memoryRowStream) Columns() ([]string, error) {
+	return r.rows[r.next], nil
+}
+
+func (r *memoryRowStream) Error() error { return nil }
+
+func (r *memoryRowStream) Close() error { return nil }
+
+// Чтение листа порциями ограниченного размера. Пустые строки в конце листа
+// отбрасываются, как это делает excelize.GetRows.
+type sheetReader struct {
+	stream  rowStream
+	empty   int      // Пустые строки, ещё не отданные: отдаются, только если за ними есть данные
+	pending []string // Непустая строка, прочитанная после пустых
+	done    bool     // Лист прочитан до конца
+}
+
+// Следующие строки листа, не больше limit (limit < 0 — все оставшиеся)
+func (r *sheetReader) read(limit int) ([][]string, error) {
+	var rows [][]string
+	for limit < 0 || len(rows) < limit {
+		if r.pending != nil {
+			if r.empty > 0 {
+				rows = append(rows, nil)
+				r.empty--
+				continue
+			}
+			rows = append(rows, r.pending)
+			r.pending = nil
+			continue
+		}
+		if !r.stream.Next() {
+			r.done = true
+			break
+		}
+		row, err := r.stream.Columns()
+		if err != nil {
+			return nil, err
+		}
+		if len(row) == 0 {
+			r.empty++
+			continue
+		}
+		r.pending = row
+	}
+	if err := r.stream.Error(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Лист прочитан, строк больше нет
+func (r *sheetReader) finished() bool {
+	return r.done && r.pending == nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Записи двоичной книги Excel (BIFF12, MS-XLSB), нужные для чтения значений
+const (
+	xlsbRowHeader   = 0   // BrtRowHdr: начало строки
+	xlsbCellBlank   = 1   // BrtCellBlank
+	xlsbCellRK      = 2   // BrtCellRk: число в сжатом виде
+	xlsbCellError   = 3   // BrtCellError
+	xlsbCellBool    = 4   // BrtCellBool
+	xlsbCellReal    = 5   // BrtCellReal
+	xlsbCellString  = 6   // BrtCellSt: строка в ячейке
+	xlsbCellShared  = 7   // BrtCellIsst: индекс общей строки
+	xlsbFmlaString  = 8   // BrtFmlaString: формула с текстовым значением
+	xlsbFmlaNum     = 9   // BrtFmlaNum
+	xlsbFmlaBool    = 10  // BrtFmlaBool
+	xlsbFmlaError   = 11  // BrtFmlaError
+	xlsbSharedItem  = 19  // BrtSSTItem: общая строка
+	xlsbCellRString = 62  // BrtCellRString: строка с форматированием
+	xlsbSheet       = 156 // BrtBundleSh: лист книги
+)
+
+// Файл xlsb читается в память. Текст ячеек — их значения без числовых
+// форматов: числа и даты — как RawCell у xlsx (даты — серийные числа, которые
+// понимает фильтр -since), логические значения — "TRUE"/"FALSE".
+func openXLSBWorkbook(filePath string) (workbook, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	read := func(name string) ([]byte, error) {
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("в файле xlsb нет %s", name)
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+
+	targets, err := xlsbRelationships(read)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if _, ok := files["xl/sharedStrings.bin"]; ok {
+		data, err := read("xl/sharedStrings.bin")
+		if err != nil {
+			return nil, err
+		}
+		if shared, err = xlsbSharedStrings(data); err != nil {
+			return nil, fmt.Errorf("sharedStrings.bin: %w", err)
+		}
+	}
+
+	data, err := read("xl/workbook.bin")
+	if err != nil {
+		return nil, err
+	}
+	var book memoryWorkbook
+	err = xlsbRecords(data, func(kind int, record []byte) error {
+		if kind != xlsbSheet {
+			return nil
+		}
+		// hsState и iTabID, затем идентификатор связи и имя листа
+		if len(record) < 8 {
+			return errors.New("некорректная запись листа")
+		}
+		relID, rest, err := xlsbString(record[8:])
+		if err != nil {
+			return err
+		}
+		name, _, err := xlsbString(rest)
+		if err != nil {
+			return err
+		}
+		target, ok := targets[relID]
+		if !ok {
+			return fmt.Errorf("лист %s: нет связи %s", name, relID)
+		}
+		sheetData, err := read(target)
+		if err != nil {
+			return err
+		}
+		rows, err := xlsbSheetRows(sheetData, shared)
+		if err != nil {
+			return fmt.Errorf("лист %s: %w", name, err)
+		}
+		book = append(book, memorySheet{Name: name, Rows: rows})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Пути частей листов по идентификаторам связей книги
+func xlsbRelationships(read func(string) ([]byte, error)) (map[string]string, error) {
+	data, err := read("xl/_rels/workbook.bin.rels")
+	if err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return nil, fmt.Errorf("workbook.bin.rels: %w", err)
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+	return targets, nil
+}
+
+// Таблица общих строк
+func xlsbSharedStrings(data []byte) ([]string, error) {
+	var shared []string
+	err := xlsbRecords(data, func(kind int, record []byte) error {
+		if kind != xlsbSharedItem {
+			return nil
+		}
+		// Флаги форматирования, затем текст
+		if len(record) < 1 {
+			return errors.New("некорректная общая строка")
+		}
+		text, _, err := xlsbString(record[1:])
+		shared = append(shared, text)
+		return err
+	})
+	return shared, err
+}
+
+// Строки листа в виде текста ячеек
+func xlsbSheetRows(data []byte, shared []string) ([][]string, error) {
+	var rows [][]string
+	row := -1
+	err := xlsbRecords(data, func(kind int, record []byte) error {
+		if kind == xlsbRowHeader {
+			if len(record) < 4 {
+				return errors.New("некорректный заголовок строки")
+			}
+			row = int(binary.LittleEndian.Uint32(record))
+			return nil
+		}
+		if kind < xlsbCellBlank || kind > xlsbFmlaError && kind != xlsbCellRString {
+			return nil
+		}
+		// Ячейка: колонка, стиль и флаги, затем значение
+		if row < 0 || len(record) < 8 {
+			return errors.New("ячейка вне строки")
+		}
+		col := int(binary.LittleEndian.Uint32(record))
+		value, err := xlsbCellValue(kind, record[8:], shared)
+		if err != nil {
+			return fmt.Errorf("строка %d, колонка %d: %w", row+1, col+1, err)
+		}
+		if value == "" {
+			return nil
+		}
+		for len(rows) <= row {
+			rows = append(rows, nil)
+		}
+		for len(rows[row]) <= col {
+			rows[row] = append(rows[row], "")
+		}
+		rows[row][col] = value
+		return nil
+	})
+	return trimRowTails(rows), err
+}
+
+// Текст значения ячейки ("" — пустая ячейка или ошибка вроде #N/A)
+func xlsbCellValue(kind int, value []byte, shared []string) (string, error) {
+	short := errors.New("запись ячейки короче значения")
+	switch kind {
+	case xlsbCellRK:
+		if len(value) < 4 {
+			return "", short
+		}
+		return xlsbNumber(xlsbRK(binary.LittleEndian.Uint32(value))), nil
+	case xlsbCellReal, xlsbFmlaNum:
+		if len(value) < 8 {
+			return "", short
+		}
+		return xlsbNumber(math.Float64frombits(binary.LittleEndian.Uint64(value))), nil
+	case xlsbCellBool, xlsbFmlaBool:
+		if len(value) < 1 {
+			return "", short
+		}
+		return strings.ToUpper(strconv.FormatBool(value[0] != 0)), nil
+	case xlsbCellString, xlsbFmlaString:
+		text, _, err := xlsbString(value)
+		return text, err
+	case xlsbCellRString:
+		if len(value) < 1 {
+			return "", short
+		}
+		text, _, err := xlsbString(value[1:])
+		return text, err
+	case xlsbCellShared:
+		if len(value) < 4 {
+			return "", short
+		}
+		index := int(binary.LittleEndian.Uint32(value))
+		if index >= len(shared) {
+			return "", fmt.Errorf("нет общей строки %d", index)
+		}
+		return shared[index], nil
+	}
+	return "", nil
+}
+
+// Число RK: 30 старших бит целого или старшие биты double, флаг деления на 100
+func xlsbRK(rk uint32) float64 {
+	var value float64
+	if rk&0x02 != 0 {
+		value = float64(int32(rk) >> 2)
+	} else {
+		value = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		value /= 100
+	}
+	return value
+}
+
+func xlsbNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Строка XLWideString: длина в символах UTF-16 и сами символы.
+// Возвращает строку и остаток записи.
+func xlsbString(data []byte) (string, []byte, error) {
+	if len(data) < 4 {
+		return "", nil, errors.New("запись короче строки")
+	}
+	length := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	if length < 0 || length > len(data)/2 {
+		return "", nil, errors.New("запись короче строки")
+	}
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), data[2*length:], nil
+}
+
+// Перебор записей части книги: тип и длина записываются переменным числом
+// байт (по 7 бит в байте, старший бит — продолжение)
+func xlsbRecords(data []byte, handle func(kind int, record []byte) error) error {
+	for len(data) > 0 {
+		kind, n := xlsbVarint(data, 2)
+		if n == 0 {
+			return errors.New("обрезанная запись")
+		}
+		data = data[n:]
+		size, n := xlsbVarint(data, 4)
+		if n == 0 || size > len(data)-n {
+			return errors.New("обрезанная запись")
+		}
+		data = data[n:]
+		if err := handle(kind, data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// Число переменной длины не больше maxBytes байт; 0 байт — данные кончились
+func xlsbVarint(data []byte, maxBytes int) (int, int) {
+	value := 0
+	for i := 0; i < maxBytes && i < len(data); i++ {
+		value |= int(data[i]&0x7F) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"unicode/utf16"
+)
+
+// go test -run TestXLSBFixture -update-xlsb пересоздаёт testdata/prices.xlsb
+var updateXLSB = flag.Bool("update-xlsb", false, "пересоздать testdata/prices.xlsb")
+
+const xlsbFixture = "testdata/prices.xlsb"
+
+// Записи BIFF12 из MS-XLSB, которые пишет Excel вокруг данных и которые
+// чтение должно пропускать
+const (
+	xlsbBeginSheet     = 129
+	xlsbEndSheet       = 130
+	xlsbBeginBook      = 131
+	xlsbEndBook        = 132
+	xlsbBeginSheetData = 145
+	xlsbEndSheetData   = 146
+	xlsbBeginSST       = 159
+	xlsbEndSST         = 160
+)
+
+// Часть книги из записей BIFF12: тип и длина — числа переменной длины
+type xlsbPart struct{ bytes.Buffer }
+
+func (p *xlsbPart) record(kind int, data ...[]byte) {
+	body := slices.Concat(data...)
+	p.varint(kind)
+	p.varint(len(body))
+	p.Write(body)
+}
+
+func (p *xlsbPart) varint(value int) {
+	for value >= 0x80 {
+		p.WriteByte(byte(value&0x7F) | 0x80)
+		value >>= 7
+	}
+	p.WriteByte(byte(value))
+}
+
+func xlsbU32(value uint32) []byte { return binary.LittleEndian.AppendUint32(nil, value) }
+
+func xlsbWide(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	data := xlsbU32(uint32(len(units)))
+	for _, unit := range units {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+// Целое число в виде RK: 30 старших бит и флаг целого
+func xlsbRKInt(value int32) uint32 { return uint32(value<<2) | 0x02 }
+
+// Заголовок строки: номер, стиль, высота, флаги и один диапазон колонок
+func (p *xlsbPart) row(index uint32) {
+	p.record(xlsbRowHeader, xlsbU32(index), xlsbU32(0), []byte{0x2C, 0x01, 0, 0, 0}, xlsbU32(1), xlsbU32(0), xlsbU32(6))
+}
+
+// Ячейка: колонка, стиль и флаги, затем значение
+func (p *xlsbPart) cell(kind int, col uint32, value ...[]byte) {
+	p.record(kind, append([][]byte{xlsbU32(col), xlsbU32(0)}, value...)...)
+}
+
+// Книга xlsb с листом прайса (общие строки, строки в ячейках, числа RK и
+// double, формулы, логическое значение, ошибка, пропущенная строка) и пустым листом
+func writeXLSBFixture(path string) error {
+	shared := []string{"Артикул", "Бренд", "Наименование", "Цена", "Остаток", "Поставка", "OC 90", "W 712/75"}
+	var sst xlsbPart
+	sst.record(xlsbBeginSST, xlsbU32(uint32(len(shared))), xlsbU32(uint32(len(shared))))
+	for _, text := range shared {
+		sst.record(xlsbSharedItem, []byte{0}, xlsbWide(text))
+	}
+	sst.record(xlsbEndSST)
+
+	var book xlsbPart
+	book.record(xlsbBeginBook)
+	book.record(xlsbSheet, xlsbU32(0), xlsbU32(1), xlsbWide("rId1"), xlsbWide("Прайс"))
+	book.record(xlsbSheet, xlsbU32(0), xlsbU32(2), xlsbWide("rId2"), xlsbWide("Пусто"))
+	book.record(xlsbEndBook)
+
+	var sheet xlsbPart
+	sheet.record(xlsbBeginSheet)
+	sheet.record(xlsbBeginSheetData)
+	sheet.row(0)
+	for col := range 6 {
+		sheet.cell(xlsbCellShared, uint32(col), xlsbU32(uint32(col)))
+	}
+	sheet.row(1)
+	sheet.cell(xlsbCellShared, 0, xlsbU32(6))
+	sheet.cell(xlsbCellString, 1, xlsbWide("MANN-FILTER"))
+	sheet.cell(xlsbCellRString, 2, []byte{0}, xlsbWide("Фильтр масляный"))
+	sheet.cell(xlsbCellRK, 3, xlsbU32(12345<<2|0x03))  // 123.45: целое, делённое на 100
+	sheet.cell(xlsbCellRK, 4, xlsbU32(xlsbRKInt(-15))) // -15: целое
+	sheet.cell(xlsbCellReal, 5, binary.LittleEndian.AppendUint64(nil, math.Float64bits(45352.5)))
+	// Строка 3 пропущена
+	sheet.row(3)
+	sheet.cell(xlsbCellShared, 0, xlsbU32(7))
+	sheet.cell(xlsbCellBlank, 1)
+	sheet.cell(xlsbFmlaString, 2, xlsbWide("Фильтр воздушный"), []byte{0, 0}, xlsbU32(0), xlsbU32(0))
+	sheet.cell(xlsbCellRK, 3, xlsbU32(0x3FE00000)) // 0.5: старшие биты double
+	sheet.cell(xlsbFmlaNum, 4, binary.LittleEndian.AppendUint64(nil, math.Float64bits(2)), []byte{0, 0}, xlsbU32(0), xlsbU32(0))
+	sheet.cell(xlsbCellError, 5, []byte{0x2A}) // #N/A
+	sheet.cell(xlsbCellBool, 6, []byte{1})
+	sheet.record(xlsbEndSheetData)
+	sheet.record(xlsbEndSheet)
+
+	var empty xlsbPart
+	empty.record(xlsbBeginSheet)
+	empty.record(xlsbBeginSheetData)
+	empty.record(xlsbEndSheetData)
+	empty.record(xlsbEndSheet)
+
+	const rels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.bin"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.bin"/>` +
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.bin"/>` +
+		`</Relationships>`
+
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, part := range []struct {
+		name string
+		data []byte
+	}{
+		{"xl/workbook.bin", book.Bytes()},
+		{"xl/_rels/workbook.bin.rels", []byte(rels)},
+		{"xl/worksheets/sheet1.bin", sheet.Bytes()},
+		{"xl/worksheets/sheet2.bin", empty.Bytes()},
+		{"xl/sharedStrings.bin", sst.Bytes()},
+	} {
+		f, err := w.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, archive.Bytes(), 0o644)
+}
+
+func TestXLSBFixture(t *testing.T) {
+	if *updateXLSB {
+		if err := writeXLSBFixture(xlsbFixture); err != nil {
+			t.Fatal(err)
+		}
+	}
+	book, err := openWorkbook(xlsbFixture, FileConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+
+	if got := book.Sheets(); !slices.Equal(got, []string{"Прайс", "Пусто"}) {
+		t.Fatalf("листы %q", got)
+	}
+	rows, err := book.Rows("Прайс")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Артикул", "Бренд", "Наименование", "Цена", "Остаток", "Поставка"},
+		{"OC 90", "MANN-FILTER", "Фильтр масляный", "123.45", "-15", "45352.5"},
+		{},
+		{"W 712/75", "", "Фильтр воздушный", "0.5", "2", "", "TRUE"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("строк %d, ожидается %d: %q", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Errorf("строка %d: %q, ожидается %q", i+1, rows[i], want[i])
+		}
+	}
+	if raw, err := book.RawCell("Прайс", 6, 2); err != nil || raw != "45352.5" {
+		t.Errorf("RawCell(F2) = %q, %v", raw, err)
+	}
+
+	empty, err := book.Rows("Пусто")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("строки пустого листа: %q", empty)
+	}
+}
+
+func TestXLSBRK(t *testing.T) {
+	tests := []struct {
+		rk   uint32
+		want float64
+	}{
+		{xlsbRKInt(1), 1},
+		{xlsbRKInt(-7), -7},
+		{12345<<2 | 0x03, 123.45},
+		{0x3FF00000, 1}, // 1.0: старшие биты double
+		{0x3FF00000 | 0x01, 0.01},
+		{0x40590000, 100}, // 100.0
+	}
+	for _, tt := range tests {
+		if got := xlsbRK(tt.rk); got != tt.want {
+			t.Errorf("xlsbRK(%#x) = %v, ожидается %v", tt.rk, got, tt.want)
+		}
+	}
+}
+
+// Обрезанные записи и ссылка на несуществующую общую строку — ошибка, а не паника
+func TestXLSBMalformed(t *testing.T) {
+	var sheet xlsbPart
+	sheet.row(0)
+	sheet.cell(xlsbCellShared, 0, xlsbU32(3))
+	if _, err := xlsbSheetRows(sheet.Bytes(), []string{"a"}); err == nil {
+		t.Error("нет ошибки для индекса общей строки вне таблицы")
+	}
+	if _, err := xlsbSheetRows(sheet.Bytes()[:len(sheet.Bytes())-2], []string{"a", "b", "c", "d"}); err == nil {
+		t.Error("нет ошибки для обрезанной записи")
+	}
+	if _, _, err := xlsbString(xlsbU32(10)); err == nil {
+		t.Error("нет ошибки для строки длиннее записи")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/extrame/xls"
+)
+
+// Файл Excel 97–2003 (BIFF), который excelize не читает. Листы целиком
+// читаются в память; значения ячеек — текст в том виде, в каком их
+// показывает Excel, поэтому canonical_cell_types на такие файлы не влияет.
+func openXLSWorkbook(filePath string) (book workbook, err error) {
+	// Разбор повреждённого файла библиотека может завершить паникой
+	defer func() {
+		if r := recover(); r != nil {
+			book, err = nil, fmt.Errorf("файл xls повреждён или не поддерживается: %v", r)
+		}
+	}()
+
+	wb, closer, err := xls.OpenWithCloser(filePath, "utf-8")
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	if wb == nil {
+		return nil, fmt.Errorf("файл %s не является книгой Excel 97–2003", filePath)
+	}
+
+	sheets := make(memoryWorkbook, 0, wb.NumSheets())
+	for i := 0; i < wb.NumSheets(); i++ {
+		sheet := wb.GetSheet(i)
+		if sheet == nil {
+			continue
+		}
+		var rows [][]string
+		if sheet.MaxRow > 0 || sheet.Row(0) != nil {
+			rows = make([][]string, int(sheet.MaxRow)+1)
+		}
+		for r := range rows {
+			row := sheet.Row(r)
+			if row == nil {
+				continue // Пустая строка листа
+			}
+			cells := make([]string, row.LastCol())
+			for c := row.FirstCol(); c < row.LastCol(); c++ {
+				cells[c] = row.Col(c)
+			}
+			rows[r] = cells
+		}
+		sheets = append(sheets, memorySheet{Name: sheet.Name, Rows: trimRowTails(rows)})
+	}
+	return sheets, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Настройки выгрузки в xlsx
+type XLSXExportSettings struct {
+	NumberFormat string `json:"number_format"` // Формат числовых колонок (по умолчанию "#,##0.00")
+	DateFormat   string `json:"date_format"`   // Формат колонок даты-времени (по умолчанию "yyyy-mm-dd hh:mm:ss")
+}
+
+// Форматы xlsx выгрузки по умолчанию
+const (
+	defaultXLSXNumberFormat = "#,##0.00"
+	defaultXLSXDateFormat   = "yyyy-mm-dd hh:mm:ss"
+)
+
+// Тип значения колонки xlsx выгрузки
+type xlsxKind int
+
+const (
+	xlsxText     xlsxKind = iota // Текст: всегда строковая ячейка, без интерпретации как формулы
+	xlsxNumber                   // Число: числовая ячейка с форматом NumberFormat
+	xlsxDateTime                 // Дата-время: ячейка даты с форматом DateFormat
+)
+
+// Колонка xlsx выгрузки
+type xlsxColumn struct {
+	Header string
+	Kind   xlsxKind
+	Value  func(p Product) any
+}
+
+// Колонки xlsx выгрузки в порядке следования
+var xlsxColumns = []xlsxColumn{
+	{Header: "article", Kind: xlsxText, Value: func(p Product) any { return p.Article }},
+	{Header: "brand", Kind: xlsxText, Value: func(p Product) any { return p.Brand }},
+	{Header: "name", Kind: xlsxText, Value: func(p Product) any { return p.Name }},
+	{Header: "ean", Kind: xlsxText, Value: func(p Product) any { return p.EAN }},
+	{Header: "price", Kind: xlsxNumber, Value: func(p Product) any {
+		if p.Price == nil {
+			return nil
+		}
+		return *p.Price
+	}},
+	{Header: "updated_at", Kind: xlsxDateTime, Value: func(p Product) any { return p.UpdatedAt }},
+}
+
+// Защита от интерпретации текста как формулы: значения, начинающиеся с =, +, -, @
+// или управляющего символа, предваряются апострофом, как это делает сам Excel
+// для текста, введённого вручную
+func escapeXLSXFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
+
+// Экспорт таблицы товаров в xlsx файл (постранично, потоковой записью)
+func exportToXLSXFile(db *gorm.DB, outputPath string, batchSize int, settings XLSXExportSettings) error {
+	numberFormat := settings.NumberFormat
+	if numberFormat == "" {
+		numberFormat = defaultXLSXNumberFormat
+	}
+	dateFormat := settings.DateFormat
+	if dateFormat == "" {
+		dateFormat = defaultXLSXDateFormat
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "Sheet1"
+	numberStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &numberFormat})
+	if err != nil {
+		return err
+	}
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	header := make([]any, len(xlsxColumns))
+	for i, col := range xlsxColumns {
+		header[i] = excelize.Cell{Value: col.Header}
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	rowNum := 2
+	for offset := 0; ; offset += batchSize {
+		var page []Product
+		if err := db.Order("id").Limit(batchSize).Offset(offset).Find(&page).Error; err != nil {
+			return fmt.Errorf("ошибка при выборке данных: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		for _, p := range page {
+			values := make([]any, len(xlsxColumns))
+			for i, col := range xlsxColumns {
+				values[i] = xlsxCell(col, p, numberStyle, dateStyle)
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, rowNum)
+			if err := sw.SetRow(cell, values); err != nil {
+				return err
+			}
+			rowNum++
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return f.SaveAs(outputPath)
+}
+
+// Ячейка xlsx выгрузки с типом и форматом, соответствующими колонке
+func xlsxCell(col xlsxColumn, p Product, numberStyle, dateStyle int) excelize.Cell {
+	value := col.Value(p)
+	switch col.Kind {
+	case xlsxNumber:
+		if value == nil {
+			return excelize.Cell{}
+		}
+		return excelize.Cell{StyleID: numberStyle, Value: value}
+	case xlsxDateTime:
+		if t, ok := value.(time.Time); ok && t.IsZero() {
+			return excelize.Cell{}
+		}
+		return excelize.Cell{StyleID: dateStyle, Value: value}
+	default:
+		// Строка передаётся как string: потоковая запись сохраняет её строковой ячейкой
+		return excelize.Cell{Value: escapeXLSXFormula(fmt.Sprint(value))}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestEscapeXLSXFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+7 495 000-00-00", "'+7 495 000-00-00"},
+		{"-10%", "'-10%"},
+		{"@HYPERLINK", "'@HYPERLINK"},
+		{"\tФильтр", "'\tФильтр"},
+		{"Фильтр =SUM", "Фильтр =SUM"},
+		{"AB-100", "AB-100"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := escapeXLSXFormula(tt.value); got != tt.want {
+			t.Errorf("escapeXLSXFormula(%q) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// Название "=SUM" выгружается текстом, а не формулой; цена — числом с форматом
+func TestExportToXLSXFileCellTypes(t *testing.T) {
+	setTestConfig(t, Config{})
+	db := newTestDB(t)
+	price := 1234.5
+	products := []Product{
+		{Article: "ab100", Brand: "bosch", Name: "=SUM(A1:A2)", Hash: "h1", Price: &price},
+		{Article: "ab200", Brand: "bosch", Name: "Свеча", Hash: "h2"},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "catalog.xlsx")
+	if err := exportToXLSXFile(db, output, 1, XLSXExportSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	formula, err := f.GetCellFormula("Sheet1", "C2")
+	if err != nil || formula != "" {
+		t.Errorf("название выгружено формулой %q (%v)", formula, err)
+	}
+	if name, _ := f.GetCellValue("Sheet1", "C2"); name != "'=SUM(A1:A2)" {
+		t.Errorf("название %q", name)
+	}
+
+	tests := []struct {
+		cell     string
+		wantType excelize.CellType
+		wantText string
+	}{
+		{"E2", excelize.CellTypeUnset, "1,234.50"}, // Число без атрибута типа
+		{"E3", excelize.CellTypeUnset, ""},         // Цена неизвестна — пустая ячейка
+		{"A2", excelize.CellTypeInlineString, "ab100"},
+	}
+	for _, tt := range tests {
+		cellType, err := f.GetCellType("Sheet1", tt.cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, _ := f.GetCellValue("Sheet1", tt.cell)
+		if cellType != tt.wantType || text != tt.wantText {
+			t.Errorf("%s: тип %v, текст %q; ожидалось %v, %q", tt.cell, cellType, text, tt.wantType, tt.wantText)
+		}
+	}
+}
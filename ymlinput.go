@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Расширения фидов YML (Яндекс Маркет); xml — так их часто выгружают поставщики
+var ymlExtensions = []string{".yml", ".xml"}
+
+// Поля предложения YML, из которых берутся колонки товара, если у фида нет
+// своей записи: vendor — бренд, vendorCode — артикул, name — название.
+// Заголовки поиска обязательны, поэтому barcode и price задаются в записи.
+var ymlHeaders = HeaderSettings{
+	Brand:   "vendor",
+	Article: "vendorCode",
+	Name:    "name",
+}
+
+// Фид YML (по расширению, без учёта регистра)
+func isYMLFile(name string) bool {
+	return slices.Contains(ymlExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// Фид YML как прайс-лист: каждое предложение <offer> — строка, его атрибуты
+// и дочерние элементы — поля (см. feedWorkbook). Характеристики <param name="Цвет">
+// называются "param.Цвет", повторы элемента — "picture", "picture.1" и т. д.;
+// поле category — название категории по categoryId.
+func openYMLWorkbook(filePath string) (workbook, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	objects, err := parseYMLOffers(file)
+	if err != nil {
+		return nil, err
+	}
+	return feedWorkbook(filePath, objects, nil), nil
+}
+
+// Разбор фида: категории (они идут перед предложениями) и предложения
+func parseYMLOffers(r io.Reader) ([]flatObject, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = ymlCharsetReader
+
+	categories := make(map[string]string)
+	var offers []flatObject
+	root := true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root {
+			if start.Name.Local != "yml_catalog" {
+				return nil, fmt.Errorf("ожидается фид YML (yml_catalog), получен элемент %s", start.Name.Local)
+			}
+			root = false
+			continue
+		}
+
+		switch start.Name.Local {
+		case "category":
+			var name string
+			if err := decoder.DecodeElement(&name, &start); err != nil {
+				return nil, err
+			}
+			categories[ymlAttr(start, "id")] = strings.TrimSpace(name)
+		case "offer":
+			offer, err := readYMLOffer(decoder, start)
+			if err != nil {
+				return nil, fmt.Errorf("предложение %d: %w", len(offers)+1, err)
+			}
+			if name, ok := categories[offer.values["categoryId"]]; ok {
+				offer.set("category", name)
+			}
+			offers = append(offers, offer)
+		}
+	}
+	if root {
+		return nil, errors.New("файл не содержит фида YML")
+	}
+	return offers, nil
+}
+
+// Поля одного предложения. Вложенные элементы глубже дочерних (например
+// delivery-options) пропускаются.
+func readYMLOffer(decoder *xml.Decoder, start xml.StartElement) (flatObject, error) {
+	offer := flatObject{values: make(map[string]string)}
+	for _, attr := range start.Attr {
+		offer.set(attr.Name.Local, attr.Value)
+	}
+
+	repeats := make(map[string]int)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return offer, err
+		}
+		switch t := token.(type) {
+		case xml.EndElement:
+			return offer, nil
+		case xml.StartElement:
+			var element struct {
+				Text   string `xml:",chardata"`
+				Nested []struct {
+					XMLName xml.Name
+				} `xml:",any"`
+			}
+			if err := decoder.DecodeElement(&element, &t); err != nil {
+				return offer, err
+			}
+			if len(element.Nested) > 0 {
+				continue
+			}
+
+			field := t.Name.Local
+			if t.Name.Local == "param" {
+				field = "param." + ymlAttr(t, "name")
+			}
+			n := repeats[field]
+			repeats[field]++
+			if n > 0 {
+				field += "." + strconv.Itoa(n)
+			}
+			offer.set(field, strings.TrimSpace(element.Text))
+		}
+	}
+}
+
+// Значение атрибута элемента без пространства имён ("" — атрибута нет)
+func ymlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Фиды русских поставщиков нередко выгружаются в windows-1251
+func ymlCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "windows-1251", "cp1251":
+		return charmap.Windows1251.NewDecoder().Reader(input), nil
+	case "utf-8", "utf8":
+		return input, nil
+	}
+	return nil, fmt.Errorf("неизвестная кодировка фида %q", label)
+}